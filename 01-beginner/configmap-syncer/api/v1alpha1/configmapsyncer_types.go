@@ -10,23 +10,146 @@ type ConfigMapSyncerSpec struct {
 	// +kubebuilder:validation:Required
 	SourceNamespace string `json:"sourceNamespace"`
 
-	// SourceConfigMap is the name of the ConfigMap to sync
-	// +kubebuilder:validation:Required
-	SourceConfigMap string `json:"sourceConfigMap"`
+	// SourceConfigMap is the name of the ConfigMap to sync.
+	// Exactly one of SourceConfigMap or SourceSelector must be set.
+	// +optional
+	SourceConfigMap string `json:"sourceConfigMap,omitempty"`
+
+	// SourceSelector selects every ConfigMap in SourceNamespace carrying
+	// matching labels, syncing each of them to the target namespaces.
+	// +optional
+	SourceSelector *metav1.LabelSelector `json:"sourceSelector,omitempty"`
 
 	// TargetNamespaces is the list of namespaces to sync to
 	// +kubebuilder:validation:MinItems=1
 	TargetNamespaces []string `json:"targetNamespaces"`
+
+	// FlattenInto, when set, renders all source data keys into this single
+	// target key instead of copying them individually. BinaryData is copied unchanged.
+	// +optional
+	FlattenInto string `json:"flattenInto,omitempty"`
+
+	// FlattenFormat is the rendering used by FlattenInto
+	// +kubebuilder:validation:Enum=properties;json
+	// +kubebuilder:default=properties
+	FlattenFormat string `json:"flattenFormat,omitempty"`
+
+	// MergeStrategy controls how a source Data value is combined with a
+	// value the target already holds for the same key. replace overwrites it.
+	// jsonMerge applies the source value as a JSON merge patch (RFC 7386), so
+	// keys only present in the target survive. strategicPatch applies it as a
+	// Kubernetes strategic merge patch, merging lists of objects by their
+	// "name" field and lists of scalars as sets.
+	// +kubebuilder:validation:Enum=replace;jsonMerge;strategicPatch
+	// +kubebuilder:default=replace
+	MergeStrategy string `json:"mergeStrategy,omitempty"`
+
+	// OwnedKeys limits the syncer to these Data and BinaryData keys in the
+	// targets. Other keys in a target are left untouched, and an owned key
+	// missing from the source is removed. Empty means the syncer owns all keys.
+	// +optional
+	OwnedKeys []string `json:"ownedKeys,omitempty"`
+
+	// SyncWindows restricts writes to target namespaces to these recurring
+	// windows. Changes made outside a window are applied when the next one
+	// opens. Empty means writes are always allowed.
+	// +optional
+	SyncWindows []SyncWindow `json:"syncWindows,omitempty"`
+
+	// MaxConcurrentWrites caps how many target namespaces are written in parallel
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	MaxConcurrentWrites int32 `json:"maxConcurrentWrites,omitempty"`
+
+	// MaxDataBytes is the largest source, counting the keys and values of
+	// Data and BinaryData, that is synced. A larger source is not synced to
+	// any target. Defaults to the 1MiB ConfigMap size limit.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1048576
+	MaxDataBytes int64 `json:"maxDataBytes,omitempty"`
+
+	// SyncPriority orders target namespaces into groups synced one after
+	// another, highest priority first. Target namespaces not listed in any
+	// group are synced last. Empty means all targets are synced together.
+	// +optional
+	SyncPriority []SyncPriorityGroup `json:"syncPriority,omitempty"`
+
+	// SyncOnlyIfChanged skips a source, without reading or hashing its
+	// targets, while it still has the resourceVersion recorded at its last
+	// sync to every target and the syncer spec is unchanged. Edits to targets
+	// are then only corrected when ResyncInterval elapses.
+	// +optional
+	SyncOnlyIfChanged bool `json:"syncOnlyIfChanged,omitempty"`
+
+	// ResyncInterval, with SyncOnlyIfChanged, syncs a source again once this
+	// long has passed since its last sync to every target, even if it is
+	// unchanged. Empty means unchanged sources are never resynced.
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+}
+
+// SyncPriorityGroup is a set of target namespaces synced before any lower-priority group
+type SyncPriorityGroup struct {
+	// Namespaces lists the target namespaces in this group. Each must also
+	// appear in TargetNamespaces.
+	// +kubebuilder:validation:MinItems=1
+	Namespaces []string `json:"namespaces"`
+
+	// AbortOnFailure skips all lower-priority namespaces when any namespace
+	// in this group fails to sync. Skipped namespaces are reported as failed
+	// and retried with the rest.
+	// +optional
+	AbortOnFailure bool `json:"abortOnFailure,omitempty"`
+}
+
+// SyncWindow is a recurring period during which target ConfigMaps may be written
+type SyncWindow struct {
+	// Schedule is a cron expression marking when the window opens
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window stays open, e.g. "2h"
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+}
+
+// SourceSyncStatus records the sync result of a single source ConfigMap
+type SourceSyncStatus struct {
+	// Name is the name of the source ConfigMap
+	Name string `json:"name"`
+
+	// SyncedNamespaces lists namespaces this source was synced to
+	SyncedNamespaces []string `json:"syncedNamespaces,omitempty"`
+
+	// FailedNamespaces lists namespaces this source failed to sync to
+	FailedNamespaces []string `json:"failedNamespaces,omitempty"`
+
+	// ObservedResourceVersion is the resourceVersion of the source at its
+	// last sync to every target
+	ObservedResourceVersion string `json:"observedResourceVersion,omitempty"`
+
+	// LastFullSyncTime is when the source was last synced to every target
+	LastFullSyncTime *metav1.Time `json:"lastFullSyncTime,omitempty"`
 }
 
 // ConfigMapSyncerStatus defines the observed state of ConfigMapSyncer
 type ConfigMapSyncerStatus struct {
+	// ObservedGeneration is the most recent generation that was successfully synced
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// SyncedNamespaces lists successfully synced namespaces
 	SyncedNamespaces []string `json:"syncedNamespaces,omitempty"`
 
 	// FailedNamespaces lists namespaces that failed to sync
 	FailedNamespaces []string `json:"failedNamespaces,omitempty"`
 
+	// RetryAttempts counts consecutive syncs with failed namespaces and drives
+	// the retry backoff. It resets once every target syncs.
+	RetryAttempts int32 `json:"retryAttempts,omitempty"`
+
+	// Sources tracks each synced source ConfigMap individually
+	Sources []SourceSyncStatus `json:"sources,omitempty"`
+
 	// LastSyncTime is the last successful sync timestamp
 	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
 
@@ -55,6 +178,30 @@ type ConfigMapSyncerList struct {
 	Items           []ConfigMapSyncer `json:"items"`
 }
 
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterConfigMapSyncer is a cluster-scoped ConfigMapSyncer, managed
+// centrally by platform admins rather than from a tenant namespace
+type ClusterConfigMapSyncer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigMapSyncerSpec   `json:"spec,omitempty"`
+	Status ConfigMapSyncerStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterConfigMapSyncerList contains a list of ClusterConfigMapSyncer
+type ClusterConfigMapSyncerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterConfigMapSyncer `json:"items"`
+}
+
 func init() {
 	SchemeBuilder.Register(&ConfigMapSyncer{}, &ConfigMapSyncerList{})
+	SchemeBuilder.Register(&ClusterConfigMapSyncer{}, &ClusterConfigMapSyncerList{})
 }
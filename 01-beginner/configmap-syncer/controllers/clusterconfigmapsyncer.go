@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	configv1alpha1 "github.com/nutcas3/configmap-syncer/api/v1alpha1"
+)
+
+// A ClusterConfigMapSyncer is reconciled through a ConfigMapSyncer view of
+// it with an empty namespace. Namespaced syncers always have a namespace, so
+// the empty one tells the two kinds apart, both on the view and on the
+// reconcile requests enqueued for it.
+
+// clusterScoped reports whether syncer is the view of a ClusterConfigMapSyncer
+func clusterScoped(syncer *configv1alpha1.ConfigMapSyncer) bool {
+	return syncer.Namespace == ""
+}
+
+// clusterSyncerView returns the ConfigMapSyncer view of a ClusterConfigMapSyncer
+func clusterSyncerView(cluster *configv1alpha1.ClusterConfigMapSyncer) *configv1alpha1.ConfigMapSyncer {
+	return &configv1alpha1.ConfigMapSyncer{
+		ObjectMeta: cluster.ObjectMeta,
+		Spec:       cluster.Spec,
+		Status:     cluster.Status,
+	}
+}
+
+// clusterSyncerFor returns the ClusterConfigMapSyncer a view stands for
+func clusterSyncerFor(syncer *configv1alpha1.ConfigMapSyncer) *configv1alpha1.ClusterConfigMapSyncer {
+	return &configv1alpha1.ClusterConfigMapSyncer{
+		ObjectMeta: syncer.ObjectMeta,
+		Spec:       syncer.Spec,
+		Status:     syncer.Status,
+	}
+}
+
+// getSyncer fetches the syncer a request was enqueued for, a
+// ClusterConfigMapSyncer when the request has no namespace
+func (r *ConfigMapSyncerReconciler) getSyncer(ctx context.Context, key types.NamespacedName) (*configv1alpha1.ConfigMapSyncer, error) {
+	if key.Namespace != "" {
+		syncer := &configv1alpha1.ConfigMapSyncer{}
+		if err := r.Get(ctx, key, syncer); err != nil {
+			return nil, err
+		}
+		return syncer, nil
+	}
+
+	cluster := &configv1alpha1.ClusterConfigMapSyncer{}
+	if err := r.Get(ctx, key, cluster); err != nil {
+		return nil, err
+	}
+	return clusterSyncerView(cluster), nil
+}
+
+// listSyncers lists every ConfigMapSyncer and the views of every
+// ClusterConfigMapSyncer, for the watches to map events onto
+func (r *ConfigMapSyncerReconciler) listSyncers(ctx context.Context) ([]configv1alpha1.ConfigMapSyncer, error) {
+	syncers := &configv1alpha1.ConfigMapSyncerList{}
+	if err := r.List(ctx, syncers); err != nil {
+		return nil, err
+	}
+
+	clusterSyncers := &configv1alpha1.ClusterConfigMapSyncerList{}
+	if err := r.List(ctx, clusterSyncers); err != nil {
+		return nil, err
+	}
+	for i := range clusterSyncers.Items {
+		syncers.Items = append(syncers.Items, *clusterSyncerView(&clusterSyncers.Items[i]))
+	}
+	return syncers.Items, nil
+}
+
+// updateSyncer writes the syncer's metadata and spec, such as its finalizers
+func (r *ConfigMapSyncerReconciler) updateSyncer(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer) error {
+	if !clusterScoped(syncer) {
+		return r.Update(ctx, syncer)
+	}
+
+	cluster := clusterSyncerFor(syncer)
+	err := r.Update(ctx, cluster)
+	syncer.ObjectMeta = cluster.ObjectMeta
+	return err
+}
+
+// updateSyncerStatus writes the syncer's status
+func (r *ConfigMapSyncerReconciler) updateSyncerStatus(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer) error {
+	if !clusterScoped(syncer) {
+		return r.Status().Update(ctx, syncer)
+	}
+
+	cluster := clusterSyncerFor(syncer)
+	err := r.Status().Update(ctx, cluster)
+	syncer.ObjectMeta = cluster.ObjectMeta
+	return err
+}
+
+// event records an event on the syncer, or on the ClusterConfigMapSyncer it stands for
+func (r *ConfigMapSyncerReconciler) event(syncer *configv1alpha1.ConfigMapSyncer, eventtype, reason, message string) {
+	if clusterScoped(syncer) {
+		r.Recorder.Event(clusterSyncerFor(syncer), eventtype, reason, message)
+		return
+	}
+	r.Recorder.Event(syncer, eventtype, reason, message)
+}
@@ -2,38 +2,117 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
+	"hash"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	configv1alpha1 "github.com/nutcas3/configmap-syncer/api/v1alpha1"
+	"github.com/robfig/cron/v3"
 )
 
 const (
 	finalizerName = "configmapsyncer.config.example.com/finalizer"
+
+	// defaultAnnotationPrefix prefixes the annotations written on target copies
+	defaultAnnotationPrefix = "configmapsyncer.config.example.com/"
+
+	// contentHashAnnotation records the hash of the content last written to a target
+	contentHashAnnotation = "content-hash"
+
+	// sourceContentRevisionAnnotation records the source resourceVersion
+	// whose content a target was last written with. Source edits that leave
+	// the synced content alone don't update it.
+	sourceContentRevisionAnnotation = "source-content-revision"
+
+	// sourceUIDAnnotation records the UID of the source a target was written from
+	sourceUIDAnnotation = "source-uid"
+
+	// tombstoneValue as a source value deletes that key from every target
+	tombstoneValue = "__DELETE__"
+
+	// defaultMaxConcurrentWrites bounds fan-out when the spec leaves it unset
+	defaultMaxConcurrentWrites = 5
+
+	// defaultMaxDataBytes is the ConfigMap size limit enforced by the API server
+	defaultMaxDataBytes = 1 << 20
+
+	// Merge strategies for keys the target already holds
+	mergeReplace        = "replace"
+	mergeStrategicPatch = "strategicPatch"
+
+	// Backoff for retrying namespaces that failed to sync
+	retryBaseDelay = 5 * time.Second
+	retryMaxDelay  = 5 * time.Minute
 )
 
 // ConfigMapSyncerReconciler reconciles a ConfigMapSyncer object
 type ConfigMapSyncerReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// AllowedSourceNamespaces maps a syncer's namespace to the source namespaces
+	// it may read from. The "*" key applies to every namespace. A nil map leaves
+	// source namespaces unrestricted.
+	AllowedSourceNamespaces map[string][]string
+
+	// LabelPrefix is prepended to the synced-by and synced-from labels on
+	// target copies. Empty keeps the unprefixed keys.
+	LabelPrefix string
+
+	// AnnotationPrefix replaces "configmapsyncer.config.example.com/" on the
+	// annotations written to target copies. Empty keeps the default.
+	AnnotationPrefix string
+
+	// PauseConfigMap names a ConfigMap whose existence pauses every syncer.
+	// An empty name disables the switch.
+	PauseConfigMap types.NamespacedName
+
+	// DriftCheckInterval requeues every synced syncer so targets edited out
+	// of band are found, and the drift gauge refreshed, without waiting for
+	// the source or the syncer to change. Zero disables the periodic check.
+	DriftCheckInterval time.Duration
 }
 
 //+kubebuilder:rbac:groups=config.example.com,resources=configmapsyncers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=config.example.com,resources=configmapsyncers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=config.example.com,resources=configmapsyncers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=config.example.com,resources=clusterconfigmapsyncers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=config.example.com,resources=clusterconfigmapsyncers/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=config.example.com,resources=clusterconfigmapsyncers/finalizers,verbs=update
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -41,8 +120,8 @@ func (r *ConfigMapSyncerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	log := log.FromContext(ctx)
 
 	// 1. Fetch the ConfigMapSyncer
-	syncer := &configv1alpha1.ConfigMapSyncer{}
-	if err := r.Get(ctx, req.NamespacedName, syncer); err != nil {
+	syncer, err := r.getSyncer(ctx, req.NamespacedName)
+	if err != nil {
 		if errors.IsNotFound(err) {
 			log.Info("ConfigMapSyncer resource not found. Ignoring since object must be deleted")
 			return ctrl.Result{}, nil
@@ -51,23 +130,122 @@ func (r *ConfigMapSyncerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
-	// 2. Handle deletion with finalizers
+	// 2. Freeze all syncing, including cleanup on deletion, while the pause ConfigMap exists
+	paused, err := r.paused(ctx)
+	if err != nil {
+		log.Error(err, "Failed to check the pause ConfigMap")
+		return ctrl.Result{}, err
+	}
+	if paused {
+		log.Info("Syncing is paused", "configMap", r.PauseConfigMap)
+		r.updateStatusCondition(ctx, syncer, metav1.Condition{
+			Type:               "Paused",
+			Status:             metav1.ConditionTrue,
+			Reason:             "ControllerPaused",
+			Message:            fmt.Sprintf("All syncing is paused while ConfigMap %s exists", r.PauseConfigMap),
+			LastTransitionTime: metav1.Now(),
+		})
+		if err := r.updateSyncerStatus(ctx, syncer); err != nil {
+			log.Error(err, "Failed to update ConfigMapSyncer status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&syncer.Status.Conditions, "Paused")
+
+	// 3. Handle deletion with finalizers
 	if !syncer.DeletionTimestamp.IsZero() {
 		return r.handleDeletion(ctx, syncer)
 	}
 
-	// 3. Add finalizer if not present
+	// 4. Add finalizer if not present
 	if !controllerutil.ContainsFinalizer(syncer, finalizerName) {
 		controllerutil.AddFinalizer(syncer, finalizerName)
-		if err := r.Update(ctx, syncer); err != nil {
+		if err := r.updateSyncer(ctx, syncer); err != nil {
 			log.Error(err, "Failed to add finalizer")
 			return ctrl.Result{}, err
 		}
 		log.Info("Added finalizer to ConfigMapSyncer")
 	}
 
-	// 4. Fetch source ConfigMap
-	sourceConfigMap, err := r.getSourceConfigMap(ctx, syncer)
+	// 5. Validate source configuration and sync priority
+	err = validateSource(syncer)
+	if err == nil {
+		err = validateSyncPriority(syncer)
+	}
+	if err != nil {
+		log.Error(err, "Invalid ConfigMapSyncer spec")
+		r.updateStatusCondition(ctx, syncer, metav1.Condition{
+			Type:    "Ready",
+			Status:  metav1.ConditionFalse,
+			Reason:  "InvalidSpec",
+			Message: err.Error(),
+		})
+		return ctrl.Result{}, nil
+	}
+
+	// 6. Enforce tenant isolation on the source namespace
+	if !r.sourceNamespaceAllowed(syncer) {
+		log.Info("Source namespace not allowed for syncer", "namespace", syncer.Namespace, "sourceNamespace", syncer.Spec.SourceNamespace)
+		r.updateStatusCondition(ctx, syncer, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionFalse,
+			Reason:             "Forbidden",
+			Message:            fmt.Sprintf("Syncers in namespace %s may not read from namespace %s", syncer.Namespace, syncer.Spec.SourceNamespace),
+			LastTransitionTime: metav1.Now(),
+		})
+		if err := r.updateSyncerStatus(ctx, syncer); err != nil {
+			log.Error(err, "Failed to update ConfigMapSyncer status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 7. Defer writes outside of the configured sync windows
+	if len(syncer.Spec.SyncWindows) > 0 {
+		open, nextOpen, err := syncWindowOpen(syncer.Spec.SyncWindows, time.Now())
+		if err != nil {
+			log.Error(err, "Invalid sync window")
+			r.updateStatusCondition(ctx, syncer, metav1.Condition{
+				Type:               "Ready",
+				Status:             metav1.ConditionFalse,
+				Reason:             "InvalidSpec",
+				Message:            err.Error(),
+				LastTransitionTime: metav1.Now(),
+			})
+			if err := r.updateSyncerStatus(ctx, syncer); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+
+		if !open {
+			log.Info("Outside of sync window, deferring sync", "nextWindow", nextOpen)
+			r.updateStatusCondition(ctx, syncer, metav1.Condition{
+				Type:               "WaitingForWindow",
+				Status:             metav1.ConditionTrue,
+				Reason:             "OutsideSyncWindow",
+				Message:            fmt.Sprintf("Sync deferred until the next window opens at %s", nextOpen.UTC().Format(time.RFC3339)),
+				LastTransitionTime: metav1.Now(),
+			})
+			if err := r.updateSyncerStatus(ctx, syncer); err != nil {
+				log.Error(err, "Failed to update ConfigMapSyncer status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: time.Until(nextOpen)}, nil
+		}
+
+		r.updateStatusCondition(ctx, syncer, metav1.Condition{
+			Type:               "WaitingForWindow",
+			Status:             metav1.ConditionFalse,
+			Reason:             "InsideSyncWindow",
+			Message:            "A sync window is open",
+			LastTransitionTime: metav1.Now(),
+		})
+	}
+
+	// 8. Fetch source ConfigMaps
+	sourceConfigMaps, err := r.getSourceConfigMaps(ctx, syncer)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			log.Info("Source ConfigMap not found", "namespace", syncer.Spec.SourceNamespace, "name", syncer.Spec.SourceConfigMap)
@@ -83,18 +261,79 @@ func (r *ConfigMapSyncerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
-	// 5. Sync to target namespaces
-	syncedNamespaces, failedNamespaces, err := r.syncToTargets(ctx, syncer, sourceConfigMap)
-	if err != nil {
-		log.Error(err, "Failed to sync to targets")
+	// Sources that stopped matching the selector leave no copies behind
+	if err := r.pruneUnmatchedSources(ctx, syncer, sourceConfigMaps); err != nil {
+		log.Error(err, "Failed to remove copies of unmatched sources")
 		return ctrl.Result{}, err
 	}
 
-	// 6. Update status
+	if len(sourceConfigMaps) == 0 {
+		log.Info("No source ConfigMaps match selector", "namespace", syncer.Spec.SourceNamespace)
+		r.updateStatusCondition(ctx, syncer, metav1.Condition{
+			Type:    "Ready",
+			Status:  metav1.ConditionFalse,
+			Reason:  "SourceNotFound",
+			Message: fmt.Sprintf("No ConfigMaps in namespace %s match the source selector", syncer.Spec.SourceNamespace),
+		})
+		if err := r.updateSyncerStatus(ctx, syncer); err != nil {
+			log.Error(err, "Failed to update ConfigMapSyncer status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// 9. Sync each source to target namespaces
+	var sources []configv1alpha1.SourceSyncStatus
+	var mismatchedTargets []string
+	var oversizedSources []string
+	for i := range sourceConfigMaps {
+		// In strict mode an unchanged source is skipped before any hashing
+		if previous := r.unchangedSource(ctx, syncer, &sourceConfigMaps[i], time.Now()); previous != nil {
+			log.Info("Source unchanged since its last sync, skipping", "source", sourceConfigMaps[i].Name,
+				"resourceVersion", previous.ObservedResourceVersion)
+			sources = append(sources, *previous)
+			continue
+		}
+
+		if size := dataSize(&sourceConfigMaps[i]); size > maxDataBytes(syncer) {
+			r.recordSourceTooLarge(ctx, syncer, &sourceConfigMaps[i], size)
+			oversizedSources = append(oversizedSources, sourceConfigMaps[i].Name)
+			// Keep the targets synced before so they are still cleaned up on deletion
+			if previous := sourceStatus(syncer, sourceConfigMaps[i].Name); previous != nil {
+				sources = append(sources, *previous)
+			}
+			continue
+		}
+
+		synced, failed, mismatched, err := r.syncToTargets(ctx, syncer, &sourceConfigMaps[i])
+		if err != nil {
+			log.Error(err, "Failed to sync to targets", "source", sourceConfigMaps[i].Name)
+			return ctrl.Result{}, err
+		}
+		status := configv1alpha1.SourceSyncStatus{
+			Name:             sourceConfigMaps[i].Name,
+			SyncedNamespaces: synced,
+			FailedNamespaces: failed,
+		}
+		if len(failed) == 0 {
+			syncTime := metav1.Now()
+			status.ObservedResourceVersion = sourceConfigMaps[i].ResourceVersion
+			status.LastFullSyncTime = &syncTime
+		}
+		sources = append(sources, status)
+		for _, ns := range mismatched {
+			mismatchedTargets = append(mismatchedTargets, ns+"/"+sourceConfigMaps[i].Name)
+		}
+	}
+	syncedNamespaces, failedNamespaces := aggregateNamespaces(sources)
+
+	// 10. Update status
+	syncer.Status.Sources = sources
 	syncer.Status.SyncedNamespaces = syncedNamespaces
 	syncer.Status.FailedNamespaces = failedNamespaces
 	now := metav1.Now()
 	syncer.Status.LastSyncTime = &now
+	syncer.Status.ObservedGeneration = syncer.Generation
 
 	condition := metav1.Condition{
 		Type:               "Ready",
@@ -104,24 +343,62 @@ func (r *ConfigMapSyncerReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		LastTransitionTime: now,
 	}
 
-	if len(failedNamespaces) > 0 {
+	// Retry failed namespaces with backoff rather than waiting for the next event
+	var result ctrl.Result
+	if len(failedNamespaces) == 0 {
+		syncer.Status.RetryAttempts = 0
+	} else {
+		syncer.Status.RetryAttempts++
+		result.RequeueAfter = retryBackoff(syncer.Status.RetryAttempts)
 		condition.Status = metav1.ConditionFalse
 		condition.Reason = "SyncPartiallyFailed"
 		condition.Message = fmt.Sprintf("Synced to %d namespaces, failed: %d", len(syncedNamespaces), len(failedNamespaces))
 	}
+	if len(oversizedSources) > 0 && condition.Status == metav1.ConditionTrue {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "SourceTooLarge"
+		condition.Message = fmt.Sprintf("Source ConfigMap(s) %s exceed maxDataBytes and were not synced", strings.Join(oversizedSources, ", "))
+	}
+
+	// Come back when the next unchanged source is due for a resync
+	if next := nextResync(syncer); !next.IsZero() {
+		if wait := time.Until(next); result.RequeueAfter == 0 || wait < result.RequeueAfter {
+			result.RequeueAfter = wait
+		}
+	}
+
+	// Come back to check the targets for drift even if nothing changes
+	if interval := r.DriftCheckInterval; interval > 0 && (result.RequeueAfter == 0 || interval < result.RequeueAfter) {
+		result.RequeueAfter = interval
+	}
 
 	r.updateStatusCondition(ctx, syncer, condition)
+	r.updateTypeMismatchCondition(ctx, syncer, mismatchedTargets)
+	r.updateSourceTooLargeCondition(ctx, syncer, oversizedSources)
 
-	if err := r.Status().Update(ctx, syncer); err != nil {
+	if err := r.updateSyncerStatus(ctx, syncer); err != nil {
 		log.Error(err, "Failed to update ConfigMapSyncer status")
 		return ctrl.Result{}, err
 	}
 
 	log.Info("Successfully reconciled ConfigMapSyncer",
 		"synced", len(syncedNamespaces),
-		"failed", len(failedNamespaces))
+		"failed", len(failedNamespaces),
+		"retryAfter", result.RequeueAfter)
 
-	return ctrl.Result{}, nil
+	return result, nil
+}
+
+// retryBackoff doubles the retry delay for each consecutive failed attempt, up to retryMaxDelay
+func retryBackoff(attempts int32) time.Duration {
+	delay := retryBaseDelay
+	for i := int32(1); i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
 }
 
 // handleDeletion handles the deletion of ConfigMapSyncer with finalizer cleanup
@@ -133,26 +410,39 @@ func (r *ConfigMapSyncerReconciler) handleDeletion(ctx context.Context, syncer *
 
 		// Delete synced ConfigMaps from all target namespaces
 		for _, ns := range syncer.Spec.TargetNamespaces {
-			cm := &corev1.ConfigMap{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      syncer.Spec.SourceConfigMap,
-					Namespace: ns,
-				},
-			}
+			for _, name := range syncedSourceNames(syncer) {
+				driftDetected.DeleteLabelValues(ns, name)
 
-			if err := r.Delete(ctx, cm); err != nil {
-				if !errors.IsNotFound(err) {
-					log.Error(err, "Failed to delete ConfigMap", "namespace", ns, "name", syncer.Spec.SourceConfigMap)
-					return ctrl.Result{}, err
+				// Shared targets only lose the keys this syncer owns
+				if len(syncer.Spec.OwnedKeys) > 0 {
+					if err := r.removeOwnedKeys(ctx, syncer, ns, name); err != nil {
+						log.Error(err, "Failed to remove owned keys", "namespace", ns, "name", name)
+						return ctrl.Result{}, err
+					}
+					continue
+				}
+
+				cm := &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: ns,
+					},
+				}
+
+				if err := r.Delete(ctx, cm); err != nil {
+					if !errors.IsNotFound(err) {
+						log.Error(err, "Failed to delete ConfigMap", "namespace", ns, "name", name)
+						return ctrl.Result{}, err
+					}
+				} else {
+					log.Info("Deleted synced ConfigMap", "namespace", ns, "name", name)
 				}
-			} else {
-				log.Info("Deleted synced ConfigMap", "namespace", ns, "name", syncer.Spec.SourceConfigMap)
 			}
 		}
 
 		// Remove finalizer
 		controllerutil.RemoveFinalizer(syncer, finalizerName)
-		if err := r.Update(ctx, syncer); err != nil {
+		if err := r.updateSyncer(ctx, syncer); err != nil {
 			log.Error(err, "Failed to remove finalizer")
 			return ctrl.Result{}, err
 		}
@@ -162,90 +452,934 @@ func (r *ConfigMapSyncerReconciler) handleDeletion(ctx context.Context, syncer *
 	return ctrl.Result{}, nil
 }
 
-// getSourceConfigMap fetches the source ConfigMap
-func (r *ConfigMapSyncerReconciler) getSourceConfigMap(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer) (*corev1.ConfigMap, error) {
-	configMap := &corev1.ConfigMap{}
-	err := r.Get(ctx, types.NamespacedName{
-		Name:      syncer.Spec.SourceConfigMap,
-		Namespace: syncer.Spec.SourceNamespace,
-	}, configMap)
+// removeOwnedKeys strips the syncer's owned keys from a target ConfigMap,
+// deleting it only when no keys are left
+func (r *ConfigMapSyncerReconciler) removeOwnedKeys(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, namespace, name string) error {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, cm); err != nil {
+		return client.IgnoreNotFound(err)
+	}
 
-	return configMap, err
+	cm.Data = mergeOwnedKeys(syncer.Spec.OwnedKeys, cm.Data, nil)
+	cm.BinaryData = mergeOwnedKeys(syncer.Spec.OwnedKeys, cm.BinaryData, nil)
+	if len(cm.Data) == 0 && len(cm.BinaryData) == 0 {
+		return client.IgnoreNotFound(r.Delete(ctx, cm))
+	}
+	return r.Update(ctx, cm)
 }
 
-// syncToTargets syncs the source ConfigMap to all target namespaces
-func (r *ConfigMapSyncerReconciler) syncToTargets(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap) ([]string, []string, error) {
+// pruneUnmatchedSources removes the copies of sources recorded in status that
+// no longer match the source selector, for example because their label was
+// removed, and drops them from status. Copies written by someone else are kept.
+func (r *ConfigMapSyncerReconciler) pruneUnmatchedSources(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, sources []corev1.ConfigMap) error {
+	if syncer.Spec.SourceSelector == nil {
+		return nil
+	}
 	log := log.FromContext(ctx)
-	var syncedNamespaces []string
-	var failedNamespaces []string
 
-	for _, targetNS := range syncer.Spec.TargetNamespaces {
-		// Check if target namespace exists
-		ns := &corev1.Namespace{}
-		if err := r.Get(ctx, types.NamespacedName{Name: targetNS}, ns); err != nil {
-			if errors.IsNotFound(err) {
-				log.Info("Target namespace not found, skipping", "namespace", targetNS)
-				failedNamespaces = append(failedNamespaces, targetNS)
+	matched := make(map[string]bool, len(sources))
+	for i := range sources {
+		matched[sources[i].Name] = true
+	}
+
+	var kept []configv1alpha1.SourceSyncStatus
+	for _, previous := range syncer.Status.Sources {
+		if matched[previous.Name] {
+			kept = append(kept, previous)
+			continue
+		}
+
+		for _, ns := range previous.SyncedNamespaces {
+			driftDetected.DeleteLabelValues(ns, previous.Name)
+
+			// Shared targets only lose the keys this syncer owns
+			if len(syncer.Spec.OwnedKeys) > 0 {
+				if err := r.removeOwnedKeys(ctx, syncer, ns, previous.Name); err != nil {
+					return err
+				}
 				continue
 			}
-			log.Error(err, "Failed to check namespace", "namespace", targetNS)
-			failedNamespaces = append(failedNamespaces, targetNS)
+
+			target := &corev1.ConfigMap{}
+			if err := r.Get(ctx, types.NamespacedName{Name: previous.Name, Namespace: ns}, target); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return err
+			}
+			if !r.writtenBy(syncer, target) {
+				continue
+			}
+			if err := r.Delete(ctx, target); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		log.Info("Removed copies of a source that no longer matches the selector", "source", previous.Name)
+	}
+	syncer.Status.Sources = kept
+	return nil
+}
+
+// validateSource ensures exactly one source mode is configured
+func validateSource(syncer *configv1alpha1.ConfigMapSyncer) error {
+	if syncer.Spec.SourceConfigMap == "" && syncer.Spec.SourceSelector == nil {
+		return fmt.Errorf("one of sourceConfigMap or sourceSelector must be set")
+	}
+	if syncer.Spec.SourceConfigMap != "" && syncer.Spec.SourceSelector != nil {
+		return fmt.Errorf("sourceConfigMap and sourceSelector are mutually exclusive")
+	}
+	if syncer.Spec.SourceSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(syncer.Spec.SourceSelector); err != nil {
+			return fmt.Errorf("invalid sourceSelector: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateSyncPriority ensures every prioritized namespace is a target and
+// belongs to a single group
+func validateSyncPriority(syncer *configv1alpha1.ConfigMapSyncer) error {
+	targets := make(map[string]bool, len(syncer.Spec.TargetNamespaces))
+	for _, ns := range syncer.Spec.TargetNamespaces {
+		targets[ns] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, group := range syncer.Spec.SyncPriority {
+		for _, ns := range group.Namespaces {
+			if !targets[ns] {
+				return fmt.Errorf("syncPriority namespace %q is not in targetNamespaces", ns)
+			}
+			if seen[ns] {
+				return fmt.Errorf("syncPriority namespace %q is listed more than once", ns)
+			}
+			seen[ns] = true
+		}
+	}
+	return nil
+}
+
+// sourceNamespaceAllowed reports whether the syncer may read from its source
+// namespace. A syncer may always read from its own namespace, and cluster
+// syncers, which only platform admins can create, from any namespace.
+func (r *ConfigMapSyncerReconciler) sourceNamespaceAllowed(syncer *configv1alpha1.ConfigMapSyncer) bool {
+	if r.AllowedSourceNamespaces == nil || clusterScoped(syncer) || syncer.Spec.SourceNamespace == syncer.Namespace {
+		return true
+	}
+
+	for _, key := range []string{syncer.Namespace, "*"} {
+		for _, ns := range r.AllowedSourceNamespaces[key] {
+			if ns == syncer.Spec.SourceNamespace || ns == "*" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ParseAllowedSourceNamespaces parses a source namespace allowlist of the form
+// "team-a=shared,team-a;team-b=shared" where each rule maps a syncer namespace
+// (or "*" for all) to the source namespaces it may read. An empty string
+// returns nil, meaning unrestricted.
+func ParseAllowedSourceNamespaces(value string) (map[string][]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	allowed := make(map[string][]string)
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
 			continue
 		}
 
-		// Create target ConfigMap
-		target := &corev1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      source.Name,
-				Namespace: targetNS,
-				Labels: map[string]string{
-					"synced-by":   syncer.Name,
-					"synced-from": syncer.Spec.SourceNamespace,
-				},
-				Annotations: map[string]string{
-					"configmapsyncer.config.example.com/source-namespace": syncer.Spec.SourceNamespace,
-					"configmapsyncer.config.example.com/syncer-name":      syncer.Name,
-				},
-			},
-			Data:       source.Data,
-			BinaryData: source.BinaryData,
+		syncerNS, sources, found := strings.Cut(rule, "=")
+		syncerNS = strings.TrimSpace(syncerNS)
+		if !found || syncerNS == "" {
+			return nil, fmt.Errorf("invalid rule %q, expected <namespace>=<source>[,<source>...]", rule)
+		}
+
+		for _, source := range strings.Split(sources, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				allowed[syncerNS] = append(allowed[syncerNS], source)
+			}
+		}
+	}
+
+	return allowed, nil
+}
+
+// ParsePauseConfigMap parses the "<namespace>/<name>" of the pause ConfigMap.
+// An empty string returns an empty name, disabling the pause switch.
+func ParsePauseConfigMap(value string) (types.NamespacedName, error) {
+	if strings.TrimSpace(value) == "" {
+		return types.NamespacedName{}, nil
+	}
+
+	namespace, name, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid ConfigMap %q, expected <namespace>/<name>", value)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// getSourceConfigMaps fetches the named source ConfigMap, or every ConfigMap
+// in the source namespace matching the source selector
+func (r *ConfigMapSyncerReconciler) getSourceConfigMaps(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer) ([]corev1.ConfigMap, error) {
+	if syncer.Spec.SourceSelector == nil {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{
+			Name:      syncer.Spec.SourceConfigMap,
+			Namespace: syncer.Spec.SourceNamespace,
+		}, configMap); err != nil {
+			return nil, err
 		}
+		return []corev1.ConfigMap{*configMap}, nil
+	}
 
-		// Check if ConfigMap already exists
-		existing := &corev1.ConfigMap{}
-		err := r.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: targetNS}, existing)
+	selector, err := metav1.LabelSelectorAsSelector(syncer.Spec.SourceSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	configMapList := &corev1.ConfigMapList{}
+	if err := r.List(ctx, configMapList, client.InNamespace(syncer.Spec.SourceNamespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	return configMapList.Items, nil
+}
+
+// syncedSourceNames returns the names of every source ConfigMap this syncer manages
+func syncedSourceNames(syncer *configv1alpha1.ConfigMapSyncer) []string {
+	if syncer.Spec.SourceSelector == nil {
+		return []string{syncer.Spec.SourceConfigMap}
+	}
+
+	names := make([]string, 0, len(syncer.Status.Sources))
+	for _, source := range syncer.Status.Sources {
+		names = append(names, source.Name)
+	}
+	return names
+}
+
+// sourceStatus returns the recorded sync result of the named source, if any
+func sourceStatus(syncer *configv1alpha1.ConfigMapSyncer, name string) *configv1alpha1.SourceSyncStatus {
+	for i := range syncer.Status.Sources {
+		if syncer.Status.Sources[i].Name == name {
+			return &syncer.Status.Sources[i]
+		}
+	}
+	return nil
+}
+
+// unchangedSource returns the recorded status of source when
+// SyncOnlyIfChanged lets it be skipped: the syncer spec was synced, the source
+// was last synced to every target at its current resourceVersion, no resync
+// is due and every copy still exists with the labels and annotations this
+// controller writes, so deleted copies are still recreated and copies are
+// relabeled after the label or annotation prefix changes
+func (r *ConfigMapSyncerReconciler) unchangedSource(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap, now time.Time) *configv1alpha1.SourceSyncStatus {
+	if !syncer.Spec.SyncOnlyIfChanged || syncer.Status.ObservedGeneration != syncer.Generation {
+		return nil
+	}
 
-		if err != nil && errors.IsNotFound(err) {
-			// Create new ConfigMap
-			if err := r.Create(ctx, target); err != nil {
-				log.Error(err, "Failed to create ConfigMap", "namespace", targetNS, "name", target.Name)
+	previous := sourceStatus(syncer, source.Name)
+	if previous == nil || previous.ObservedResourceVersion != source.ResourceVersion ||
+		previous.LastFullSyncTime == nil || len(previous.FailedNamespaces) > 0 {
+		return nil
+	}
+	if interval := syncer.Spec.ResyncInterval; interval != nil && interval.Duration > 0 &&
+		!now.Before(previous.LastFullSyncTime.Add(interval.Duration)) {
+		return nil
+	}
+
+	// Existence is checked against the cache, so this costs no API calls
+	for _, ns := range previous.SyncedNamespaces {
+		target := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: source.Name, Namespace: ns}, target); err != nil {
+			return nil
+		}
+		// The content revision isn't compared with the source: it stays put
+		// across metadata-only edits, which the observed resourceVersion covers
+		if _, ok := target.Annotations[r.annotationKey(sourceContentRevisionAnnotation)]; !ok ||
+			!r.writtenBy(syncer, target) {
+			return nil
+		}
+		if _, ok := target.Labels[r.syncedByNamespaceLabel()]; !ok {
+			return nil
+		}
+	}
+	return previous
+}
+
+// nextResync returns when the first source synced to every target is due
+// for a resync under SyncOnlyIfChanged, or zero if none is
+func nextResync(syncer *configv1alpha1.ConfigMapSyncer) time.Time {
+	if !syncer.Spec.SyncOnlyIfChanged || syncer.Spec.ResyncInterval == nil || syncer.Spec.ResyncInterval.Duration <= 0 {
+		return time.Time{}
+	}
+
+	var next time.Time
+	for _, source := range syncer.Status.Sources {
+		if source.LastFullSyncTime == nil {
+			continue
+		}
+		due := source.LastFullSyncTime.Add(syncer.Spec.ResyncInterval.Duration)
+		if next.IsZero() || due.Before(next) {
+			next = due
+		}
+	}
+	return next
+}
+
+// aggregateNamespaces folds per-source results into overall lists. A namespace
+// counts as synced only if no source failed to sync to it.
+func aggregateNamespaces(sources []configv1alpha1.SourceSyncStatus) ([]string, []string) {
+	failedSet := make(map[string]bool)
+	var failed []string
+	for _, source := range sources {
+		for _, ns := range source.FailedNamespaces {
+			if !failedSet[ns] {
+				failedSet[ns] = true
+				failed = append(failed, ns)
+			}
+		}
+	}
+
+	syncedSet := make(map[string]bool)
+	var synced []string
+	for _, source := range sources {
+		for _, ns := range source.SyncedNamespaces {
+			if !failedSet[ns] && !syncedSet[ns] {
+				syncedSet[ns] = true
+				synced = append(synced, ns)
+			}
+		}
+	}
+
+	return synced, failed
+}
+
+// syncToTargets syncs the source ConfigMap to all target namespaces, one
+// priority group after another, writing to at most MaxConcurrentWrites
+// namespaces at a time. It also returns the namespaces whose adopted target
+// held a different type of data than the source.
+func (r *ConfigMapSyncerReconciler) syncToTargets(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap) ([]string, []string, []string, error) {
+	log := log.FromContext(ctx)
+
+	var syncedNamespaces []string
+	var failedNamespaces []string
+	var mismatchedNamespaces []string
+
+	groups := priorityGroups(syncer)
+	for i, group := range groups {
+		synced, failed, mismatched := r.syncGroup(ctx, syncer, source, group.Namespaces)
+		syncedNamespaces = append(syncedNamespaces, synced...)
+		failedNamespaces = append(failedNamespaces, failed...)
+		mismatchedNamespaces = append(mismatchedNamespaces, mismatched...)
+
+		// Lower priorities wait for the next attempt rather than running ahead
+		if len(failed) > 0 && group.AbortOnFailure {
+			for _, rest := range groups[i+1:] {
+				failedNamespaces = append(failedNamespaces, rest.Namespaces...)
+			}
+			log.Info("Aborting lower-priority namespaces after failure", "source", source.Name, "failed", failed)
+			break
+		}
+	}
+
+	// Keep status stable regardless of completion order
+	sort.Strings(syncedNamespaces)
+	sort.Strings(failedNamespaces)
+	sort.Strings(mismatchedNamespaces)
+
+	return syncedNamespaces, failedNamespaces, mismatchedNamespaces, nil
+}
+
+// syncGroup syncs the source ConfigMap to the given namespaces concurrently
+func (r *ConfigMapSyncerReconciler) syncGroup(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap, namespaces []string) ([]string, []string, []string) {
+	var syncedNamespaces []string
+	var failedNamespaces []string
+	var mismatchedNamespaces []string
+
+	maxConcurrentWrites := int(syncer.Spec.MaxConcurrentWrites)
+	if maxConcurrentWrites <= 0 {
+		maxConcurrentWrites = defaultMaxConcurrentWrites
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, maxConcurrentWrites)
+	)
+
+	for _, targetNS := range namespaces {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			mismatched, err := r.syncToNamespace(ctx, syncer, source, targetNS)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if mismatched {
+				mismatchedNamespaces = append(mismatchedNamespaces, targetNS)
+			}
+			if err != nil {
 				failedNamespaces = append(failedNamespaces, targetNS)
-				continue
+			} else {
+				syncedNamespaces = append(syncedNamespaces, targetNS)
 			}
-			log.Info("Created ConfigMap", "namespace", targetNS, "name", target.Name)
-			syncedNamespaces = append(syncedNamespaces, targetNS)
-		} else if err != nil {
-			log.Error(err, "Failed to get ConfigMap", "namespace", targetNS, "name", target.Name)
-			failedNamespaces = append(failedNamespaces, targetNS)
+		}()
+	}
+	wg.Wait()
+
+	return syncedNamespaces, failedNamespaces, mismatchedNamespaces
+}
+
+// priorityGroups splits the target namespaces into the order they are synced
+// in. Namespaces without a priority form a final group.
+func priorityGroups(syncer *configv1alpha1.ConfigMapSyncer) []configv1alpha1.SyncPriorityGroup {
+	groups := make([]configv1alpha1.SyncPriorityGroup, 0, len(syncer.Spec.SyncPriority)+1)
+	prioritized := make(map[string]bool)
+	for _, group := range syncer.Spec.SyncPriority {
+		groups = append(groups, group)
+		for _, ns := range group.Namespaces {
+			prioritized[ns] = true
+		}
+	}
+
+	var rest []string
+	for _, ns := range syncer.Spec.TargetNamespaces {
+		if !prioritized[ns] {
+			rest = append(rest, ns)
+		}
+	}
+	if len(rest) > 0 {
+		groups = append(groups, configv1alpha1.SyncPriorityGroup{Namespaces: rest})
+	}
+	return groups
+}
+
+// syncToNamespace creates or updates the copy of the source ConfigMap in a
+// single target namespace, reporting whether it adopted a target holding a
+// different type of data than the source
+func (r *ConfigMapSyncerReconciler) syncToNamespace(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap, targetNS string) (bool, error) {
+	log := log.FromContext(ctx)
+
+	// Check if target namespace exists
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: targetNS}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("Target namespace not found, skipping", "namespace", targetNS)
+			return false, err
+		}
+		log.Error(err, "Failed to check namespace", "namespace", targetNS)
+		return false, err
+	}
+
+	// Tombstoned keys are deleted from the target rather than written
+	tombstones := tombstonedKeys(source.Data)
+	if len(tombstones) > 0 {
+		source = source.DeepCopy()
+		for _, key := range tombstones {
+			delete(source.Data, key)
+		}
+	}
+
+	// Create target ConfigMap
+	target := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: targetNS,
+			Labels: map[string]string{
+				r.syncedByLabel():          syncer.Name,
+				r.syncedByNamespaceLabel(): syncer.Namespace,
+				r.syncedFromLabel():        syncer.Spec.SourceNamespace,
+			},
+			Annotations: map[string]string{
+				r.annotationKey("source-namespace"):              syncer.Spec.SourceNamespace,
+				r.annotationKey("syncer-name"):                   syncer.Name,
+				r.annotationKey(sourceContentRevisionAnnotation): source.ResourceVersion,
+				r.annotationKey(sourceUIDAnnotation):             string(source.UID),
+			},
+		},
+		BinaryData: source.BinaryData,
+	}
+
+	data, err := targetData(syncer, source)
+	if err != nil {
+		log.Error(err, "Failed to render target data", "namespace", targetNS, "name", target.Name)
+		return false, err
+	}
+	target.Data = data
+
+	// Check if ConfigMap already exists
+	existing := &corev1.ConfigMap{}
+	err = r.Get(ctx, types.NamespacedName{Name: target.Name, Namespace: targetNS}, existing)
+
+	// Layer structured source values over what the target already holds
+	var mergeErr error
+	if err == nil && syncer.Spec.MergeStrategy != "" && syncer.Spec.MergeStrategy != mergeReplace {
+		target.Data, mergeErr = mergeStructuredData(syncer.Spec.MergeStrategy, existing.Data, target.Data)
+		if mergeErr != nil {
+			log.Error(mergeErr, "Failed to merge keys, keeping their target values", "namespace", targetNS, "name", target.Name)
+		}
+	}
+
+	// Only manage owned keys, leaving the rest of the target to local owners
+	if len(syncer.Spec.OwnedKeys) > 0 {
+		var existingData map[string]string
+		var existingBinaryData map[string][]byte
+		if err == nil {
+			existingData, existingBinaryData = existing.Data, existing.BinaryData
+		}
+		target.Data = mergeOwnedKeys(syncer.Spec.OwnedKeys, existingData, target.Data)
+		target.BinaryData = mergeOwnedKeys(syncer.Spec.OwnedKeys, existingBinaryData, target.BinaryData)
+	}
+
+	// Tombstones win over merging, but never reach keys other owners manage
+	for _, key := range tombstones {
+		if len(syncer.Spec.OwnedKeys) > 0 && !slices.Contains(syncer.Spec.OwnedKeys, key) {
+			continue
+		}
+		delete(target.Data, key)
+		delete(target.BinaryData, key)
+	}
+
+	// Remember what was written so later edits to the target stand out from source changes
+	target.Annotations[r.annotationKey(contentHashAnnotation)] = syncedContentHash(syncer, target.Data, target.BinaryData)
+
+	if err != nil && errors.IsNotFound(err) {
+		// Create new ConfigMap
+		if err := r.Create(ctx, target); err != nil {
+			log.Error(err, "Failed to create ConfigMap", "namespace", targetNS, "name", target.Name)
+			return false, err
+		}
+		driftDetected.WithLabelValues(targetNS, target.Name).Set(0)
+		log.Info("Created ConfigMap", "namespace", targetNS, "name", target.Name)
+		return false, nil
+	} else if err != nil {
+		log.Error(err, "Failed to get ConfigMap", "namespace", targetNS, "name", target.Name)
+		return false, err
+	}
+
+	// Writing binary data over a text target, or the reverse, can break its
+	// consumers, so call it out when adopting a target the syncer didn't write
+	mismatched := !r.writtenBy(syncer, existing) && dataTypesDiffer(source, existing)
+	if mismatched {
+		r.recordTypeMismatch(ctx, syncer, source, existing)
+	}
+
+	// A target that no longer matches the last write was changed out of band
+	if recorded, ok := existing.Annotations[r.annotationKey(contentHashAnnotation)]; ok &&
+		recorded != syncedContentHash(syncer, existing.Data, existing.BinaryData) {
+		log.Info("Target ConfigMap drifted since the last sync, correcting it", "namespace", targetNS, "name", target.Name)
+		driftDetected.WithLabelValues(targetNS, target.Name).Set(1)
+	} else {
+		driftDetected.WithLabelValues(targetNS, target.Name).Set(0)
+	}
+
+	// Skip no-op writes so unchanged ConfigMaps do not churn
+	if contentHash(existing.Data, existing.BinaryData) == contentHash(target.Data, target.BinaryData) &&
+		maps.Equal(existing.Labels, target.Labels) &&
+		r.annotationsMatch(existing.Annotations, target.Annotations) {
+		log.V(1).Info("ConfigMap up to date", "namespace", targetNS, "name", target.Name)
+		return mismatched, mergeErr
+	}
+
+	// Update existing ConfigMap
+	existing.Data = target.Data
+	existing.BinaryData = target.BinaryData
+	existing.Labels = target.Labels
+	existing.Annotations = target.Annotations
+
+	if err := r.Update(ctx, existing); err != nil {
+		log.Error(err, "Failed to update ConfigMap", "namespace", targetNS, "name", target.Name)
+		return mismatched, err
+	}
+	log.Info("Updated ConfigMap", "namespace", targetNS, "name", target.Name)
+	return mismatched, mergeErr
+}
+
+// annotationsMatch compares a target's annotations with the desired ones. A
+// recorded content revision counts as matching whatever its value, since the
+// source's resourceVersion changes with every edit, including ones that leave
+// the synced content alone. It is only rewritten along with the content.
+func (r *ConfigMapSyncerReconciler) annotationsMatch(existing, desired map[string]string) bool {
+	key := r.annotationKey(sourceContentRevisionAnnotation)
+	if _, ok := existing[key]; !ok {
+		return maps.Equal(existing, desired)
+	}
+
+	existing, desired = maps.Clone(existing), maps.Clone(desired)
+	delete(existing, key)
+	delete(desired, key)
+	return maps.Equal(existing, desired)
+}
+
+// dataType names the kind of content a ConfigMap holds: text, binary, mixed,
+// or empty when it holds no keys
+func dataType(cm *corev1.ConfigMap) string {
+	switch {
+	case len(cm.Data) > 0 && len(cm.BinaryData) > 0:
+		return "mixed"
+	case len(cm.Data) > 0:
+		return "text"
+	case len(cm.BinaryData) > 0:
+		return "binary"
+	default:
+		return ""
+	}
+}
+
+// dataTypesDiffer reports whether one ConfigMap holds only text data and the
+// other only binary data
+func dataTypesDiffer(source, target *corev1.ConfigMap) bool {
+	sourceType, targetType := dataType(source), dataType(target)
+	return (sourceType == "text" && targetType == "binary") || (sourceType == "binary" && targetType == "text")
+}
+
+// recordTypeMismatch logs and emits a warning event for an adopted target
+// whose data type differs from the source
+func (r *ConfigMapSyncerReconciler) recordTypeMismatch(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source, target *corev1.ConfigMap) {
+	outcome := "its data is replaced by the source"
+	if len(syncer.Spec.OwnedKeys) > 0 {
+		outcome = "only the owned keys are replaced"
+	}
+	message := fmt.Sprintf("Target ConfigMap %s/%s holds %s data but source %s/%s holds %s data; %s",
+		target.Namespace, target.Name, dataType(target), source.Namespace, source.Name, dataType(source), outcome)
+
+	log.FromContext(ctx).Info("Adopting target ConfigMap with a different data type", "namespace", target.Namespace,
+		"name", target.Name, "targetType", dataType(target), "sourceType", dataType(source))
+	if r.Recorder != nil {
+		r.event(syncer, corev1.EventTypeWarning, "TypeMismatch", message)
+	}
+}
+
+// updateTypeMismatchCondition reports the adopted targets, as namespace/name,
+// whose data type differed from the source during the last sync
+func (r *ConfigMapSyncerReconciler) updateTypeMismatchCondition(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, targets []string) {
+	condition := metav1.Condition{
+		Type:               "TypeMismatch",
+		Status:             metav1.ConditionFalse,
+		Reason:             "TypesMatch",
+		Message:            "No adopted target held a different type of data than its source",
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(targets) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "TargetTypeDiffers"
+		condition.Message = fmt.Sprintf("Adopted target ConfigMap(s) %s held text data where the source holds binary data, or the reverse",
+			strings.Join(targets, ", "))
+	}
+	r.updateStatusCondition(ctx, syncer, condition)
+}
+
+// maxDataBytes returns the largest source size the syncer copies to targets
+func maxDataBytes(syncer *configv1alpha1.ConfigMapSyncer) int64 {
+	if syncer.Spec.MaxDataBytes <= 0 {
+		return defaultMaxDataBytes
+	}
+	return syncer.Spec.MaxDataBytes
+}
+
+// dataSize counts the bytes in the keys and values of a ConfigMap's Data and BinaryData
+func dataSize(cm *corev1.ConfigMap) int64 {
+	var size int64
+	for key, value := range cm.Data {
+		size += int64(len(key) + len(value))
+	}
+	for key, value := range cm.BinaryData {
+		size += int64(len(key) + len(value))
+	}
+	return size
+}
+
+// recordSourceTooLarge logs and emits a warning event for a source that
+// exceeds maxDataBytes and is therefore not synced
+func (r *ConfigMapSyncerReconciler) recordSourceTooLarge(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap, size int64) {
+	message := fmt.Sprintf("Source ConfigMap %s/%s holds %d bytes, more than maxDataBytes %d; it is not synced",
+		source.Namespace, source.Name, size, maxDataBytes(syncer))
+
+	log.FromContext(ctx).Info("Source ConfigMap too large, skipping sync", "namespace", source.Namespace,
+		"name", source.Name, "size", size, "maxDataBytes", maxDataBytes(syncer))
+	if r.Recorder != nil {
+		r.event(syncer, corev1.EventTypeWarning, "SourceTooLarge", message)
+	}
+}
+
+// updateSourceTooLargeCondition reports the sources that were not synced
+// because they exceed maxDataBytes
+func (r *ConfigMapSyncerReconciler) updateSourceTooLargeCondition(ctx context.Context, syncer *configv1alpha1.ConfigMapSyncer, sources []string) {
+	condition := metav1.Condition{
+		Type:               "SourceTooLarge",
+		Status:             metav1.ConditionFalse,
+		Reason:             "WithinLimit",
+		Message:            fmt.Sprintf("All sources are within maxDataBytes %d", maxDataBytes(syncer)),
+		LastTransitionTime: metav1.Now(),
+	}
+	if len(sources) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ExceedsMaxDataBytes"
+		condition.Message = fmt.Sprintf("Source ConfigMap(s) %s exceed maxDataBytes %d and were not synced",
+			strings.Join(sources, ", "), maxDataBytes(syncer))
+	}
+	r.updateStatusCondition(ctx, syncer, condition)
+}
+
+// mergeStructuredData merges each desired value into the existing value of
+// the same key using strategy. Keys the target does not hold yet are taken
+// as is. A key whose values are not valid JSON keeps its existing value and
+// is reported in the returned error; the other keys are still merged.
+func mergeStructuredData(strategy string, existing, desired map[string]string) (map[string]string, error) {
+	merged := make(map[string]string, len(desired))
+	var errs []error
+	for key, value := range desired {
+		current, ok := existing[key]
+		if !ok {
+			merged[key] = value
+			continue
+		}
+
+		var base, patch any
+		if err := json.Unmarshal([]byte(current), &base); err != nil {
+			errs = append(errs, fmt.Errorf("key %q: target value is not valid JSON: %w", key, err))
+			merged[key] = current
 			continue
+		}
+		if err := json.Unmarshal([]byte(value), &patch); err != nil {
+			errs = append(errs, fmt.Errorf("key %q: source value is not valid JSON: %w", key, err))
+			merged[key] = current
+			continue
+		}
+
+		mergedValue, err := mergeValue(strategy, base, patch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %q: %w", key, err))
+			merged[key] = current
+			continue
+		}
+		out, err := json.Marshal(mergedValue)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %q: %w", key, err))
+			merged[key] = current
+			continue
+		}
+		merged[key] = string(out)
+	}
+	return merged, goerrors.Join(errs...)
+}
+
+// mergeValue combines a target value with a source value. strategicPatch
+// applies the source object as a Kubernetes strategic merge patch; anything
+// else, and values that aren't both objects, follows RFC 7386.
+func mergeValue(strategy string, base, patch any) (any, error) {
+	baseMap, baseIsMap := base.(map[string]any)
+	patchMap, patchIsMap := patch.(map[string]any)
+	if strategy == mergeStrategicPatch && baseIsMap && patchIsMap {
+		return strategicpatch.StrategicMergeMapPatchUsingLookupPatchMeta(baseMap, patchMap, nameMergeKeySchema{})
+	}
+	return mergeJSON(base, patch), nil
+}
+
+// nameMergeKeySchema is the patch metadata for values without a schema:
+// every list is merged, lists of objects by their "name" field the way
+// Kubernetes merges containers. Directives such as $patch: replace work as
+// they do with kubectl.
+type nameMergeKeySchema struct{}
+
+func (s nameMergeKeySchema) LookupPatchMetadataForStruct(string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	return s, strategicpatch.PatchMeta{}, nil
+}
+
+func (s nameMergeKeySchema) LookupPatchMetadataForSlice(string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	var meta strategicpatch.PatchMeta
+	meta.SetPatchStrategies([]string{"merge"})
+	meta.SetPatchMergeKey("name")
+	return s, meta, nil
+}
+
+func (nameMergeKeySchema) Name() string {
+	return "value"
+}
+
+// mergeJSON applies patch to base following RFC 7386: objects are merged
+// recursively, null removes a key and anything else replaces the base value
+func mergeJSON(base, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	baseMap, ok := base.(map[string]any)
+	if !ok {
+		baseMap = map[string]any{}
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(baseMap, key)
+			continue
+		}
+		baseMap[key] = mergeJSON(baseMap[key], value)
+	}
+	return baseMap
+}
+
+// tombstonedKeys returns the source keys whose value is the tombstone, sorted
+func tombstonedKeys(data map[string]string) []string {
+	var keys []string
+	for key, value := range data {
+		if value == tombstoneValue {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeOwnedKeys returns existing with every owned key replaced by its
+// value in desired, or removed when desired lacks it. Keys that are not
+// owned keep their existing values.
+func mergeOwnedKeys[V any](owned []string, existing, desired map[string]V) map[string]V {
+	merged := make(map[string]V, len(existing)+len(owned))
+	for key, value := range existing {
+		merged[key] = value
+	}
+	for _, key := range owned {
+		if value, ok := desired[key]; ok {
+			merged[key] = value
 		} else {
-			// Update existing ConfigMap
-			existing.Data = target.Data
-			existing.BinaryData = target.BinaryData
-			existing.Labels = target.Labels
-			existing.Annotations = target.Annotations
-
-			if err := r.Update(ctx, existing); err != nil {
-				log.Error(err, "Failed to update ConfigMap", "namespace", targetNS, "name", target.Name)
-				failedNamespaces = append(failedNamespaces, targetNS)
-				continue
-			}
-			log.Info("Updated ConfigMap", "namespace", targetNS, "name", target.Name)
-			syncedNamespaces = append(syncedNamespaces, targetNS)
+			delete(merged, key)
+		}
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// syncedContentHash hashes the part of a target the syncer manages: every
+// key, or only the owned keys when OwnedKeys is set
+func syncedContentHash(syncer *configv1alpha1.ConfigMapSyncer, data map[string]string, binaryData map[string][]byte) string {
+	if len(syncer.Spec.OwnedKeys) == 0 {
+		return contentHash(data, binaryData)
+	}
+
+	ownedData := make(map[string]string)
+	ownedBinaryData := make(map[string][]byte)
+	for _, key := range syncer.Spec.OwnedKeys {
+		if value, ok := data[key]; ok {
+			ownedData[key] = value
 		}
+		if value, ok := binaryData[key]; ok {
+			ownedBinaryData[key] = value
+		}
+	}
+	return contentHash(ownedData, ownedBinaryData)
+}
+
+// contentHash returns a canonical digest of a ConfigMap's Data and BinaryData.
+// Keys are sorted and every key and value is length-prefixed, so identical
+// content hashes the same regardless of map order or byte content.
+func contentHash(data map[string]string, binaryData map[string][]byte) string {
+	h := sha256.New()
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	writeHashField(h, []byte("data"))
+	for _, key := range keys {
+		writeHashField(h, []byte(key))
+		writeHashField(h, []byte(data[key]))
+	}
+
+	keys = keys[:0]
+	for key := range binaryData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	writeHashField(h, []byte("binaryData"))
+	for _, key := range keys {
+		writeHashField(h, []byte(key))
+		writeHashField(h, binaryData[key])
 	}
 
-	return syncedNamespaces, failedNamespaces, nil
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeHashField writes b to h prefixed with its length
+func writeHashField(h hash.Hash, b []byte) {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(b)))
+	h.Write(length[:])
+	h.Write(b)
+}
+
+// syncWindowOpen reports whether any sync window is open at now and, if none
+// is, when the next one opens
+func syncWindowOpen(windows []configv1alpha1.SyncWindow, now time.Time) (bool, time.Time, error) {
+	var nextOpen time.Time
+	for _, window := range windows {
+		schedule, err := cron.ParseStandard(window.Schedule)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid sync window schedule %q: %w", window.Schedule, err)
+		}
+		if window.Duration.Duration <= 0 {
+			return false, time.Time{}, fmt.Errorf("sync window %q must have a positive duration", window.Schedule)
+		}
+
+		// The window is open if it last opened within the past duration
+		if !schedule.Next(now.Add(-window.Duration.Duration)).After(now) {
+			return true, time.Time{}, nil
+		}
+
+		if next := schedule.Next(now); nextOpen.IsZero() || next.Before(nextOpen) {
+			nextOpen = next
+		}
+	}
+
+	return false, nextOpen, nil
+}
+
+// targetData returns the data to write to target ConfigMaps, flattening the
+// source keys into a single key when FlattenInto is set
+func targetData(syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap) (map[string]string, error) {
+	if syncer.Spec.FlattenInto == "" {
+		return source.Data, nil
+	}
+
+	var rendered string
+	switch syncer.Spec.FlattenFormat {
+	case "json":
+		// encoding/json sorts map keys, keeping the output stable
+		out, err := json.Marshal(source.Data)
+		if err != nil {
+			return nil, err
+		}
+		rendered = string(out)
+	default:
+		keys := make([]string, 0, len(source.Data))
+		for key := range source.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		var b strings.Builder
+		escaper := strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s=%s\n", key, escaper.Replace(source.Data[key]))
+		}
+		rendered = b.String()
+	}
+
+	return map[string]string{syncer.Spec.FlattenInto: rendered}, nil
 }
 
 // updateStatusCondition updates or adds a condition to the status
@@ -265,17 +1399,56 @@ func (r *ConfigMapSyncerReconciler) updateStatusCondition(ctx context.Context, s
 	}
 }
 
-// findSyncersForConfigMap maps ConfigMap changes to ConfigMapSyncer reconciliations
+// paused reports whether the pause ConfigMap exists
+func (r *ConfigMapSyncerReconciler) paused(ctx context.Context) (bool, error) {
+	if r.PauseConfigMap.Name == "" {
+		return false, nil
+	}
+	err := r.Get(ctx, r.PauseConfigMap, &corev1.ConfigMap{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// findSyncersForConfigMap maps ConfigMap changes to ConfigMapSyncer and
+// ClusterConfigMapSyncer reconciliations. Creating or deleting the pause ConfigMap enqueues every syncer.
 func (r *ConfigMapSyncerReconciler) findSyncersForConfigMap(ctx context.Context, cm client.Object) []reconcile.Request {
-	syncers := &configv1alpha1.ConfigMapSyncerList{}
-	if err := r.List(ctx, syncers); err != nil {
+	syncers, err := r.listSyncers(ctx)
+	if err != nil {
+		return []reconcile.Request{}
+	}
+
+	isPauseConfigMap := r.PauseConfigMap.Name != "" && client.ObjectKeyFromObject(cm) == r.PauseConfigMap
+
+	var requests []reconcile.Request
+	for _, syncer := range syncers {
+		if isPauseConfigMap || (syncer.Spec.SourceNamespace == cm.GetNamespace() &&
+			sourceMatches(&syncer, cm)) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      syncer.Name,
+					Namespace: syncer.Namespace,
+				},
+			})
+		}
+	}
+
+	return requests
+}
+
+// findSyncersForDeletedTarget maps a deleted target copy back to the syncer
+// that wrote it, identified by its synced-by, synced-by-namespace and
+// synced-from labels
+func (r *ConfigMapSyncerReconciler) findSyncersForDeletedTarget(ctx context.Context, cm client.Object) []reconcile.Request {
+	syncers, err := r.listSyncers(ctx)
+	if err != nil {
 		return []reconcile.Request{}
 	}
 
 	var requests []reconcile.Request
-	for _, syncer := range syncers.Items {
-		if syncer.Spec.SourceNamespace == cm.GetNamespace() &&
-			syncer.Spec.SourceConfigMap == cm.GetName() {
+	for _, syncer := range syncers {
+		if r.writtenBy(&syncer, cm) && syncer.Spec.SourceNamespace == cm.GetLabels()[r.syncedFromLabel()] {
 			requests = append(requests, reconcile.Request{
 				NamespacedName: types.NamespacedName{
 					Name:      syncer.Name,
@@ -288,13 +1461,95 @@ func (r *ConfigMapSyncerReconciler) findSyncersForConfigMap(ctx context.Context,
 	return requests
 }
 
+// syncedByLabel names the label recording which syncer wrote a target copy
+func (r *ConfigMapSyncerReconciler) syncedByLabel() string {
+	return r.LabelPrefix + "synced-by"
+}
+
+// syncedByNamespaceLabel names the label recording the namespace of the
+// syncer that wrote a target copy, empty for a ClusterConfigMapSyncer
+func (r *ConfigMapSyncerReconciler) syncedByNamespaceLabel() string {
+	return r.LabelPrefix + "synced-by-namespace"
+}
+
+// writtenBy reports whether a target copy was written by syncer. The name
+// alone is ambiguous: syncers in different namespaces, or a namespaced and a
+// cluster syncer, may share it. Copies written before the namespace was
+// recorded go by the name until their next sync adds it.
+func (r *ConfigMapSyncerReconciler) writtenBy(syncer *configv1alpha1.ConfigMapSyncer, target client.Object) bool {
+	labels := target.GetLabels()
+	namespace, ok := labels[r.syncedByNamespaceLabel()]
+	return labels[r.syncedByLabel()] == syncer.Name && (!ok || namespace == syncer.Namespace)
+}
+
+// syncedFromLabel names the label recording a target copy's source namespace
+func (r *ConfigMapSyncerReconciler) syncedFromLabel() string {
+	return r.LabelPrefix + "synced-from"
+}
+
+// annotationKey returns the full key of an annotation written on target copies
+func (r *ConfigMapSyncerReconciler) annotationKey(name string) string {
+	if r.AnnotationPrefix == "" {
+		return defaultAnnotationPrefix + name
+	}
+	return r.AnnotationPrefix + name
+}
+
+// ValidateKeyPrefix checks that prefix forms valid label and annotation keys
+// with the names the syncer appends, the longest being "source-content-revision"
+func ValidateKeyPrefix(prefix string) error {
+	if errs := validation.IsQualifiedName(prefix + "source-content-revision"); len(errs) > 0 {
+		return fmt.Errorf("prefix %q does not form a valid key: %s", prefix, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// sourceMatches reports whether a ConfigMap is a source of the given syncer
+func sourceMatches(syncer *configv1alpha1.ConfigMapSyncer, cm client.Object) bool {
+	if syncer.Spec.SourceSelector == nil {
+		return syncer.Spec.SourceConfigMap == cm.GetName()
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(syncer.Spec.SourceSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(cm.GetLabels()))
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ConfigMapSyncerReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Status and metadata-only updates don't change the generation, so skip
+	// them; source ConfigMap changes still arrive through the watch below
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&configv1alpha1.ConfigMapSyncer{}).
+		For(&configv1alpha1.ConfigMapSyncer{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		// Cluster syncers are enqueued without a namespace, which Reconcile
+		// tells apart from the namespaced kind
+		Watches(
+			&configv1alpha1.ClusterConfigMapSyncer{},
+			&handler.EnqueueRequestForObject{},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		// Updates are mapped for both the old and the new object, so a source
+		// whose labels stop matching a selector still enqueues its syncer
 		Watches(
 			&corev1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(r.findSyncersForConfigMap),
 		).
+		// Recreate copies deleted from target namespaces. Only deletes are
+		// watched, so the syncer's own writes never enqueue it again.
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.findSyncersForDeletedTarget),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+				DeleteFunc: func(e event.DeleteEvent) bool {
+					_, ok := e.Object.GetLabels()[r.syncedByLabel()]
+					return ok
+				},
+			}),
+		).
 		Complete(r)
 }
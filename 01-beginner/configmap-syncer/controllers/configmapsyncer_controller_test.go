@@ -0,0 +1,868 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	configv1alpha1 "github.com/nutcas3/configmap-syncer/api/v1alpha1"
+)
+
+// newTestReconciler returns a ConfigMapSyncerReconciler backed by a fake
+// client holding objs
+func newTestReconciler(t *testing.T, objs ...client.Object) *ConfigMapSyncerReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := configv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &ConfigMapSyncerReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&configv1alpha1.ConfigMapSyncer{}, &configv1alpha1.ClusterConfigMapSyncer{}).
+			Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+func namespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+func configMap(namespace, name string, data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Data:       data,
+	}
+}
+
+func syncer(name string, spec configv1alpha1.ConfigMapSyncerSpec) *configv1alpha1.ConfigMapSyncer {
+	return &configv1alpha1.ConfigMapSyncer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Generation: 1},
+		Spec:       spec,
+	}
+}
+
+// reconcileSyncer runs one reconciliation of the named syncer in default and
+// returns the syncer as stored afterwards
+func reconcileSyncer(t *testing.T, r *ConfigMapSyncerReconciler, name string) (ctrl.Result, *configv1alpha1.ConfigMapSyncer) {
+	t.Helper()
+	ctx := context.Background()
+	key := types.NamespacedName{Name: name, Namespace: "default"}
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	stored := &configv1alpha1.ConfigMapSyncer{}
+	if err := r.Get(ctx, key, stored); err != nil {
+		t.Fatal(err)
+	}
+	return result, stored
+}
+
+// getConfigMap returns the named ConfigMap, or nil when it doesn't exist
+func getConfigMap(t *testing.T, r *ConfigMapSyncerReconciler, namespace, name string) *corev1.ConfigMap {
+	t.Helper()
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: namespace}, cm); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			t.Fatal(err)
+		}
+		return nil
+	}
+	return cm
+}
+
+func TestSyncWritesEveryTargetRegardlessOfConcurrency(t *testing.T) {
+	var targets []string
+	for i := range 12 {
+		targets = append(targets, fmt.Sprintf("team-%02d", i))
+	}
+
+	for _, maxConcurrentWrites := range []int32{0, 1, 3, 50} {
+		t.Run(fmt.Sprintf("maxConcurrentWrites=%d", maxConcurrentWrites), func(t *testing.T) {
+			objs := []client.Object{
+				namespace("default"),
+				configMap("default", "app-config", map[string]string{"level": "info"}),
+				syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+					SourceNamespace:     "default",
+					SourceConfigMap:     "app-config",
+					TargetNamespaces:    targets,
+					MaxConcurrentWrites: maxConcurrentWrites,
+				}),
+			}
+			for _, ns := range targets {
+				objs = append(objs, namespace(ns))
+			}
+			r := newTestReconciler(t, objs...)
+
+			_, stored := reconcileSyncer(t, r, "app")
+
+			for _, ns := range targets {
+				target := getConfigMap(t, r, ns, "app-config")
+				if target == nil {
+					t.Errorf("no copy in namespace %s", ns)
+					continue
+				}
+				if target.Data["level"] != "info" {
+					t.Errorf("copy in %s has data %v", ns, target.Data)
+				}
+			}
+			if !slices.Equal(stored.Status.SyncedNamespaces, targets) {
+				t.Errorf("status.syncedNamespaces = %v, want %v", stored.Status.SyncedNamespaces, targets)
+			}
+			if len(stored.Status.FailedNamespaces) != 0 {
+				t.Errorf("status.failedNamespaces = %v, want none", stored.Status.FailedNamespaces)
+			}
+		})
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	cert := []byte{0x30, 0x82, 0x01, 0x0a, 0x00, 0xff, 0xfe}
+	reordered := map[string][]byte{}
+	for _, key := range []string{"tls.crt", "ca.crt", "key.der"} {
+		reordered[key] = cert
+	}
+	ordered := map[string][]byte{}
+	for _, key := range []string{"ca.crt", "key.der", "tls.crt"} {
+		ordered[key] = slices.Clone(cert)
+	}
+
+	type content struct {
+		data       map[string]string
+		binaryData map[string][]byte
+	}
+	tests := []struct {
+		name      string
+		a, b      content
+		wantEqual bool
+	}{
+		{
+			name:      "binary maps built in different orders",
+			a:         content{binaryData: reordered},
+			b:         content{binaryData: ordered},
+			wantEqual: true,
+		},
+		{
+			name:      "data and binary data together",
+			a:         content{map[string]string{"a": "1", "b": "2"}, map[string][]byte{"blob": cert}},
+			b:         content{map[string]string{"b": "2", "a": "1"}, map[string][]byte{"blob": slices.Clone(cert)}},
+			wantEqual: true,
+		},
+		{
+			name:      "nil and empty maps",
+			a:         content{},
+			b:         content{map[string]string{}, map[string][]byte{}},
+			wantEqual: true,
+		},
+		{
+			name: "one byte differs",
+			a:    content{binaryData: map[string][]byte{"blob": cert}},
+			b:    content{binaryData: map[string][]byte{"blob": append(slices.Clone(cert[:len(cert)-1]), 0x00)}},
+		},
+		{
+			name: "same value as data and as binary data",
+			a:    content{data: map[string]string{"blob": "abc"}},
+			b:    content{binaryData: map[string][]byte{"blob": []byte("abc")}},
+		},
+		{
+			name: "boundary between key and value moves",
+			a:    content{data: map[string]string{"ab": "c"}},
+			b:    content{data: map[string]string{"a": "bc"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := contentHash(tt.a.data, tt.a.binaryData)
+			b := contentHash(tt.b.data, tt.b.binaryData)
+			if (a == b) != tt.wantEqual {
+				t.Errorf("contentHash() equal = %v, want %v (%s, %s)", a == b, tt.wantEqual, a, b)
+			}
+			if again := contentHash(tt.a.data, tt.a.binaryData); again != a {
+				t.Errorf("contentHash() is not stable: %s, then %s", a, again)
+			}
+		})
+	}
+}
+
+func TestObservedGenerationAdvancesWithSpecChange(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), namespace("team-b"),
+		configMap("default", "app-config", map[string]string{"level": "info"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:   "default",
+			SourceConfigMap:   "app-config",
+			TargetNamespaces:  []string{"team-a"},
+			SyncOnlyIfChanged: true,
+		}),
+	)
+
+	_, stored := reconcileSyncer(t, r, "app")
+	if stored.Status.ObservedGeneration != 1 {
+		t.Fatalf("status.observedGeneration = %d, want 1", stored.Status.ObservedGeneration)
+	}
+
+	// The API server bumps the generation on every spec change
+	stored.Spec.TargetNamespaces = []string{"team-a", "team-b"}
+	stored.Generation = 2
+	if err := r.Update(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stored = reconcileSyncer(t, r, "app")
+	if stored.Status.ObservedGeneration != 2 {
+		t.Errorf("status.observedGeneration = %d, want 2", stored.Status.ObservedGeneration)
+	}
+	// The source is unchanged, but the new spec must not be skipped
+	if getConfigMap(t, r, "team-b", "app-config") == nil {
+		t.Error("namespace added to the spec got no copy")
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{0, retryBaseDelay},
+		{1, retryBaseDelay},
+		{2, 2 * retryBaseDelay},
+		{3, 4 * retryBaseDelay},
+		{6, 32 * retryBaseDelay},
+		{7, retryMaxDelay},
+		{1000, retryMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestFailedNamespaceIsRetried(t *testing.T) {
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"),
+		configMap("default", "app-config", map[string]string{"level": "info"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a", "team-b"},
+		}),
+	)
+
+	result, stored := reconcileSyncer(t, r, "app")
+	if !slices.Equal(stored.Status.FailedNamespaces, []string{"team-b"}) {
+		t.Fatalf("status.failedNamespaces = %v, want [team-b]", stored.Status.FailedNamespaces)
+	}
+	if result.RequeueAfter != retryBaseDelay {
+		t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, retryBaseDelay)
+	}
+
+	// The namespace shows up before the retry
+	if err := r.Create(context.Background(), namespace("team-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, stored = reconcileSyncer(t, r, "app")
+	if getConfigMap(t, r, "team-b", "app-config") == nil {
+		t.Error("retry did not sync the new namespace")
+	}
+	if len(stored.Status.FailedNamespaces) != 0 || stored.Status.RetryAttempts != 0 {
+		t.Errorf("status.failedNamespaces = %v, retryAttempts = %d, want both cleared",
+			stored.Status.FailedNamespaces, stored.Status.RetryAttempts)
+	}
+	if result.RequeueAfter != 0 {
+		t.Errorf("RequeueAfter = %v after all targets succeeded, want none", result.RequeueAfter)
+	}
+}
+
+func TestMergeOwnedKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		owned    []string
+		existing map[string]string
+		desired  map[string]string
+		want     map[string]string
+	}{
+		{
+			name:     "owned key is updated",
+			owned:    []string{"level"},
+			existing: map[string]string{"level": "debug", "local": "kept"},
+			desired:  map[string]string{"level": "info"},
+			want:     map[string]string{"level": "info", "local": "kept"},
+		},
+		{
+			name:     "owned key missing from the source is removed",
+			owned:    []string{"level"},
+			existing: map[string]string{"level": "debug", "local": "kept"},
+			desired:  map[string]string{},
+			want:     map[string]string{"local": "kept"},
+		},
+		{
+			name:     "key that is not owned is not copied",
+			owned:    []string{"level"},
+			existing: nil,
+			desired:  map[string]string{"level": "info", "extra": "x"},
+			want:     map[string]string{"level": "info"},
+		},
+		{
+			name:     "nothing left",
+			owned:    []string{"level"},
+			existing: map[string]string{"level": "debug"},
+			desired:  nil,
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeOwnedKeys(tt.owned, tt.existing, tt.desired)
+			if !maps.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+				t.Errorf("mergeOwnedKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncKeepsKeysThatAreNotOwned(t *testing.T) {
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"),
+		configMap("default", "app-config", map[string]string{"level": "info", "extra": "x"}),
+		configMap("team-a", "app-config", map[string]string{"level": "debug", "local": "kept"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a"},
+			OwnedKeys:        []string{"level"},
+		}),
+	)
+
+	reconcileSyncer(t, r, "app")
+
+	want := map[string]string{"level": "info", "local": "kept"}
+	if target := getConfigMap(t, r, "team-a", "app-config"); target == nil || !maps.Equal(target.Data, want) {
+		t.Errorf("target = %v, want data %v", target, want)
+	}
+}
+
+func TestSameNamedSyncersKeepTheirCopiesApart(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), namespace("team-b"),
+		configMap("default", "app-config", map[string]string{"level": "info"}),
+		configMap("default", "db-config", map[string]string{"host": "db"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a"},
+		}),
+		&configv1alpha1.ClusterConfigMapSyncer{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Generation: 1},
+			Spec: configv1alpha1.ConfigMapSyncerSpec{
+				SourceNamespace:  "default",
+				SourceConfigMap:  "db-config",
+				TargetNamespaces: []string{"team-b"},
+			},
+		},
+	)
+	reconcileSyncer(t, r, "app")
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "app"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	namespaced := ctrl.Request{NamespacedName: types.NamespacedName{Name: "app", Namespace: "default"}}
+	cluster := ctrl.Request{NamespacedName: types.NamespacedName{Name: "app"}}
+	tests := []struct {
+		namespace, name string
+		want            ctrl.Request
+	}{
+		{"team-a", "app-config", namespaced},
+		{"team-b", "db-config", cluster},
+	}
+	for _, tt := range tests {
+		copied := getConfigMap(t, r, tt.namespace, tt.name)
+		if copied == nil {
+			t.Fatalf("%s/%s was not synced", tt.namespace, tt.name)
+		}
+		if got := r.findSyncersForDeletedTarget(ctx, copied); !slices.Equal(got, []ctrl.Request{tt.want}) {
+			t.Errorf("findSyncersForDeletedTarget(%s/%s) = %v, want [%v]", tt.namespace, tt.name, got, tt.want)
+		}
+	}
+
+	// A copy written before the namespace was recorded still goes by the name
+	legacy := configMap("team-a", "legacy", nil)
+	legacy.Labels = map[string]string{r.syncedByLabel(): "app", r.syncedFromLabel(): "default"}
+	if got := r.findSyncersForDeletedTarget(ctx, legacy); len(got) != 2 {
+		t.Errorf("findSyncersForDeletedTarget(legacy) = %v, want both syncers named app", got)
+	}
+}
+
+func TestPruningLeavesCopiesOfSameNamedClusterSyncer(t *testing.T) {
+	ctx := context.Background()
+	source := configMap("default", "shared", map[string]string{"level": "info"})
+	source.Labels = map[string]string{"sync": "true"}
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), source,
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceSelector:   &metav1.LabelSelector{MatchLabels: map[string]string{"sync": "true"}},
+			TargetNamespaces: []string{"team-a"},
+		}),
+	)
+	reconcileSyncer(t, r, "app")
+
+	// A cluster syncer also named app has since taken the copy over
+	copied := getConfigMap(t, r, "team-a", "shared")
+	copied.Labels[r.syncedByNamespaceLabel()] = ""
+	if err := r.Update(ctx, copied); err != nil {
+		t.Fatal(err)
+	}
+
+	source.Labels = nil
+	if err := r.Update(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSyncer(t, r, "app")
+	if getConfigMap(t, r, "team-a", "shared") == nil {
+		t.Error("pruning deleted the cluster syncer's copy")
+	}
+}
+
+func TestTombstonedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]string
+		want []string
+	}{
+		{name: "none", data: map[string]string{"level": "info"}},
+		{name: "nil data"},
+		{
+			name: "sorted",
+			data: map[string]string{"b": tombstoneValue, "level": "info", "a": tombstoneValue},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "only the exact sentinel",
+			data: map[string]string{"a": tombstoneValue + "x", "b": " " + tombstoneValue},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tombstonedKeys(tt.data); !slices.Equal(got, tt.want) {
+				t.Errorf("tombstonedKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTombstoneDeletesTargetKey(t *testing.T) {
+	ctx := context.Background()
+	source := configMap("default", "app-config", map[string]string{"level": "info", "legacy": "on"})
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), source,
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a"},
+		}),
+	)
+	reconcileSyncer(t, r, "app")
+	if target := getConfigMap(t, r, "team-a", "app-config"); target == nil || target.Data["legacy"] != "on" {
+		t.Fatalf("initial sync did not copy legacy: %v", target)
+	}
+
+	source.Data["legacy"] = tombstoneValue
+	if err := r.Update(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSyncer(t, r, "app")
+
+	want := map[string]string{"level": "info"}
+	if target := getConfigMap(t, r, "team-a", "app-config"); !maps.Equal(target.Data, want) {
+		t.Errorf("target data = %v, want %v", target.Data, want)
+	}
+}
+
+func TestTombstoneLeavesKeysNotOwned(t *testing.T) {
+	ctx := context.Background()
+	source := configMap("default", "app-config", map[string]string{"level": "info", "legacy": "on"})
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), source,
+		configMap("team-a", "app-config", map[string]string{"owner": "team-a"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a"},
+			OwnedKeys:        []string{"level", "legacy"},
+		}),
+	)
+	reconcileSyncer(t, r, "app")
+
+	source.Data["legacy"] = tombstoneValue
+	source.Data["owner"] = tombstoneValue
+	if err := r.Update(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSyncer(t, r, "app")
+
+	// The owned key goes, the key team-a manages stays
+	want := map[string]string{"level": "info", "owner": "team-a"}
+	if target := getConfigMap(t, r, "team-a", "app-config"); !maps.Equal(target.Data, want) {
+		t.Errorf("target data = %v, want %v", target.Data, want)
+	}
+}
+
+func TestDeletedCopyIsRecreated(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"),
+		configMap("default", "app-config", map[string]string{"level": "info"}),
+		configMap("team-a", "local-config", map[string]string{"owner": "team-a"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a"},
+		}),
+	)
+	reconcileSyncer(t, r, "app")
+
+	copied := getConfigMap(t, r, "team-a", "app-config")
+	if copied == nil {
+		t.Fatal("initial sync did not create the copy")
+	}
+	if err := r.Delete(ctx, copied); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := r.findSyncersForDeletedTarget(ctx, copied)
+	want := ctrl.Request{NamespacedName: types.NamespacedName{Name: "app", Namespace: "default"}}
+	if !slices.Equal(requests, []ctrl.Request{want}) {
+		t.Fatalf("findSyncersForDeletedTarget() = %v, want [%v]", requests, want)
+	}
+	if requests := r.findSyncersForDeletedTarget(ctx, getConfigMap(t, r, "team-a", "local-config")); len(requests) != 0 {
+		t.Errorf("unmanaged ConfigMap enqueued %v", requests)
+	}
+
+	reconcileSyncer(t, r, requests[0].Name)
+	if getConfigMap(t, r, "team-a", "app-config") == nil {
+		t.Error("deleted copy was not recreated")
+	}
+}
+
+func TestDataTypesDiffer(t *testing.T) {
+	text := &corev1.ConfigMap{Data: map[string]string{"a": "1"}}
+	binary := &corev1.ConfigMap{BinaryData: map[string][]byte{"a": {0x01}}}
+	mixed := &corev1.ConfigMap{Data: map[string]string{"a": "1"}, BinaryData: map[string][]byte{"b": {0x01}}}
+	empty := &corev1.ConfigMap{}
+
+	tests := []struct {
+		name           string
+		source, target *corev1.ConfigMap
+		want           bool
+	}{
+		{"binary over text", binary, text, true},
+		{"text over binary", text, binary, true},
+		{"text over text", text, text, false},
+		{"binary over binary", binary, binary, false},
+		{"mixed over text", mixed, text, false},
+		{"binary over mixed", binary, mixed, false},
+		{"binary over empty", binary, empty, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dataTypesDiffer(tt.source, tt.target); got != tt.want {
+				t.Errorf("dataTypesDiffer() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinarySourceOverTextTarget(t *testing.T) {
+	source := configMap("default", "certs", nil)
+	source.BinaryData = map[string][]byte{"ca.der": {0x30, 0x82, 0x00}}
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), source,
+		configMap("team-a", "certs", map[string]string{"ca.pem": "-----BEGIN CERTIFICATE-----"}),
+		syncer("certs", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "certs",
+			TargetNamespaces: []string{"team-a"},
+		}),
+	)
+
+	_, stored := reconcileSyncer(t, r, "certs")
+
+	condition := meta.FindStatusCondition(stored.Status.Conditions, "TypeMismatch")
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.Contains(condition.Message, "team-a/certs") {
+		t.Errorf("TypeMismatch condition = %+v, want True naming team-a/certs", condition)
+	}
+	select {
+	case event := <-r.Recorder.(*record.FakeRecorder).Events:
+		if !strings.HasPrefix(event, "Warning TypeMismatch ") {
+			t.Errorf("event = %q, want a TypeMismatch warning", event)
+		}
+	default:
+		t.Error("no TypeMismatch event was recorded")
+	}
+	target := getConfigMap(t, r, "team-a", "certs")
+	if len(target.Data) != 0 || len(target.BinaryData["ca.der"]) != 3 {
+		t.Errorf("target data = %v, binaryData = %v, want the source's binary data only", target.Data, target.BinaryData)
+	}
+}
+
+func TestAnnotationsMatch(t *testing.T) {
+	r := newTestReconciler(t)
+	rv := r.annotationKey(sourceContentRevisionAnnotation)
+	uid := r.annotationKey(sourceUIDAnnotation)
+
+	tests := []struct {
+		name              string
+		existing, desired map[string]string
+		want              bool
+	}{
+		{
+			name:     "identical",
+			existing: map[string]string{rv: "1", uid: "u"},
+			desired:  map[string]string{rv: "1", uid: "u"},
+			want:     true,
+		},
+		{
+			name:     "only the resourceVersion differs",
+			existing: map[string]string{rv: "1", uid: "u"},
+			desired:  map[string]string{rv: "2", uid: "u"},
+			want:     true,
+		},
+		{
+			name:     "source was recreated",
+			existing: map[string]string{rv: "1", uid: "u"},
+			desired:  map[string]string{rv: "2", uid: "v"},
+		},
+		{
+			name:     "resourceVersion not recorded yet",
+			existing: map[string]string{uid: "u"},
+			desired:  map[string]string{rv: "2", uid: "u"},
+		},
+		{
+			name:     "other annotation differs",
+			existing: map[string]string{rv: "1", "team": "a"},
+			desired:  map[string]string{rv: "1", "team": "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.annotationsMatch(tt.existing, tt.desired); got != tt.want {
+				t.Errorf("annotationsMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetAnnotationTracksSourceRevision(t *testing.T) {
+	ctx := context.Background()
+	source := configMap("default", "app-config", map[string]string{"level": "info"})
+	source.UID = "source-uid"
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), source,
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:  "default",
+			SourceConfigMap:  "app-config",
+			TargetNamespaces: []string{"team-a"},
+		}),
+	)
+	rv := r.annotationKey(sourceContentRevisionAnnotation)
+
+	checkRevision := func(want string) *corev1.ConfigMap {
+		t.Helper()
+		target := getConfigMap(t, r, "team-a", "app-config")
+		if got := target.Annotations[rv]; got != want {
+			t.Errorf("target records source resourceVersion %q, want %q", got, want)
+		}
+		if got := target.Annotations[r.annotationKey(sourceUIDAnnotation)]; got != "source-uid" {
+			t.Errorf("target records source uid %q, want source-uid", got)
+		}
+		return target
+	}
+
+	reconcileSyncer(t, r, "app")
+	checkRevision(source.ResourceVersion)
+
+	source.Data["level"] = "debug"
+	if err := r.Update(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSyncer(t, r, "app")
+	target := checkRevision(source.ResourceVersion)
+
+	// A new resourceVersion alone doesn't rewrite the target, so it keeps the
+	// revision of the last content change
+	recorded := source.ResourceVersion
+	source.Labels = map[string]string{"edited": "true"}
+	if err := r.Update(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSyncer(t, r, "app")
+	if after := checkRevision(recorded); after.ResourceVersion != target.ResourceVersion {
+		t.Error("target was rewritten although its content did not change")
+	}
+}
+
+func TestUnchangedSource(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	tests := []struct {
+		name   string
+		mutate func(r *ConfigMapSyncerReconciler, syncer *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap)
+		want   bool
+	}{
+		{name: "unchanged", want: true},
+		{
+			name: "strict mode off",
+			mutate: func(_ *ConfigMapSyncerReconciler, syncer *configv1alpha1.ConfigMapSyncer, _ *corev1.ConfigMap) {
+				syncer.Spec.SyncOnlyIfChanged = false
+			},
+		},
+		{
+			name: "spec changed",
+			mutate: func(_ *ConfigMapSyncerReconciler, syncer *configv1alpha1.ConfigMapSyncer, _ *corev1.ConfigMap) {
+				syncer.Generation++
+			},
+		},
+		{
+			name: "source edited",
+			mutate: func(_ *ConfigMapSyncerReconciler, _ *configv1alpha1.ConfigMapSyncer, source *corev1.ConfigMap) {
+				source.ResourceVersion += "0"
+			},
+		},
+		{
+			name: "resync due",
+			mutate: func(_ *ConfigMapSyncerReconciler, syncer *configv1alpha1.ConfigMapSyncer, _ *corev1.ConfigMap) {
+				syncer.Spec.ResyncInterval = &metav1.Duration{Duration: time.Nanosecond}
+			},
+		},
+		{
+			name: "copy deleted",
+			mutate: func(r *ConfigMapSyncerReconciler, _ *configv1alpha1.ConfigMapSyncer, _ *corev1.ConfigMap) {
+				if err := r.Delete(ctx, getConfigMap(t, r, "team-a", "app-config")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(t,
+				namespace("default"), namespace("team-a"),
+				configMap("default", "app-config", map[string]string{"level": "info"}),
+				syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+					SourceNamespace:   "default",
+					SourceConfigMap:   "app-config",
+					TargetNamespaces:  []string{"team-a"},
+					SyncOnlyIfChanged: true,
+				}),
+			)
+			_, stored := reconcileSyncer(t, r, "app")
+			source := getConfigMap(t, r, "default", "app-config")
+			if tt.mutate != nil {
+				tt.mutate(r, stored, source)
+			}
+
+			previous := r.unchangedSource(ctx, stored, source, now.Add(time.Second))
+			if got := previous != nil; got != tt.want {
+				t.Errorf("unchangedSource() skips = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadataEditIsSkippedAfterOneSync(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"),
+		configMap("default", "app-config", map[string]string{"level": "info"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:   "default",
+			SourceConfigMap:   "app-config",
+			TargetNamespaces:  []string{"team-a"},
+			SyncOnlyIfChanged: true,
+		}),
+	)
+	reconcileSyncer(t, r, "app")
+	written := getConfigMap(t, r, "team-a", "app-config").ResourceVersion
+
+	// Only the source's labels change, so its content revision stays behind
+	source := getConfigMap(t, r, "default", "app-config")
+	source.Labels = map[string]string{"edited": "true"}
+	if err := r.Update(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	_, stored := reconcileSyncer(t, r, "app")
+	if getConfigMap(t, r, "team-a", "app-config").ResourceVersion != written {
+		t.Error("metadata-only edit rewrote the target")
+	}
+
+	// The pass recorded the new resourceVersion, so the next one is skipped
+	if r.unchangedSource(ctx, stored, source, time.Now()) == nil {
+		t.Error("unchangedSource() doesn't skip the source after syncing its metadata-only edit")
+	}
+}
+
+func TestUnchangedSourceIsNotWritten(t *testing.T) {
+	r := newTestReconciler(t,
+		namespace("default"), namespace("team-a"), namespace("team-b"),
+		configMap("default", "app-config", map[string]string{"level": "info"}),
+		syncer("app", configv1alpha1.ConfigMapSyncerSpec{
+			SourceNamespace:   "default",
+			SourceConfigMap:   "app-config",
+			TargetNamespaces:  []string{"team-a", "team-b"},
+			SyncOnlyIfChanged: true,
+		}),
+	)
+	reconcileSyncer(t, r, "app")
+
+	var writes []string
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			writes = append(writes, "create "+obj.GetNamespace()+"/"+obj.GetName())
+			return c.Create(ctx, obj, opts...)
+		},
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			writes = append(writes, "update "+obj.GetNamespace()+"/"+obj.GetName())
+			return c.Update(ctx, obj, opts...)
+		},
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			writes = append(writes, "patch "+obj.GetNamespace()+"/"+obj.GetName())
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	})
+
+	_, stored := reconcileSyncer(t, r, "app")
+
+	if len(writes) != 0 {
+		t.Errorf("unchanged source caused writes: %v", writes)
+	}
+	if !slices.Equal(stored.Status.SyncedNamespaces, []string{"team-a", "team-b"}) {
+		t.Errorf("status.syncedNamespaces = %v, want the targets of the last sync", stored.Status.SyncedNamespaces)
+	}
+}
@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// driftDetected is 1 while a target ConfigMap was last found edited out of
+// band, and 0 once it is back in sync
+var driftDetected = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "configmapsyncer_drift_detected",
+		Help: "Whether the target ConfigMap differed from what the syncer last wrote (1) or was in sync (0).",
+	},
+	[]string{"namespace", "configmap"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(driftDetected)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -29,12 +30,29 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var allowedSourceNamespaces string
+	var labelPrefix string
+	var annotationPrefix string
+	var pauseConfigMap string
+	var driftCheckInterval time.Duration
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&allowedSourceNamespaces, "allowed-source-namespaces", "",
+		"Restrict which source namespaces syncers may read, as rules like \"team-a=shared,team-a;team-b=shared\". "+
+			"Use \"*\" as the syncer namespace to apply a rule everywhere. Empty leaves reads unrestricted.")
+
+	flag.StringVar(&labelPrefix, "label-prefix", "",
+		"Prefix for the synced-by and synced-from labels on target copies, e.g. \"acme.io/\". Empty keeps the unprefixed labels.")
+	flag.StringVar(&annotationPrefix, "annotation-prefix", "",
+		"Prefix for the annotations on target copies, e.g. \"acme.io/\". Empty uses \"configmapsyncer.config.example.com/\".")
+	flag.StringVar(&pauseConfigMap, "pause-configmap", "",
+		"Pause all syncing while the ConfigMap \"<namespace>/<name>\" exists. Empty disables the pause switch.")
+	flag.DurationVar(&driftCheckInterval, "drift-check-interval", 10*time.Minute,
+		"How often every syncer is reconciled to check its targets for drift. Zero only checks on changes and retries.")
 
 	opts := zap.Options{
 		Development: true,
@@ -44,6 +62,25 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	allowedSources, err := controllers.ParseAllowedSourceNamespaces(allowedSourceNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid --allowed-source-namespaces")
+		os.Exit(1)
+	}
+
+	for name, prefix := range map[string]string{"--label-prefix": labelPrefix, "--annotation-prefix": annotationPrefix} {
+		if err := controllers.ValidateKeyPrefix(prefix); err != nil {
+			setupLog.Error(err, "invalid "+name)
+			os.Exit(1)
+		}
+	}
+
+	pauseName, err := controllers.ParsePauseConfigMap(pauseConfigMap)
+	if err != nil {
+		setupLog.Error(err, "invalid --pause-configmap")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
@@ -56,8 +93,14 @@ func main() {
 	}
 
 	if err = (&controllers.ConfigMapSyncerReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		Recorder:                mgr.GetEventRecorderFor("configmapsyncer-controller"),
+		AllowedSourceNamespaces: allowedSources,
+		LabelPrefix:             labelPrefix,
+		AnnotationPrefix:        annotationPrefix,
+		PauseConfigMap:          pauseName,
+		DriftCheckInterval:      driftCheckInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ConfigMapSyncer")
 		os.Exit(1)
@@ -1,7 +1,10 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // WebAppSpec defines the desired state of WebApp
@@ -10,17 +13,395 @@ type WebAppSpec struct {
 	// +kubebuilder:validation:Required
 	Image string `json:"image"`
 
-	// Replicas is the number of desired pods
-	// +kubebuilder:validation:Minimum=1
+	// Replicas is the number of desired pods. Zero scales the WebApp to zero
+	// until it is woken with the webapp.example.com/activate annotation.
+	// +kubebuilder:validation:Minimum=0
 	// +kubebuilder:validation:Maximum=10
 	// +kubebuilder:default=1
-	Replicas int32 `json:"replicas,omitempty"`
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
 
 	// Port is the container port to expose
 	// +kubebuilder:validation:Minimum=1
 	// +kubebuilder:validation:Maximum=65535
 	// +kubebuilder:default=80
 	Port int32 `json:"port,omitempty"`
+
+	// Strategy is how new versions are rolled out. BlueGreen runs each new
+	// version in a second Deployment and switches the Service to it once ready.
+	// +kubebuilder:validation:Enum=RollingUpdate;BlueGreen
+	// +kubebuilder:default=RollingUpdate
+	Strategy string `json:"strategy,omitempty"`
+
+	// RolloutPaused pauses the Deployment. Spec changes still update its pod
+	// template but are not rolled out until it is set back to false. Under
+	// BlueGreen the new color is not stood up while paused.
+	RolloutPaused bool `json:"rolloutPaused,omitempty"`
+
+	// BlueGreen tunes the BlueGreen strategy
+	BlueGreen *BlueGreenSpec `json:"blueGreen,omitempty"`
+
+	// Autoscaling creates a HorizontalPodAutoscaler that scales the Deployment
+	// on CPU usage. The operator then leaves the replica count to it.
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// DisruptionBudget creates a PodDisruptionBudget limiting how many pods
+	// voluntary disruptions, such as node drains, may evict at once
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+
+	// RevisionHistoryLimit is the number of old ReplicaSets to retain.
+	// Defaults to the Kubernetes default of 10.
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// ProgressDeadlineSeconds is how long a rollout may stall before it is
+	// reported as failed. Defaults to the Kubernetes default of 600.
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+
+	// PriorityClassName is the priority class assigned to the pods
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// GPU requests GPUs for the container and schedules pods onto GPU nodes
+	GPU *GPUSpec `json:"gpu,omitempty"`
+
+	// Services replaces the default Service with one Service per entry, e.g.
+	// a ClusterIP for internal traffic and a LoadBalancer for external traffic
+	Services []ServiceSpec `json:"services,omitempty"`
+
+	// Headless creates the Service without a cluster IP so DNS resolves
+	// directly to pod addresses. Changing it recreates the Service.
+	Headless bool `json:"headless,omitempty"`
+
+	// Hostname is the external DNS name of the WebApp. It is set as the
+	// external-dns hostname annotation on the externally facing Service and
+	// reported in status.serviceURL, or routed by the HTTPRoute when Gateway
+	// is set.
+	// +kubebuilder:validation:Pattern=`^(\*\.)?([a-z0-9]([-a-z0-9]*[a-z0-9])?\.)*[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=253
+	Hostname string `json:"hostname,omitempty"`
+
+	// Gateway exposes the WebApp through a Gateway API HTTPRoute attached to
+	// this Gateway, for hostname when it is set. The hostname is then left
+	// to the route instead of being annotated on a Service.
+	Gateway *GatewayRef `json:"gateway,omitempty"`
+
+	// SessionAffinity routes each client to the same pod. ClientIP keeps a
+	// client on one pod for SessionAffinityConfig's timeout. Defaults to None.
+	// +kubebuilder:validation:Enum=None;ClientIP
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// SessionAffinityConfig sets the ClientIP stickiness timeout. Defaults to
+	// the Kubernetes default of 3 hours.
+	SessionAffinityConfig *corev1.SessionAffinityConfig `json:"sessionAffinityConfig,omitempty"`
+
+	// ExtraSelectorLabels are added to the Service selector and the pod
+	// template labels. The operator's own labels always take precedence.
+	ExtraSelectorLabels map[string]string `json:"extraSelectorLabels,omitempty"`
+
+	// ExternalHealthCheck makes the operator GET the Service and only report
+	// Ready once it answers with the expected status code
+	ExternalHealthCheck *HealthCheckSpec `json:"externalHealthCheck,omitempty"`
+
+	// Metrics exposes a metrics endpoint on the Service and, when the
+	// Prometheus Operator is installed, creates a ServiceMonitor for it
+	Metrics *MetricsSpec `json:"metrics,omitempty"`
+
+	// DNSPolicy sets the pod DNS policy. Defaults to ClusterFirst. None
+	// requires DNSConfig to list at least one nameserver.
+	// +kubebuilder:validation:Enum=ClusterFirst;ClusterFirstWithHostNet;Default;None
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig adds nameservers, search domains and resolver options to the
+	// pod's resolv.conf
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// HostAliases are added to the pod's /etc/hosts
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+
+	// Env sets environment variables in the app container. Values read from
+	// ConfigMaps and Secrets restart the pods when that content changes.
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom loads every key of a ConfigMap or Secret into the app
+	// container's environment. Content changes restart the pods.
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// PodSecurityContext is applied to the pods. Defaults to running as
+	// non-root with the RuntimeDefault seccomp profile.
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is applied to the container. Defaults to dropping all
+	// capabilities and disallowing privilege escalation.
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// StartupProbe checks whether the app container has finished starting.
+	// Kubernetes holds off liveness and readiness probes until it succeeds,
+	// so slow-booting apps aren't restarted while they warm up.
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// TrafficDelaySeconds keeps a new pod out of its Services for this long
+	// after its containers became ready, so it can warm up before taking
+	// traffic. Rollouts wait for the delay too.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	TrafficDelaySeconds int32 `json:"trafficDelaySeconds,omitempty"`
+
+	// Sidecars are extra containers run in each pod next to the app
+	Sidecars []Sidecar `json:"sidecars,omitempty"`
+
+	// LogShipping runs a log shipper that reads the files the app writes to
+	// a log directory shared through an emptyDir
+	LogShipping *LogShippingSpec `json:"logShipping,omitempty"`
+
+	// EmptyDirVolumes are scratch volumes mounted into the container. They
+	// live as long as the pod and start out empty.
+	EmptyDirVolumes []EmptyDirVolume `json:"emptyDirVolumes,omitempty"`
+
+	// PersistentVolume mounts an existing PVC into the container. Since most
+	// PVCs can only be attached to one node, the Deployment is then pinned to
+	// one replica and the Recreate strategy.
+	PersistentVolume *PersistentVolume `json:"persistentVolume,omitempty"`
+
+	// PodAnnotations are added to the pod template
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// PodLabels are added to the pod template. The operator's own labels
+	// always take precedence.
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+
+	// DeploymentAnnotations are added to the Deployment
+	DeploymentAnnotations map[string]string `json:"deploymentAnnotations,omitempty"`
+
+	// DeploymentLabels are added to the Deployment. The operator's own labels
+	// always take precedence.
+	DeploymentLabels map[string]string `json:"deploymentLabels,omitempty"`
+}
+
+// BlueGreenSpec configures blue-green rollouts
+type BlueGreenSpec struct {
+	// ScaleDownDelaySeconds is how long the previous color keeps running after
+	// the Service switches away from it, to let in-flight requests drain
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=30
+	ScaleDownDelaySeconds int32 `json:"scaleDownDelaySeconds,omitempty"`
+}
+
+// AutoscalingSpec configures the HorizontalPodAutoscaler
+type AutoscalingSpec struct {
+	// MinReplicas is the fewest pods the autoscaler keeps running
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the most pods the autoscaler scales up to
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetCPUUtilizationPercentage is the average CPU usage, relative to
+	// CPURequest, the autoscaler aims for
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=80
+	TargetCPUUtilizationPercentage int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+
+	// CPURequest is the CPU requested by the app container, which CPU
+	// utilization is measured against. Defaults to 100m.
+	CPURequest *resource.Quantity `json:"cpuRequest,omitempty"`
+}
+
+// DisruptionBudgetSpec configures the PodDisruptionBudget
+type DisruptionBudgetSpec struct {
+	// MaxUnavailable is how many pods may be evicted at once, as a number or
+	// a percentage of the replica floor: spec.replicas, or
+	// autoscaling.minReplicas when autoscaling is enabled. A value that
+	// would allow no evictions is raised to 1. Defaults to 25%.
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// EmptyDirVolume is a scratch volume and where it is mounted
+type EmptyDirVolume struct {
+	// Name identifies the volume within the pod
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// MountPath is the absolute path the volume is mounted at
+	// +kubebuilder:validation:Pattern=`^/`
+	MountPath string `json:"mountPath"`
+
+	// SizeLimit caps how much the volume may hold. Pods exceeding it are evicted.
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+
+	// Medium is where the volume is stored. Memory uses a tmpfs that counts
+	// against the container's memory limit. Defaults to the node's disk.
+	// +kubebuilder:validation:Enum="";Memory
+	Medium corev1.StorageMedium `json:"medium,omitempty"`
+}
+
+// PersistentVolume is an existing PVC and where it is mounted
+type PersistentVolume struct {
+	// ClaimName is the PVC in the WebApp's namespace
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// MountPath is the absolute path the volume is mounted at
+	// +kubebuilder:validation:Pattern=`^/`
+	MountPath string `json:"mountPath"`
+}
+
+// GPUSpec requests GPUs from a vendor device plugin
+type GPUSpec struct {
+	// Count is the number of GPUs per pod
+	// +kubebuilder:validation:Minimum=1
+	Count int32 `json:"count"`
+
+	// Vendor selects the device plugin resource, e.g. nvidia.com/gpu
+	// +kubebuilder:validation:Enum=nvidia;amd
+	// +kubebuilder:default=nvidia
+	Vendor string `json:"vendor,omitempty"`
+}
+
+// ServiceSpec describes one of the Services exposing the WebApp
+type ServiceSpec struct {
+	// NameSuffix is appended to the WebApp name to form the Service name.
+	// An empty suffix uses the WebApp name itself.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	NameSuffix string `json:"nameSuffix,omitempty"`
+
+	// Type is the Service type
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=ClusterIP
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Ports are the ports the Service exposes. Defaults to the WebApp port.
+	Ports []ServicePort `json:"ports,omitempty"`
+}
+
+// ServicePort is a port exposed by a Service
+type ServicePort struct {
+	// Name identifies the port within the Service
+	Name string `json:"name"`
+
+	// Port is the port the Service listens on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// TargetPort is the container port traffic is sent to. Defaults to Port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	TargetPort int32 `json:"targetPort,omitempty"`
+}
+
+// Sidecar is an extra container run next to the app container
+type Sidecar struct {
+	// Name identifies the container within the pod
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Image is the container image to run
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Args are passed to the image's entrypoint
+	Args []string `json:"args,omitempty"`
+
+	// Ports are the ports the sidecar listens on
+	Ports []SidecarPort `json:"ports,omitempty"`
+}
+
+// LogShippingSpec configures the log shipper and the log directory it reads
+type LogShippingSpec struct {
+	// Image is the log shipper's container image
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Args are passed to the image's entrypoint
+	Args []string `json:"args,omitempty"`
+
+	// LogPath is the directory the app writes its logs to. The log shipper
+	// sees the same files, read-only, at the same path.
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:default="/var/log/app"
+	LogPath string `json:"logPath,omitempty"`
+
+	// SizeLimit caps how much the log directory may hold. Pods exceeding it are evicted.
+	SizeLimit *resource.Quantity `json:"sizeLimit,omitempty"`
+}
+
+// SidecarPort is a port a sidecar listens on
+type SidecarPort struct {
+	// Name identifies the port. It must be unique across all containers and,
+	// when exposed, across the Service's ports.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
+
+	// ContainerPort is the port number
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// ExposeThroughService adds the port to the WebApp's Service, or to the
+	// first ClusterIP Service when spec.services is set
+	ExposeThroughService bool `json:"exposeThroughService,omitempty"`
+}
+
+// GatewayRef names the Gateway an HTTPRoute attaches to
+type GatewayRef struct {
+	// Name is the Gateway's name
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Namespace is the Gateway's namespace. Defaults to the WebApp's namespace.
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName attaches the route to a single listener of the Gateway
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// HealthCheckSpec describes an HTTP check against the WebApp's Service
+type HealthCheckSpec struct {
+	// Path is the HTTP path requested. A missing leading slash is added.
+	// +kubebuilder:default="/"
+	Path string `json:"path,omitempty"`
+
+	// ExpectedStatus is the HTTP status code that counts as healthy.
+	// Redirects are not followed, so a 3xx answer is compared as is.
+	// +kubebuilder:validation:Minimum=100
+	// +kubebuilder:validation:Maximum=599
+	// +kubebuilder:default=200
+	ExpectedStatus int32 `json:"expectedStatus,omitempty"`
+
+	// TimeoutSeconds bounds each request
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=5
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// PeriodSeconds is how often the check is repeated
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=30
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+}
+
+// MetricsSpec describes the endpoint Prometheus scrapes
+type MetricsSpec struct {
+	// Port is the container port serving metrics
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Path is the HTTP path serving metrics
+	// +kubebuilder:default="/metrics"
+	Path string `json:"path,omitempty"`
+
+	// Interval is how often Prometheus scrapes the endpoint
+	// +kubebuilder:validation:Pattern=`^([0-9]+(ms|s|m|h))+$`
+	// +kubebuilder:default="30s"
+	Interval string `json:"interval,omitempty"`
 }
 
 // WebAppStatus defines the observed state of WebApp
@@ -28,9 +409,25 @@ type WebAppStatus struct {
 	// AvailableReplicas is the number of ready pods
 	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
 
-	// ServiceURL is the URL to access the application
+	// ServiceURL is the URL to access the application, using spec.hostname
+	// when it is set
 	ServiceURL string `json:"serviceURL,omitempty"`
 
+	// Hostname is the hostname the HTTPRoute serves the WebApp under, once
+	// the Gateway has accepted it: spec.hostname, or the listener's hostname
+	Hostname string `json:"hostname,omitempty"`
+
+	// ServiceURLs lists the addresses of every Service exposing the application
+	ServiceURLs []string `json:"serviceURLs,omitempty"`
+
+	// ActiveColor is the color, blue or green, the Service routes to under
+	// the BlueGreen strategy. It is default while the regular Deployment's
+	// pods serve traffic during a switch between strategies.
+	ActiveColor string `json:"activeColor,omitempty"`
+
+	// PromotedAt is when ActiveColor last changed
+	PromotedAt *metav1.Time `json:"promotedAt,omitempty"`
+
 	// Conditions represent the latest available observations
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
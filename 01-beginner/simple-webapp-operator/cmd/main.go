@@ -29,10 +29,24 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var rejectLatestTag bool
+	var verifyImageDigests bool
+	var appLabelKey string
+	var managedByLabelKey string
+	var managedByLabelValue string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
+	flag.BoolVar(&rejectLatestTag, "reject-latest-tag", false, "Reject WebApps whose image is untagged or uses the latest tag.")
+	flag.BoolVar(&verifyImageDigests, "verify-image-digests", false,
+		"Report WebApps whose pods run an image digest other than the one their image is pinned to.")
+	flag.StringVar(&appLabelKey, "app-label-key", "app",
+		"Label key holding the WebApp name on its resources, e.g. app.kubernetes.io/name.")
+	flag.StringVar(&managedByLabelKey, "managed-by-label-key", "managed-by",
+		"Label key marking resources as managed by the operator, e.g. app.kubernetes.io/managed-by.")
+	flag.StringVar(&managedByLabelValue, "managed-by-label-value", "webapp-operator",
+		"Value of the managed-by label.")
 
 	opts := zap.Options{
 		Development: true,
@@ -42,6 +56,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if err := controllers.ValidateSelectorLabels(appLabelKey, managedByLabelKey, managedByLabelValue); err != nil {
+		setupLog.Error(err, "invalid label flags")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
@@ -54,8 +73,13 @@ func main() {
 	}
 
 	if err = (&controllers.WebAppReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:              mgr.GetClient(),
+		Scheme:              mgr.GetScheme(),
+		RejectLatestTag:     rejectLatestTag,
+		VerifyImageDigests:  verifyImageDigests,
+		AppLabelKey:         appLabelKey,
+		ManagedByLabelKey:   managedByLabelKey,
+		ManagedByLabelValue: managedByLabelValue,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "WebApp")
 		os.Exit(1)
@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+const (
+	strategyBlueGreen = "BlueGreen"
+
+	// colorLabel tells the blue and green pods apart in selectors
+	colorLabel = "webapp.example.com/color"
+
+	// defaultColor labels the pods of the regular Deployment, so the Service
+	// can keep selecting only them while switching between strategies
+	defaultColor = "default"
+
+	// templateHashAnnotation records which pod template a colored Deployment runs
+	templateHashAnnotation = "webapp.example.com/template-hash"
+
+	defaultScaleDownDelaySeconds int32 = 30
+)
+
+var colors = []string{"blue", "green"}
+
+// reconcileBlueGreen rolls pod template changes out to the idle color,
+// promotes it once all of its replicas are available and scales the previous
+// color down after the configured delay. It returns when to check again.
+func (r *WebAppReconciler) reconcileBlueGreen(ctx context.Context, webapp *appsv1alpha1.WebApp) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	base, err := r.desiredDeployment(ctx, webapp)
+	if err != nil {
+		return 0, err
+	}
+	hash, err := templateHash(&base.Spec.Template)
+	if err != nil {
+		return 0, err
+	}
+
+	// Switching over from RollingUpdate: the regular Deployment keeps serving
+	// until the first color is promoted
+	if webapp.Status.ActiveColor == "" {
+		regular := &appsv1.Deployment{}
+		err := r.Get(ctx, types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace}, regular)
+		if err != nil && !errors.IsNotFound(err) {
+			return 0, err
+		}
+		if err == nil && metav1.IsControlledBy(regular, webapp) {
+			webapp.Status.ActiveColor = defaultColor
+		}
+	}
+
+	active := webapp.Status.ActiveColor
+	if active != "" {
+		current := &appsv1.Deployment{}
+		err := r.Get(ctx, types.NamespacedName{Name: activeDeploymentName(webapp), Namespace: webapp.Namespace}, current)
+		if err != nil && !errors.IsNotFound(err) {
+			return 0, err
+		}
+
+		// Nothing new to roll out, keep the active color in sync and retire the other
+		if err == nil && current.Annotations[templateHashAnnotation] == hash {
+			if err := r.applyDeployment(ctx, webapp, colorDeployment(base, webapp, active, hash)); err != nil {
+				return 0, err
+			}
+			return r.scaleDownIdle(ctx, webapp, otherColor(active))
+		}
+	}
+
+	// The pod template changed, or nothing is active yet: stand up the idle
+	// color, unless the rollout is paused
+	preview := otherColor(active)
+	if webapp.Spec.RolloutPaused {
+		r.updateCondition(webapp, "Promoted", metav1.ConditionFalse, "RolloutPaused",
+			fmt.Sprintf("Rollout is paused, %s is not stood up until rolloutPaused is cleared", preview))
+		return 0, nil
+	}
+	if err := r.applyDeployment(ctx, webapp, colorDeployment(base, webapp, preview, hash)); err != nil {
+		return 0, err
+	}
+
+	current := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: colorDeploymentName(webapp, preview), Namespace: webapp.Namespace}, current); err != nil {
+		if errors.IsNotFound(err) {
+			// Not in the cache yet, the Deployment watch brings us back
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if current.Annotations[templateHashAnnotation] != hash || !deploymentReady(current) {
+		r.updateCondition(webapp, "Promoted", metav1.ConditionFalse, "WaitingForRollout",
+			fmt.Sprintf("Waiting for %s to become ready", preview))
+		return 0, nil
+	}
+
+	// Switch the Service over; reconcileServices picks up the new color
+	now := metav1.Now()
+	webapp.Status.ActiveColor = preview
+	webapp.Status.PromotedAt = &now
+	r.updateCondition(webapp, "Promoted", metav1.ConditionTrue, "Promoted",
+		fmt.Sprintf("Traffic switched to %s", preview))
+	log.Info("Promoted color", "color", preview)
+
+	return time.Duration(scaleDownDelaySeconds(webapp)) * time.Second, nil
+}
+
+// scaleDownIdle scales the idle color to zero, and removes the Deployment
+// left over from the RollingUpdate strategy, once the scale-down delay after
+// the last promotion has passed
+func (r *WebAppReconciler) scaleDownIdle(ctx context.Context, webapp *appsv1alpha1.WebApp, idle string) (time.Duration, error) {
+	if promotedAt := webapp.Status.PromotedAt; promotedAt != nil {
+		delay := time.Duration(scaleDownDelaySeconds(webapp)) * time.Second
+		if remaining := time.Until(promotedAt.Add(delay)); remaining > 0 {
+			return remaining, nil
+		}
+	}
+
+	deployment := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: colorDeploymentName(webapp, idle), Namespace: webapp.Namespace}, deployment)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	if err == nil && int32Value(deployment.Spec.Replicas, 1) != 0 {
+		var zero int32
+		deployment.Spec.Replicas = &zero
+		if err := r.Update(ctx, deployment); err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, r.deleteOwnedDeployment(ctx, webapp, webapp.Name)
+}
+
+// retireBlueGreen hands traffic back to the regular Deployment after switching
+// from BlueGreen to RollingUpdate. The Service keeps selecting the active color
+// until the regular Deployment is ready, and the colored Deployments are only
+// deleted on a later pass, after the Service has stopped selecting them.
+func (r *WebAppReconciler) retireBlueGreen(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	if active := webapp.Status.ActiveColor; active == "" || active == defaultColor {
+		for _, color := range colors {
+			if err := r.deleteOwnedDeployment(ctx, webapp, colorDeploymentName(webapp, color)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace}, deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !deploymentReady(deployment) {
+		return nil
+	}
+
+	webapp.Status.ActiveColor = defaultColor
+	webapp.Status.PromotedAt = nil
+	meta.RemoveStatusCondition(&webapp.Status.Conditions, "Promoted")
+	return nil
+}
+
+func (r *WebAppReconciler) deleteOwnedDeployment(ctx context.Context, webapp *appsv1alpha1.WebApp, name string) error {
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: webapp.Namespace}, deployment); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(deployment, webapp) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Delete(ctx, deployment))
+}
+
+// colorDeployment derives the Deployment for one color from the regular one
+func colorDeployment(base *appsv1.Deployment, webapp *appsv1alpha1.WebApp, color, hash string) *appsv1.Deployment {
+	deployment := base.DeepCopy()
+	deployment.Name = colorDeploymentName(webapp, color)
+
+	deployment.Spec.Selector.MatchLabels = mergeLabels(deployment.Spec.Selector.MatchLabels, map[string]string{colorLabel: color})
+	deployment.Spec.Template.Labels = mergeLabels(deployment.Spec.Template.Labels, map[string]string{colorLabel: color})
+	deployment.Annotations = mergeLabels(deployment.Annotations, map[string]string{templateHashAnnotation: hash})
+
+	return deployment
+}
+
+func colorDeploymentName(webapp *appsv1alpha1.WebApp, color string) string {
+	return fmt.Sprintf("%s-%s", webapp.Name, color)
+}
+
+// activeDeploymentName is the Deployment currently receiving traffic
+func activeDeploymentName(webapp *appsv1alpha1.WebApp) string {
+	if color := webapp.Status.ActiveColor; color != "" && color != defaultColor {
+		return colorDeploymentName(webapp, color)
+	}
+	return webapp.Name
+}
+
+func otherColor(color string) string {
+	if color == "blue" {
+		return "green"
+	}
+	return "blue"
+}
+
+func scaleDownDelaySeconds(webapp *appsv1alpha1.WebApp) int32 {
+	if webapp.Spec.BlueGreen == nil {
+		return defaultScaleDownDelaySeconds
+	}
+	return webapp.Spec.BlueGreen.ScaleDownDelaySeconds
+}
+
+// templateHash identifies a pod template so a new version can be told apart
+// from scaling or metadata changes
+func templateHash(template *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
+// deploymentReady reports whether every replica runs the latest template and is available
+func deploymentReady(deployment *appsv1.Deployment) bool {
+	replicas := int32Value(deployment.Spec.Replicas, 1)
+	return deployment.Status.ObservedGeneration >= deployment.Generation &&
+		deployment.Status.UpdatedReplicas == replicas &&
+		deployment.Status.AvailableReplicas == replicas &&
+		deployment.Status.Replicas == replicas
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// configHashAnnotation on the pod template records the content of the
+// ConfigMaps and Secrets the app container reads, so changing it rolls the pods
+const configHashAnnotation = "webapp.example.com/config-hash"
+
+// desiredDeployment builds the WebApp's Deployment and stamps the pod
+// template with the hash of the ConfigMaps and Secrets it references
+func (r *WebAppReconciler) desiredDeployment(ctx context.Context, webapp *appsv1alpha1.WebApp) (*appsv1.Deployment, error) {
+	deployment := r.createDeployment(webapp)
+
+	hash, err := r.configHash(ctx, webapp)
+	if err != nil {
+		return nil, err
+	}
+	if hash != "" {
+		deployment.Spec.Template.Annotations = mergeLabels(deployment.Spec.Template.Annotations,
+			map[string]string{configHashAnnotation: hash})
+	}
+	return deployment, nil
+}
+
+// referencedConfig returns the sorted names of the ConfigMaps and Secrets the
+// WebApp's env and envFrom read
+func referencedConfig(webapp *appsv1alpha1.WebApp) (configMaps, secrets []string) {
+	for _, env := range webapp.Spec.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+			configMaps = append(configMaps, ref.Name)
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+			secrets = append(secrets, ref.Name)
+		}
+	}
+	for _, source := range webapp.Spec.EnvFrom {
+		if source.ConfigMapRef != nil {
+			configMaps = append(configMaps, source.ConfigMapRef.Name)
+		}
+		if source.SecretRef != nil {
+			secrets = append(secrets, source.SecretRef.Name)
+		}
+	}
+
+	sort.Strings(configMaps)
+	sort.Strings(secrets)
+	return slices.Compact(configMaps), slices.Compact(secrets)
+}
+
+// configHash hashes the content of every ConfigMap and Secret the WebApp
+// references. Only data counts, so metadata-only updates don't restart pods.
+// It returns an empty string when nothing is referenced.
+func (r *WebAppReconciler) configHash(ctx context.Context, webapp *appsv1alpha1.WebApp) (string, error) {
+	configMaps, secrets := referencedConfig(webapp)
+	if len(configMaps) == 0 && len(secrets) == 0 {
+		return "", nil
+	}
+
+	hasher := sha256.New()
+	for _, name := range configMaps {
+		configMap := &corev1.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: webapp.Namespace}, configMap)
+		if errors.IsNotFound(err) {
+			// Optional references may be missing; creating them later restarts the pods
+			fmt.Fprintf(hasher, "configmap %s missing\n", name)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hasher, "configmap %s\n", name)
+		hashData(hasher, configMap.Data, configMap.BinaryData)
+	}
+	for _, name := range secrets {
+		secret := &corev1.Secret{}
+		err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: webapp.Namespace}, secret)
+		if errors.IsNotFound(err) {
+			fmt.Fprintf(hasher, "secret %s missing\n", name)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hasher, "secret %s\n", name)
+		hashData(hasher, nil, secret.Data)
+	}
+
+	return hex.EncodeToString(hasher.Sum(nil))[:16], nil
+}
+
+// hashData writes data and binaryData to hasher in key order
+func hashData(hasher io.Writer, data map[string]string, binaryData map[string][]byte) {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "%q=%q\n", key, data[key])
+	}
+
+	keys = keys[:0]
+	for key := range binaryData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "%q=%q\n", key, binaryData[key])
+	}
+}
+
+// findWebAppsForConfig maps a ConfigMap or Secret to the WebApps in its
+// namespace that reference it
+func (r *WebAppReconciler) findWebAppsForConfig(secret bool) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		webapps := &appsv1alpha1.WebAppList{}
+		if err := r.List(ctx, webapps, client.InNamespace(obj.GetNamespace())); err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, webapp := range webapps.Items {
+			configMaps, secrets := referencedConfig(&webapp)
+			names := configMaps
+			if secret {
+				names = secrets
+			}
+			if slices.Contains(names, obj.GetName()) {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace},
+				})
+			}
+		}
+		return requests
+	}
+}
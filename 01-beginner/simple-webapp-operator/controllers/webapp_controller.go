@@ -3,27 +3,86 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/http"
+	"path"
 	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
 )
 
+// imageReferencePattern matches [registry[:port]/]name[/name...][:tag][@digest]
+var imageReferencePattern = regexp.MustCompile(
+	`^(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+		`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+		`(?::[\w][\w.-]{0,127})?` +
+		`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+const (
+	// Kubernetes defaults applied to Deployments that leave these fields unset
+	defaultRevisionHistoryLimit    int32 = 10
+	defaultProgressDeadlineSeconds int32 = 600
+
+	// maxClientIPAffinitySeconds is the longest ClientIP session affinity the API server accepts
+	maxClientIPAffinitySeconds int32 = 86400
+
+	// persistentVolumeName is the pod volume name of spec.persistentVolume
+	persistentVolumeName = "persistent-data"
+
+	// externalDNSHostnameAnnotation tells external-dns which name to publish for a Service
+	externalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+)
+
+// serviceMonitorGVK identifies the Prometheus Operator's ServiceMonitor, which
+// is handled as unstructured so the operator does not depend on its API module
+var serviceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
 // WebAppReconciler reconciles a WebApp object
 type WebAppReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// RejectLatestTag fails WebApps whose image is untagged or tagged latest
+	RejectLatestTag bool
+
+	// VerifyImageDigests compares the digest pods run against the one
+	// spec.image is pinned to and reports any mismatch
+	VerifyImageDigests bool
+
+	// AppLabelKey, ManagedByLabelKey and ManagedByLabelValue replace the
+	// app and managed-by labels that identify a WebApp's resources, e.g.
+	// with app.kubernetes.io/name and app.kubernetes.io/managed-by. Empty
+	// values keep the defaults.
+	AppLabelKey         string
+	ManagedByLabelKey   string
+	ManagedByLabelValue string
 }
 
 // +kubebuilder:rbac:groups=apps.example.com,resources=webapps,verbs=get;list;watch;create;update;patch;delete
@@ -31,6 +90,15 @@ type WebAppReconciler struct {
 // +kubebuilder:rbac:groups=apps.example.com,resources=webapps/finalizers,verbs=update
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups="",resources=pods/status,verbs=patch
+// +kubebuilder:rbac:groups=apps,resources=replicasets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
 
 func (r *WebAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -41,22 +109,120 @@ func (r *WebAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Validate image reference
+	if err := validateImage(webapp.Spec.Image, r.RejectLatestTag); err != nil {
+		log.Error(err, "Invalid image reference")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "InvalidImage", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, nil
+	}
+
+	// Validate Service definitions, session affinity, DNS settings, volumes, probes and scaling
+	err := validateServices(webapp.Spec.Services)
+	if err == nil {
+		err = validateSessionAffinity(webapp)
+	}
+	if err == nil {
+		err = validateDNS(webapp)
+	}
+	if err == nil {
+		err = validateEmptyDirVolumes(webapp.Spec.EmptyDirVolumes)
+	}
+	if err == nil {
+		err = validatePersistentVolume(webapp)
+	}
+	if err == nil {
+		err = validateSidecars(webapp)
+	}
+	if err == nil {
+		err = validateLogShipping(webapp)
+	}
+	if err == nil {
+		err = validateStartupProbe(webapp)
+	}
+	if err == nil {
+		err = validateActivation(webapp)
+	}
+	if err == nil {
+		err = validateAutoscaling(webapp)
+	}
+	if err == nil {
+		err = validateDisruptionBudget(webapp)
+	}
+	if err == nil {
+		err = validateServicePorts(r.desiredServices(webapp))
+	}
+	if err != nil {
+		log.Error(err, "Invalid spec")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "InvalidSpec", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, nil
+	}
+
 	// Reconcile Deployment
-	if err := r.reconcileDeployment(ctx, webapp); err != nil {
+	var requeueAfter time.Duration
+	if webapp.Spec.Strategy == strategyBlueGreen {
+		requeueAfter, err = r.reconcileBlueGreen(ctx, webapp)
+	} else if err = r.reconcileDeployment(ctx, webapp); err == nil {
+		err = r.retireBlueGreen(ctx, webapp)
+	}
+	if err != nil {
 		log.Error(err, "Failed to reconcile Deployment")
 		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "DeploymentFailed", err.Error())
 		r.Status().Update(ctx, webapp)
 		return ctrl.Result{}, err
 	}
 
-	// Reconcile Service
-	if err := r.reconcileService(ctx, webapp); err != nil {
+	// Reconcile Services
+	if err := r.reconcileServices(ctx, webapp); err != nil {
 		log.Error(err, "Failed to reconcile Service")
 		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "ServiceFailed", err.Error())
 		r.Status().Update(ctx, webapp)
 		return ctrl.Result{}, err
 	}
 
+	// Reconcile HorizontalPodAutoscaler and PodDisruptionBudget
+	if err := r.reconcileAutoscaler(ctx, webapp); err != nil {
+		log.Error(err, "Failed to reconcile HorizontalPodAutoscaler")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "AutoscalerFailed", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, err
+	}
+	if err := r.reconcileDisruptionBudget(ctx, webapp); err != nil {
+		log.Error(err, "Failed to reconcile PodDisruptionBudget")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "DisruptionBudgetFailed", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, err
+	}
+
+	// Reconcile ServiceMonitor
+	if err := r.reconcileServiceMonitor(ctx, webapp); err != nil {
+		log.Error(err, "Failed to reconcile ServiceMonitor")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "ServiceMonitorFailed", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, err
+	}
+
+	// Reconcile HTTPRoute
+	if err := r.reconcileHTTPRoute(ctx, webapp); err != nil {
+		log.Error(err, "Failed to reconcile HTTPRoute")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "HTTPRouteFailed", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, err
+	}
+
+	// Let pods take traffic once they have warmed up
+	trafficRequeue, err := r.releaseTraffic(ctx, webapp)
+	if err != nil {
+		log.Error(err, "Failed to release traffic to pods")
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "TrafficReleaseFailed", err.Error())
+		r.Status().Update(ctx, webapp)
+		return ctrl.Result{}, err
+	}
+	if trafficRequeue > 0 && (requeueAfter == 0 || trafficRequeue < requeueAfter) {
+		requeueAfter = trafficRequeue
+	}
+
 	// Update Status
 	if err := r.updateStatus(ctx, webapp); err != nil {
 		log.Error(err, "Failed to update status")
@@ -64,29 +230,69 @@ func (r *WebAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	}
 
 	log.Info("Successfully reconciled WebApp")
-	return ctrl.Result{}, nil
+
+	// Re-run the health check periodically
+	if check := webapp.Spec.ExternalHealthCheck; check != nil {
+		period := check.PeriodSeconds
+		if period == 0 {
+			period = 30
+		}
+		if requeueAfter == 0 || time.Duration(period)*time.Second < requeueAfter {
+			requeueAfter = time.Duration(period) * time.Second
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *WebAppReconciler) reconcileDeployment(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	deployment, err := r.desiredDeployment(ctx, webapp)
+	if err != nil {
+		return err
+	}
+	deployment.Spec.Template.Labels = mergeLabels(deployment.Spec.Template.Labels, map[string]string{colorLabel: defaultColor})
+	return r.applyDeployment(ctx, webapp, deployment)
+}
+
+// applyDeployment creates desiredDeployment or brings the existing Deployment
+// of the same name in line with it
+func (r *WebAppReconciler) applyDeployment(ctx context.Context, webapp *appsv1alpha1.WebApp, desiredDeployment *appsv1.Deployment) error {
+	if err := recordOwnedMetadata(desiredDeployment); err != nil {
+		return err
+	}
+
 	deployment := &appsv1.Deployment{}
 	err := r.Get(ctx, types.NamespacedName{
-		Name:      webapp.Name,
-		Namespace: webapp.Namespace,
+		Name:      desiredDeployment.Name,
+		Namespace: desiredDeployment.Namespace,
 	}, deployment)
 
 	if err != nil && errors.IsNotFound(err) {
 		// Deployment doesn't exist, create it
-		deployment = r.createDeployment(webapp)
-		if err := controllerutil.SetControllerReference(webapp, deployment, r.Scheme); err != nil {
+		if err := controllerutil.SetControllerReference(webapp, desiredDeployment, r.Scheme); err != nil {
 			return err
 		}
-		return r.Create(ctx, deployment)
+		return r.Create(ctx, desiredDeployment)
 	} else if err != nil {
 		return err
 	}
 
+	// A replaced Deployment is recreated once its deletion completes
+	if !deployment.DeletionTimestamp.IsZero() {
+		return nil
+	}
+
+	// Selectors are immutable, so a change of label keys replaces the Deployment
+	if !equality.Semantic.DeepEqual(deployment.Spec.Selector, desiredDeployment.Spec.Selector) {
+		return r.replaceDeployment(ctx, deployment, desiredDeployment.Spec.Selector.MatchLabels)
+	}
+
+	// The autoscaler owns the replica count
+	if webapp.Spec.Autoscaling != nil {
+		desiredDeployment.Spec.Replicas = deployment.Spec.Replicas
+	}
+
 	// Deployment exists, update if needed
-	desiredDeployment := r.createDeployment(webapp)
+	needsUpdate := false
 	if !reflect.DeepEqual(deployment.Spec.Replicas, desiredDeployment.Spec.Replicas) ||
 		!reflect.DeepEqual(deployment.Spec.Template.Spec.Containers[0].Image, desiredDeployment.Spec.Template.Spec.Containers[0].Image) ||
 		!reflect.DeepEqual(deployment.Spec.Template.Spec.Containers[0].Ports, desiredDeployment.Spec.Template.Spec.Containers[0].Ports) {
@@ -94,77 +300,316 @@ func (r *WebAppReconciler) reconcileDeployment(ctx context.Context, webapp *apps
 		deployment.Spec.Replicas = desiredDeployment.Spec.Replicas
 		deployment.Spec.Template.Spec.Containers[0].Image = desiredDeployment.Spec.Template.Spec.Containers[0].Image
 		deployment.Spec.Template.Spec.Containers[0].Ports = desiredDeployment.Spec.Template.Spec.Containers[0].Ports
-		
+		needsUpdate = true
+	}
+
+	// Unset fields are compared against the values the API server defaults them to
+	if int32Value(deployment.Spec.RevisionHistoryLimit, defaultRevisionHistoryLimit) != int32Value(desiredDeployment.Spec.RevisionHistoryLimit, defaultRevisionHistoryLimit) ||
+		int32Value(deployment.Spec.ProgressDeadlineSeconds, defaultProgressDeadlineSeconds) != int32Value(desiredDeployment.Spec.ProgressDeadlineSeconds, defaultProgressDeadlineSeconds) {
+		deployment.Spec.RevisionHistoryLimit = desiredDeployment.Spec.RevisionHistoryLimit
+		deployment.Spec.ProgressDeadlineSeconds = desiredDeployment.Spec.ProgressDeadlineSeconds
+		needsUpdate = true
+	}
+
+	// GPU resources and scheduling hints
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Containers[0].Resources, desiredDeployment.Spec.Template.Spec.Containers[0].Resources) ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.NodeSelector, desiredDeployment.Spec.Template.Spec.NodeSelector) ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Tolerations, desiredDeployment.Spec.Template.Spec.Tolerations) {
+		deployment.Spec.Template.Spec.Containers[0].Resources = desiredDeployment.Spec.Template.Spec.Containers[0].Resources
+		deployment.Spec.Template.Spec.NodeSelector = desiredDeployment.Spec.Template.Spec.NodeSelector
+		deployment.Spec.Template.Spec.Tolerations = desiredDeployment.Spec.Template.Spec.Tolerations
+		needsUpdate = true
+	}
+
+	if deployment.Spec.Template.Spec.PriorityClassName != desiredDeployment.Spec.Template.Spec.PriorityClassName {
+		deployment.Spec.Template.Spec.PriorityClassName = desiredDeployment.Spec.Template.Spec.PriorityClassName
+		needsUpdate = true
+	}
+
+	// The strategy is replaced as a whole, since Recreate rejects rollingUpdate settings
+	if deploymentStrategyType(deployment.Spec.Strategy) != deploymentStrategyType(desiredDeployment.Spec.Strategy) {
+		deployment.Spec.Strategy = desiredDeployment.Spec.Strategy
+		needsUpdate = true
+	}
+
+	// A paused Deployment takes template changes without rolling them out;
+	// unpausing rolls out whatever was staged meanwhile
+	if deployment.Spec.Paused != desiredDeployment.Spec.Paused {
+		deployment.Spec.Paused = desiredDeployment.Spec.Paused
+		needsUpdate = true
+	}
+
+	// Sidecars are compared on the fields the operator sets, since the API
+	// server fills in others such as the image pull policy
+	if !sidecarsMatch(deployment.Spec.Template.Spec.Containers[1:], desiredDeployment.Spec.Template.Spec.Containers[1:]) {
+		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers[:1], desiredDeployment.Spec.Template.Spec.Containers[1:]...)
+		needsUpdate = true
+	}
+	if !initContainersMatch(deployment.Spec.Template.Spec.InitContainers, desiredDeployment.Spec.Template.Spec.InitContainers) {
+		deployment.Spec.Template.Spec.InitContainers = desiredDeployment.Spec.Template.Spec.InitContainers
+		needsUpdate = true
+	}
+
+	// Environment of the app container
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Containers[0].Env, desiredDeployment.Spec.Template.Spec.Containers[0].Env) ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Containers[0].EnvFrom, desiredDeployment.Spec.Template.Spec.Containers[0].EnvFrom) {
+		deployment.Spec.Template.Spec.Containers[0].Env = desiredDeployment.Spec.Template.Spec.Containers[0].Env
+		deployment.Spec.Template.Spec.Containers[0].EnvFrom = desiredDeployment.Spec.Template.Spec.Containers[0].EnvFrom
+		needsUpdate = true
+	}
+
+	// Startup probe of the app container, whose defaults are filled in on both sides
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Containers[0].StartupProbe, desiredDeployment.Spec.Template.Spec.Containers[0].StartupProbe) {
+		deployment.Spec.Template.Spec.Containers[0].StartupProbe = desiredDeployment.Spec.Template.Spec.Containers[0].StartupProbe
+		needsUpdate = true
+	}
+
+	// Readiness gate holding new pods back for the traffic delay
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.ReadinessGates, desiredDeployment.Spec.Template.Spec.ReadinessGates) {
+		deployment.Spec.Template.Spec.ReadinessGates = desiredDeployment.Spec.Template.Spec.ReadinessGates
+		needsUpdate = true
+	}
+
+	// Pod and container security settings
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.SecurityContext, desiredDeployment.Spec.Template.Spec.SecurityContext) ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Containers[0].SecurityContext, desiredDeployment.Spec.Template.Spec.Containers[0].SecurityContext) {
+		deployment.Spec.Template.Spec.SecurityContext = desiredDeployment.Spec.Template.Spec.SecurityContext
+		deployment.Spec.Template.Spec.Containers[0].SecurityContext = desiredDeployment.Spec.Template.Spec.Containers[0].SecurityContext
+		needsUpdate = true
+	}
+
+	// Scratch and persistent volumes and their mounts
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Volumes, desiredDeployment.Spec.Template.Spec.Volumes) ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.Containers[0].VolumeMounts, desiredDeployment.Spec.Template.Spec.Containers[0].VolumeMounts) {
+		deployment.Spec.Template.Spec.Volumes = desiredDeployment.Spec.Template.Spec.Volumes
+		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = desiredDeployment.Spec.Template.Spec.Containers[0].VolumeMounts
+		needsUpdate = true
+	}
+
+	// DNS settings and /etc/hosts entries
+	if deployment.Spec.Template.Spec.DNSPolicy != desiredDeployment.Spec.Template.Spec.DNSPolicy ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.DNSConfig, desiredDeployment.Spec.Template.Spec.DNSConfig) ||
+		!equality.Semantic.DeepEqual(deployment.Spec.Template.Spec.HostAliases, desiredDeployment.Spec.Template.Spec.HostAliases) {
+		deployment.Spec.Template.Spec.DNSPolicy = desiredDeployment.Spec.Template.Spec.DNSPolicy
+		deployment.Spec.Template.Spec.DNSConfig = desiredDeployment.Spec.Template.Spec.DNSConfig
+		deployment.Spec.Template.Spec.HostAliases = desiredDeployment.Spec.Template.Spec.HostAliases
+		needsUpdate = true
+	}
+
+	// Merge labels and annotations, keeping keys added by other controllers
+	// and removing the ones the operator set before but no longer wants
+	owned := ownedMetadataOf(deployment)
+	if syncOwned(&deployment.Labels, desiredDeployment.Labels, owned.Labels) {
+		needsUpdate = true
+	}
+	if syncOwned(&deployment.Annotations, desiredDeployment.Annotations, owned.Annotations) {
+		needsUpdate = true
+	}
+	if syncOwned(&deployment.Spec.Template.Labels, desiredDeployment.Spec.Template.Labels, owned.PodLabels) {
+		needsUpdate = true
+	}
+	if syncOwned(&deployment.Spec.Template.Annotations, desiredDeployment.Spec.Template.Annotations, owned.PodAnnotations) {
+		needsUpdate = true
+	}
+
+	if needsUpdate {
 		return r.Update(ctx, deployment)
 	}
 
 	return nil
 }
 
-func (r *WebAppReconciler) reconcileService(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+// reconcileServices creates or updates every Service exposing the WebApp,
+// deletes owned Services that are no longer wanted and records their URLs
+func (r *WebAppReconciler) reconcileServices(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	desired := r.desiredServices(webapp)
+	wanted := make(map[string]bool, len(desired))
+	var urls []string
+	for _, desiredService := range desired {
+		service, err := r.reconcileService(ctx, webapp, desiredService)
+		if err != nil {
+			return err
+		}
+		wanted[service.Name] = true
+		urls = append(urls, serviceURLs(service)...)
+	}
+
+	// Remove Services dropped from the spec
+	services := &corev1.ServiceList{}
+	if err := r.List(ctx, services, client.InNamespace(webapp.Namespace), client.MatchingLabels(r.selectorLabels(webapp))); err != nil {
+		return err
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		if wanted[service.Name] || !metav1.IsControlledBy(service, webapp) {
+			continue
+		}
+		if err := r.Delete(ctx, service); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	// spec.hostname is only published on a LoadBalancer Service
+	if webapp.Spec.Hostname != "" && webapp.Spec.Gateway == nil && loadBalancerService(desired) == nil {
+		r.updateCondition(webapp, "HostnamePublished", metav1.ConditionFalse, "NoLoadBalancerService",
+			fmt.Sprintf("Hostname %s is not published: no Service is of type LoadBalancer", webapp.Spec.Hostname))
+	} else {
+		meta.RemoveStatusCondition(&webapp.Status.Conditions, "HostnamePublished")
+	}
+
+	webapp.Status.ServiceURLs = urls
+	return nil
+}
+
+func (r *WebAppReconciler) reconcileService(ctx context.Context, webapp *appsv1alpha1.WebApp, desiredService *corev1.Service) (*corev1.Service, error) {
 	service := &corev1.Service{}
 	err := r.Get(ctx, types.NamespacedName{
-		Name:      webapp.Name,
-		Namespace: webapp.Namespace,
+		Name:      desiredService.Name,
+		Namespace: desiredService.Namespace,
 	}, service)
 
 	if err != nil && errors.IsNotFound(err) {
 		// Service doesn't exist, create it
-		service = r.createService(webapp)
-		if err := controllerutil.SetControllerReference(webapp, service, r.Scheme); err != nil {
-			return err
+		if err := controllerutil.SetControllerReference(webapp, desiredService, r.Scheme); err != nil {
+			return nil, err
 		}
-		return r.Create(ctx, service)
+		return desiredService, r.Create(ctx, desiredService)
 	} else if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Service exists, update if needed
-	desiredService := r.createService(webapp)
-	if !reflect.DeepEqual(service.Spec.Ports, desiredService.Spec.Ports) {
+	// ClusterIP is immutable, so switching headless mode recreates the Service
+	if (service.Spec.ClusterIP == corev1.ClusterIPNone) != (desiredService.Spec.ClusterIP == corev1.ClusterIPNone) {
+		if err := r.Delete(ctx, service); err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		if err := controllerutil.SetControllerReference(webapp, desiredService, r.Scheme); err != nil {
+			return nil, err
+		}
+		return desiredService, r.Create(ctx, desiredService)
+	}
+
+	// Keep node ports allocated by the API server so they aren't seen as drift
+	if desiredService.Spec.Type != corev1.ServiceTypeClusterIP && service.Spec.Type != corev1.ServiceTypeClusterIP {
+		for i := range desiredService.Spec.Ports {
+			for _, existing := range service.Spec.Ports {
+				if existing.Name == desiredService.Spec.Ports[i].Name {
+					desiredService.Spec.Ports[i].NodePort = existing.NodePort
+				}
+			}
+		}
+	}
+
+	if service.Spec.Type != desiredService.Spec.Type ||
+		!reflect.DeepEqual(service.Spec.Ports, desiredService.Spec.Ports) ||
+		!reflect.DeepEqual(service.Spec.Selector, desiredService.Spec.Selector) {
+		service.Spec.Type = desiredService.Spec.Type
 		service.Spec.Ports = desiredService.Spec.Ports
-		return r.Update(ctx, service)
+		service.Spec.Selector = desiredService.Spec.Selector
+		return service, r.Update(ctx, service)
 	}
 
-	return nil
+	if service.Spec.SessionAffinity != desiredService.Spec.SessionAffinity ||
+		!equality.Semantic.DeepEqual(service.Spec.SessionAffinityConfig, desiredService.Spec.SessionAffinityConfig) {
+		service.Spec.SessionAffinity = desiredService.Spec.SessionAffinity
+		service.Spec.SessionAffinityConfig = desiredService.Spec.SessionAffinityConfig
+		return service, r.Update(ctx, service)
+	}
+
+	// Labels follow the operator's label keys, so a ServiceMonitor selecting
+	// on them still finds the Service; keys added by others are kept
+	if mergeInto(&service.Labels, desiredService.Labels) {
+		return service, r.Update(ctx, service)
+	}
+
+	// The hostname annotation follows spec.hostname, including its removal
+	hostname := desiredService.Annotations[externalDNSHostnameAnnotation]
+	if service.Annotations[externalDNSHostnameAnnotation] != hostname {
+		if hostname == "" {
+			delete(service.Annotations, externalDNSHostnameAnnotation)
+		} else {
+			mergeInto(&service.Annotations, desiredService.Annotations)
+		}
+		return service, r.Update(ctx, service)
+	}
+
+	return service, nil
 }
 
-func (r *WebAppReconciler) createDeployment(webapp *appsv1alpha1.WebApp) *appsv1.Deployment {
-	replicas := webapp.Spec.Replicas
-	if replicas == 0 {
-		replicas = 1
+// sessionAffinity returns the WebApp's session affinity, defaulting to None
+func sessionAffinity(webapp *appsv1alpha1.WebApp) corev1.ServiceAffinity {
+	if webapp.Spec.SessionAffinity == "" {
+		return corev1.ServiceAffinityNone
+	}
+	return webapp.Spec.SessionAffinity
+}
+
+// sessionAffinityConfig returns the ClientIP timeout the API server would
+// otherwise default, so the Service isn't seen as drifting
+func sessionAffinityConfig(webapp *appsv1alpha1.WebApp) *corev1.SessionAffinityConfig {
+	if sessionAffinity(webapp) != corev1.ServiceAffinityClientIP {
+		return nil
+	}
+
+	timeout := corev1.DefaultClientIPServiceAffinitySeconds
+	if config := webapp.Spec.SessionAffinityConfig; config != nil && config.ClientIP != nil && config.ClientIP.TimeoutSeconds != nil {
+		timeout = *config.ClientIP.TimeoutSeconds
 	}
+	return &corev1.SessionAffinityConfig{
+		ClientIP: &corev1.ClientIPConfig{TimeoutSeconds: &timeout},
+	}
+}
+
+func (r *WebAppReconciler) createDeployment(webapp *appsv1alpha1.WebApp) *appsv1.Deployment {
+	replicas := desiredReplicas(webapp)
 
 	port := webapp.Spec.Port
 	if port == 0 {
 		port = 80
 	}
 
-	labels := map[string]string{
-		"app":        webapp.Name,
-		"managed-by": "webapp-operator",
+	dnsPolicy := webapp.Spec.DNSPolicy
+	if dnsPolicy == "" {
+		dnsPolicy = corev1.DNSClusterFirst
 	}
 
-	return &appsv1.Deployment{
+	labels := r.selectorLabels(webapp)
+
+	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      webapp.Name,
-			Namespace: webapp.Namespace,
-			Labels:    labels,
+			Name:        webapp.Name,
+			Namespace:   webapp.Namespace,
+			Labels:      mergeLabels(webapp.Spec.DeploymentLabels, labels),
+			Annotations: webapp.Spec.DeploymentAnnotations,
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+			Replicas:                &replicas,
+			Paused:                  webapp.Spec.RolloutPaused,
+			RevisionHistoryLimit:    webapp.Spec.RevisionHistoryLimit,
+			ProgressDeadlineSeconds: webapp.Spec.ProgressDeadlineSeconds,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels:      mergeLabels(mergeLabels(webapp.Spec.PodLabels, webapp.Spec.ExtraSelectorLabels), labels),
+					Annotations: webapp.Spec.PodAnnotations,
 				},
 				Spec: corev1.PodSpec{
+					PriorityClassName: webapp.Spec.PriorityClassName,
+					DNSPolicy:         dnsPolicy,
+					DNSConfig:         webapp.Spec.DNSConfig,
+					HostAliases:       webapp.Spec.HostAliases,
+					SecurityContext:   podSecurityContext(webapp),
+					ReadinessGates:    trafficReadinessGates(webapp),
 					Containers: []corev1.Container{
 						{
-							Name:  "webapp",
-							Image: webapp.Spec.Image,
+							Name:            "webapp",
+							Image:           webapp.Spec.Image,
+							SecurityContext: containerSecurityContext(webapp),
+							Env:             webapp.Spec.Env,
+							EnvFrom:         webapp.Spec.EnvFrom,
+							StartupProbe:    startupProbe(webapp),
 							Ports: []corev1.ContainerPort{
 								{
 									ContainerPort: port,
@@ -177,6 +622,483 @@ func (r *WebAppReconciler) createDeployment(webapp *appsv1alpha1.WebApp) *appsv1
 			},
 		},
 	}
+
+	if webapp.Spec.GPU != nil {
+		applyGPU(&deployment.Spec.Template.Spec, webapp.Spec.GPU)
+	}
+
+	if webapp.Spec.Autoscaling != nil {
+		replicas = replicaFloor(webapp)
+		applyCPURequest(&deployment.Spec.Template.Spec.Containers[0], webapp.Spec.Autoscaling)
+	}
+
+	for _, sidecar := range webapp.Spec.Sidecars {
+		container := corev1.Container{
+			Name:            sidecar.Name,
+			Image:           sidecar.Image,
+			Args:            sidecar.Args,
+			SecurityContext: containerSecurityContext(webapp),
+		}
+		for _, port := range sidecar.Ports {
+			container.Ports = append(container.Ports, corev1.ContainerPort{
+				Name:          port.Name,
+				ContainerPort: port.ContainerPort,
+				Protocol:      corev1.ProtocolTCP,
+			})
+		}
+		deployment.Spec.Template.Spec.Containers = append(deployment.Spec.Template.Spec.Containers, container)
+	}
+
+	for _, volume := range webapp.Spec.EmptyDirVolumes {
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: volume.Name,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{
+					Medium:    volume.Medium,
+					SizeLimit: volume.SizeLimit,
+				},
+			},
+		})
+		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = append(deployment.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: volume.MountPath,
+		})
+	}
+
+	if pv := webapp.Spec.PersistentVolume; pv != nil {
+		// Old and new pods must not run side by side, or the new pod can't attach the volume
+		if *deployment.Spec.Replicas > 1 {
+			*deployment.Spec.Replicas = 1
+		}
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: persistentVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pv.ClaimName,
+				},
+			},
+		})
+		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = append(deployment.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      persistentVolumeName,
+			MountPath: pv.MountPath,
+		})
+	}
+
+	if webapp.Spec.LogShipping != nil {
+		applyLogShipping(&deployment.Spec.Template.Spec, webapp)
+	}
+
+	return deployment
+}
+
+// podSecurityContext returns the WebApp's pod security context, or a
+// non-root default that satisfies the restricted Pod Security Standard
+func podSecurityContext(webapp *appsv1alpha1.WebApp) *corev1.PodSecurityContext {
+	if webapp.Spec.PodSecurityContext != nil {
+		return webapp.Spec.PodSecurityContext.DeepCopy()
+	}
+
+	runAsNonRoot := true
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot: &runAsNonRoot,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// containerSecurityContext returns the WebApp's container security context,
+// or a default that drops all capabilities and blocks privilege escalation
+func containerSecurityContext(webapp *appsv1alpha1.WebApp) *corev1.SecurityContext {
+	if webapp.Spec.SecurityContext != nil {
+		return webapp.Spec.SecurityContext.DeepCopy()
+	}
+
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// deploymentStrategyType returns the strategy type, treating unset as the
+// RollingUpdate the API server defaults it to
+func deploymentStrategyType(strategy appsv1.DeploymentStrategy) appsv1.DeploymentStrategyType {
+	if strategy.Type == "" {
+		return appsv1.RollingUpdateDeploymentStrategyType
+	}
+	return strategy.Type
+}
+
+// applyGPU sets the vendor GPU resources on the container and adds the usual
+// node selector and toleration for dedicated GPU nodes
+func applyGPU(podSpec *corev1.PodSpec, gpu *appsv1alpha1.GPUSpec) {
+	resourceName := corev1.ResourceName("nvidia.com/gpu")
+	nodeSelector := map[string]string{"nvidia.com/gpu.present": "true"}
+	if gpu.Vendor == "amd" {
+		resourceName = corev1.ResourceName("amd.com/gpu")
+		nodeSelector = map[string]string{"feature.node.kubernetes.io/amd-gpu": "true"}
+	}
+
+	// Extended resources need matching requests, which the API server would otherwise default
+	container := &podSpec.Containers[0]
+	quantity := *resource.NewQuantity(int64(gpu.Count), resource.DecimalSI)
+	if container.Resources.Limits == nil {
+		container.Resources.Limits = corev1.ResourceList{}
+	}
+	if container.Resources.Requests == nil {
+		container.Resources.Requests = corev1.ResourceList{}
+	}
+	container.Resources.Limits[resourceName] = quantity
+	container.Resources.Requests[resourceName] = quantity
+
+	if podSpec.NodeSelector == nil {
+		podSpec.NodeSelector = map[string]string{}
+	}
+	for k, v := range nodeSelector {
+		podSpec.NodeSelector[k] = v
+	}
+
+	podSpec.Tolerations = append(podSpec.Tolerations, corev1.Toleration{
+		Key:      string(resourceName),
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoSchedule,
+	})
+}
+
+// desiredServices returns the Services the WebApp should have: the default
+// Service, or one per entry in spec.services
+func (r *WebAppReconciler) desiredServices(webapp *appsv1alpha1.WebApp) []*corev1.Service {
+	if len(webapp.Spec.Services) == 0 {
+		return publishHostname(webapp, []*corev1.Service{r.createService(webapp)})
+	}
+
+	services := make([]*corev1.Service, 0, len(webapp.Spec.Services))
+	for i := range webapp.Spec.Services {
+		services = append(services, r.createAdditionalService(webapp, &webapp.Spec.Services[i]))
+	}
+
+	// Expose sidecar ports and metrics on the first internal Service only,
+	// so the ServiceMonitor scrapes each pod once
+	for _, service := range services {
+		if service.Spec.Type != corev1.ServiceTypeClusterIP {
+			continue
+		}
+		service.Spec.Ports = append(service.Spec.Ports, exposedSidecarPorts(webapp)...)
+		if metrics := webapp.Spec.Metrics; metrics != nil && servicePortName(service, metrics.Port) == "" {
+			service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+				Name:       "metrics",
+				Port:       metrics.Port,
+				TargetPort: intstr.FromInt(int(metrics.Port)),
+				Protocol:   corev1.ProtocolTCP,
+			})
+		}
+		break
+	}
+	return publishHostname(webapp, services)
+}
+
+// publishHostname annotates the first LoadBalancer Service with
+// spec.hostname so external-dns creates a record for it. Other Service types
+// have no address a DNS name could point to, so they are left alone. With a
+// Gateway the name belongs to the HTTPRoute instead.
+func publishHostname(webapp *appsv1alpha1.WebApp, services []*corev1.Service) []*corev1.Service {
+	if webapp.Spec.Hostname == "" || webapp.Spec.Gateway != nil {
+		return services
+	}
+	if service := loadBalancerService(services); service != nil {
+		service.Annotations = map[string]string{
+			externalDNSHostnameAnnotation: webapp.Spec.Hostname,
+		}
+	}
+	return services
+}
+
+// loadBalancerService returns the first LoadBalancer Service, or nil when
+// there is none
+func loadBalancerService(services []*corev1.Service) *corev1.Service {
+	for _, service := range services {
+		if service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+			return service
+		}
+	}
+	return nil
+}
+
+// exposedSidecarPorts returns Service ports for the sidecar ports marked
+// exposeThroughService
+func exposedSidecarPorts(webapp *appsv1alpha1.WebApp) []corev1.ServicePort {
+	var ports []corev1.ServicePort
+	for _, sidecar := range webapp.Spec.Sidecars {
+		for _, port := range sidecar.Ports {
+			if !port.ExposeThroughService {
+				continue
+			}
+			ports = append(ports, corev1.ServicePort{
+				Name:       port.Name,
+				Port:       port.ContainerPort,
+				TargetPort: intstr.FromInt(int(port.ContainerPort)),
+				Protocol:   corev1.ProtocolTCP,
+			})
+		}
+	}
+	return ports
+}
+
+// sidecarsMatch reports whether the existing sidecar containers carry the
+// desired name, image, args, ports and security context
+func sidecarsMatch(existing, desired []corev1.Container) bool {
+	if len(existing) != len(desired) {
+		return false
+	}
+	for i := range desired {
+		if existing[i].Name != desired[i].Name || existing[i].Image != desired[i].Image ||
+			!equality.Semantic.DeepEqual(existing[i].Args, desired[i].Args) ||
+			!equality.Semantic.DeepEqual(existing[i].Ports, desired[i].Ports) ||
+			!equality.Semantic.DeepEqual(existing[i].SecurityContext, desired[i].SecurityContext) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *WebAppReconciler) createAdditionalService(webapp *appsv1alpha1.WebApp, spec *appsv1alpha1.ServiceSpec) *corev1.Service {
+	name := webapp.Name
+	if spec.NameSuffix != "" {
+		name = fmt.Sprintf("%s-%s", webapp.Name, spec.NameSuffix)
+	}
+
+	serviceType := spec.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+
+	labels := r.selectorLabels(webapp)
+
+	var ports []corev1.ServicePort
+	for _, p := range spec.Ports {
+		targetPort := p.TargetPort
+		if targetPort == 0 {
+			targetPort = p.Port
+		}
+		ports = append(ports, corev1.ServicePort{
+			Name:       p.Name,
+			Port:       p.Port,
+			TargetPort: intstr.FromInt(int(targetPort)),
+			Protocol:   corev1.ProtocolTCP,
+		})
+	}
+	if len(ports) == 0 {
+		port := webapp.Spec.Port
+		if port == 0 {
+			port = 80
+		}
+		ports = []corev1.ServicePort{
+			{
+				Name:       "http",
+				Port:       port,
+				TargetPort: intstr.FromInt(int(port)),
+				Protocol:   corev1.ProtocolTCP,
+			},
+		}
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: webapp.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector:              serviceSelector(webapp, labels),
+			Type:                  serviceType,
+			Ports:                 ports,
+			SessionAffinity:       sessionAffinity(webapp),
+			SessionAffinityConfig: sessionAffinityConfig(webapp),
+		},
+	}
+}
+
+// serviceSelector selects the WebApp's pods, narrowed to the active color
+// while the BlueGreen strategy is in use
+func serviceSelector(webapp *appsv1alpha1.WebApp, labels map[string]string) map[string]string {
+	selector := mergeLabels(webapp.Spec.ExtraSelectorLabels, labels)
+	if webapp.Status.ActiveColor != "" {
+		selector[colorLabel] = webapp.Status.ActiveColor
+	}
+	return selector
+}
+
+// validateServices rejects Service entries that would collide with each other
+func validateServices(services []appsv1alpha1.ServiceSpec) error {
+	suffixes := make(map[string]bool, len(services))
+	for _, service := range services {
+		if suffixes[service.NameSuffix] {
+			return fmt.Errorf("duplicate service nameSuffix %q", service.NameSuffix)
+		}
+		suffixes[service.NameSuffix] = true
+
+		names := make(map[string]bool, len(service.Ports))
+		for _, port := range service.Ports {
+			if names[port.Name] {
+				return fmt.Errorf("duplicate port name %q in service %q", port.Name, service.NameSuffix)
+			}
+			names[port.Name] = true
+		}
+	}
+	return nil
+}
+
+// validateSidecars rejects sidecars whose names or ports clash with each
+// other or with the app container, which shares the pod's network namespace
+func validateSidecars(webapp *appsv1alpha1.WebApp) error {
+	appPort := webapp.Spec.Port
+	if appPort == 0 {
+		appPort = 80
+	}
+
+	containers := map[string]bool{"webapp": true}
+	portNames := make(map[string]bool)
+	portNumbers := map[int32]string{appPort: "webapp"}
+	for _, sidecar := range webapp.Spec.Sidecars {
+		if containers[sidecar.Name] {
+			return fmt.Errorf("sidecar name %q is already used by another container", sidecar.Name)
+		}
+		containers[sidecar.Name] = true
+
+		for _, port := range sidecar.Ports {
+			if portNames[port.Name] {
+				return fmt.Errorf("duplicate port name %q in sidecar %q", port.Name, sidecar.Name)
+			}
+			portNames[port.Name] = true
+
+			if owner, ok := portNumbers[port.ContainerPort]; ok {
+				return fmt.Errorf("sidecar %q port %d is already used by container %q", sidecar.Name, port.ContainerPort, owner)
+			}
+			portNumbers[port.ContainerPort] = sidecar.Name
+		}
+	}
+	return nil
+}
+
+// validateServicePorts rejects Services whose ports share a name or number,
+// e.g. an exposed sidecar port clashing with the app's own port
+func validateServicePorts(services []*corev1.Service) error {
+	for _, service := range services {
+		names := make(map[string]bool, len(service.Spec.Ports))
+		numbers := make(map[int32]bool, len(service.Spec.Ports))
+		for _, port := range service.Spec.Ports {
+			if names[port.Name] {
+				return fmt.Errorf("service %q has more than one port named %q", service.Name, port.Name)
+			}
+			names[port.Name] = true
+			if numbers[port.Port] {
+				return fmt.Errorf("service %q has more than one port %d", service.Name, port.Port)
+			}
+			numbers[port.Port] = true
+		}
+	}
+	return nil
+}
+
+// validateSessionAffinity rejects ClientIP timeouts outside the range the API server accepts
+func validateSessionAffinity(webapp *appsv1alpha1.WebApp) error {
+	config := webapp.Spec.SessionAffinityConfig
+	if config == nil || config.ClientIP == nil || config.ClientIP.TimeoutSeconds == nil {
+		return nil
+	}
+	if timeout := *config.ClientIP.TimeoutSeconds; timeout < 1 || timeout > maxClientIPAffinitySeconds {
+		return fmt.Errorf("sessionAffinityConfig timeout must be between 1 and %d seconds", maxClientIPAffinitySeconds)
+	}
+	return nil
+}
+
+// validateEmptyDirVolumes rejects volumes that would share a name or mount path
+func validateEmptyDirVolumes(volumes []appsv1alpha1.EmptyDirVolume) error {
+	names := make(map[string]bool, len(volumes))
+	paths := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		if names[volume.Name] {
+			return fmt.Errorf("duplicate emptyDir volume name %q", volume.Name)
+		}
+		names[volume.Name] = true
+
+		mountPath := path.Clean(volume.MountPath)
+		if paths[mountPath] {
+			return fmt.Errorf("emptyDir volumes share mount path %q", volume.MountPath)
+		}
+		paths[mountPath] = true
+	}
+	return nil
+}
+
+// validatePersistentVolume rejects a PVC mount that would clash with the
+// scratch volumes or that the BlueGreen strategy couldn't honour
+func validatePersistentVolume(webapp *appsv1alpha1.WebApp) error {
+	pv := webapp.Spec.PersistentVolume
+	if pv == nil {
+		return nil
+	}
+	if webapp.Spec.Strategy == strategyBlueGreen {
+		return fmt.Errorf("persistentVolume cannot be used with the BlueGreen strategy, which runs two versions at once")
+	}
+	for _, volume := range webapp.Spec.EmptyDirVolumes {
+		if volume.Name == persistentVolumeName {
+			return fmt.Errorf("emptyDir volume name %q is reserved for the persistent volume", volume.Name)
+		}
+		if path.Clean(volume.MountPath) == path.Clean(pv.MountPath) {
+			return fmt.Errorf("persistent volume and emptyDir volume %q share mount path %q", volume.Name, pv.MountPath)
+		}
+	}
+	return nil
+}
+
+// validateDNS rejects DNS settings the API server would refuse on the pod
+func validateDNS(webapp *appsv1alpha1.WebApp) error {
+	if webapp.Spec.DNSPolicy == corev1.DNSNone &&
+		(webapp.Spec.DNSConfig == nil || len(webapp.Spec.DNSConfig.Nameservers) == 0) {
+		return fmt.Errorf("dnsPolicy None requires dnsConfig with at least one nameserver")
+	}
+	for _, alias := range webapp.Spec.HostAliases {
+		if net.ParseIP(alias.IP) == nil {
+			return fmt.Errorf("hostAlias IP %q is not a valid IP address", alias.IP)
+		}
+	}
+	return nil
+}
+
+// servicePortName returns the name of the Service port listening on port, if any
+func servicePortName(service *corev1.Service, port int32) string {
+	for _, p := range service.Spec.Ports {
+		if p.Port == port {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// serviceURLs returns the in-cluster address of a Service and, for
+// LoadBalancers, any external addresses assigned so far
+func serviceURLs(service *corev1.Service) []string {
+	if len(service.Spec.Ports) == 0 {
+		return nil
+	}
+	port := service.Spec.Ports[0].Port
+
+	urls := []string{fmt.Sprintf("%s.%s.svc.cluster.local:%d", service.Name, service.Namespace, port)}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		host := ingress.IP
+		if ingress.Hostname != "" {
+			host = ingress.Hostname
+		}
+		if host != "" {
+			urls = append(urls, fmt.Sprintf("%s:%d", host, port))
+		}
+	}
+	return urls
 }
 
 func (r *WebAppReconciler) createService(webapp *appsv1alpha1.WebApp) *corev1.Service {
@@ -185,40 +1107,162 @@ func (r *WebAppReconciler) createService(webapp *appsv1alpha1.WebApp) *corev1.Se
 		port = 80
 	}
 
-	labels := map[string]string{
-		"app":        webapp.Name,
-		"managed-by": "webapp-operator",
+	labels := r.selectorLabels(webapp)
+
+	ports := []corev1.ServicePort{
+		{
+			Name:       "http",
+			Port:       port,
+			TargetPort: intstr.FromInt(int(port)),
+			Protocol:   corev1.ProtocolTCP,
+		},
 	}
+	ports = append(ports, exposedSidecarPorts(webapp)...)
 
-	return &corev1.Service{
+	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      webapp.Name,
 			Namespace: webapp.Namespace,
 			Labels:    labels,
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: labels,
-			Type:     corev1.ServiceTypeClusterIP,
-			Ports: []corev1.ServicePort{
-				{
-					Port:       port,
-					TargetPort: intstr.FromInt(int(port)),
-					Protocol:   corev1.ProtocolTCP,
-				},
+			Selector:              serviceSelector(webapp, labels),
+			Type:                  corev1.ServiceTypeClusterIP,
+			Ports:                 ports,
+			SessionAffinity:       sessionAffinity(webapp),
+			SessionAffinityConfig: sessionAffinityConfig(webapp),
+		},
+	}
+	if webapp.Spec.Headless {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+
+	return service
+}
+
+// reconcileServiceMonitor creates, updates or deletes the WebApp's
+// ServiceMonitor. It is a no-op when the ServiceMonitor CRD is not installed.
+func (r *WebAppReconciler) reconcileServiceMonitor(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	log := log.FromContext(ctx)
+
+	installed, err := r.serviceMonitorInstalled()
+	if err != nil {
+		return err
+	}
+	if !installed {
+		if webapp.Spec.Metrics != nil {
+			log.Info("ServiceMonitor CRD not installed, skipping")
+			r.updateCondition(webapp, "Metrics", metav1.ConditionFalse, "ServiceMonitorUnavailable",
+				"monitoring.coreos.com/v1 ServiceMonitor is not installed in the cluster")
+		} else {
+			meta.RemoveStatusCondition(&webapp.Status.Conditions, "Metrics")
+		}
+		return nil
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(serviceMonitorGVK)
+	err = r.Get(ctx, types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	// Metrics removed, delete the ServiceMonitor we own
+	if webapp.Spec.Metrics == nil {
+		meta.RemoveStatusCondition(&webapp.Status.Conditions, "Metrics")
+		if found && metav1.IsControlledBy(existing, webapp) {
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil
+	}
+
+	desired := r.createServiceMonitor(webapp)
+	if !found {
+		if err := controllerutil.SetControllerReference(webapp, desired, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return err
+		}
+	} else if !equality.Semantic.DeepEqual(existing.Object["spec"], desired.Object["spec"]) {
+		existing.Object["spec"] = desired.Object["spec"]
+		if err := r.Update(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	r.updateCondition(webapp, "Metrics", metav1.ConditionTrue, "ServiceMonitorReady", "ServiceMonitor is configured")
+	return nil
+}
+
+func (r *WebAppReconciler) createServiceMonitor(webapp *appsv1alpha1.WebApp) *unstructured.Unstructured {
+	metrics := webapp.Spec.Metrics
+
+	// Scrape whichever named Service port serves the metrics port
+	portName := "metrics"
+	for _, service := range r.desiredServices(webapp) {
+		if name := servicePortName(service, metrics.Port); name != "" {
+			portName = name
+			break
+		}
+	}
+	path := metrics.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	interval := metrics.Interval
+	if interval == "" {
+		interval = "30s"
+	}
+
+	monitor := &unstructured.Unstructured{}
+	monitor.SetGroupVersionKind(serviceMonitorGVK)
+	monitor.SetName(webapp.Name)
+	monitor.SetNamespace(webapp.Namespace)
+	labels := r.selectorLabels(webapp)
+	matchLabels := make(map[string]interface{}, len(labels))
+	for key, value := range labels {
+		matchLabels[key] = value
+	}
+	monitor.SetLabels(labels)
+	monitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": matchLabels,
+		},
+		"endpoints": []interface{}{
+			map[string]interface{}{
+				"port":     portName,
+				"path":     path,
+				"interval": interval,
 			},
 		},
 	}
+	return monitor
+}
+
+// serviceMonitorInstalled reports whether the cluster serves the ServiceMonitor CRD
+func (r *WebAppReconciler) serviceMonitorInstalled() (bool, error) {
+	_, err := r.RESTMapper().RESTMapping(serviceMonitorGVK.GroupKind(), serviceMonitorGVK.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
 }
 
 func (r *WebAppReconciler) updateStatus(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
-	// Get the Deployment to check available replicas
+	// Get the Deployment serving traffic to check available replicas
 	deployment := &appsv1.Deployment{}
 	err := r.Get(ctx, types.NamespacedName{
-		Name:      webapp.Name,
+		Name:      activeDeploymentName(webapp),
 		Namespace: webapp.Namespace,
 	}, deployment)
 
-	if err != nil {
+	if err != nil && errors.IsNotFound(err) && webapp.Spec.Strategy == strategyBlueGreen {
+		// The first color hasn't been promoted yet
+		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "RolloutPending", "Waiting for the first color to become ready")
+		return r.Status().Update(ctx, webapp)
+	} else if err != nil {
 		return err
 	}
 
@@ -228,15 +1272,60 @@ func (r *WebAppReconciler) updateStatus(ctx context.Context, webapp *appsv1alpha
 	// Update service URL
 	webapp.Status.ServiceURL = fmt.Sprintf("%s.%s.svc.cluster.local:%d",
 		webapp.Name, webapp.Namespace, webapp.Spec.Port)
+	if len(webapp.Spec.Services) > 0 && len(webapp.Status.ServiceURLs) > 0 {
+		webapp.Status.ServiceURL = webapp.Status.ServiceURLs[0]
+	}
+
+	// The health check keeps using the in-cluster address, which works
+	// before external-dns has published the hostname
+	healthCheckURL := webapp.Status.ServiceURL
+	if webapp.Spec.Hostname != "" && webapp.Spec.Gateway == nil {
+		if service := loadBalancerService(r.desiredServices(webapp)); service != nil {
+			webapp.Status.ServiceURL = fmt.Sprintf("%s:%d", webapp.Spec.Hostname, service.Spec.Ports[0].Port)
+		}
+	}
 
 	// Update condition
-	if deployment.Status.AvailableReplicas == *deployment.Spec.Replicas {
-		r.updateCondition(webapp, "Ready", metav1.ConditionTrue, "AllReplicasReady", "All replicas are ready")
+	if *deployment.Spec.Replicas == 0 {
+		r.updateCondition(webapp, "Ready", metav1.ConditionTrue, "ScaledToZero",
+			fmt.Sprintf("Scaled to zero, set the %s annotation to wake it", activateAnnotation))
+	} else if deployment.Status.AvailableReplicas == *deployment.Spec.Replicas {
+		if check := webapp.Spec.ExternalHealthCheck; check != nil {
+			if err := checkHealth(ctx, healthCheckURL, check); err != nil {
+				r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "HealthCheckFailed", err.Error())
+			} else {
+				r.updateCondition(webapp, "Ready", metav1.ConditionTrue, "HealthCheckPassed", "All replicas are ready and the health check passed")
+			}
+		} else {
+			r.updateCondition(webapp, "Ready", metav1.ConditionTrue, "AllReplicasReady", "All replicas are ready")
+		}
 	} else {
 		r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "ReplicasNotReady",
 			fmt.Sprintf("%d/%d replicas ready", deployment.Status.AvailableReplicas, *deployment.Spec.Replicas))
 	}
 
+	// A pod running another digest than the pinned one was tampered with or
+	// served from a stale cache, so it must not count as ready
+	if r.VerifyImageDigests {
+		verified, err := r.verifyImageDigest(ctx, webapp, deployment)
+		if err != nil {
+			return err
+		}
+		if !verified {
+			r.updateCondition(webapp, "Ready", metav1.ConditionFalse, "DigestMismatch",
+				"A pod runs an image digest other than the one spec.image is pinned to")
+		}
+	} else {
+		meta.RemoveStatusCondition(&webapp.Status.Conditions, "DigestMismatch")
+	}
+
+	// Mirror rollout progress, which reports ProgressDeadlineExceeded when a rollout stalls
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing {
+			r.updateCondition(webapp, "Progressing", metav1.ConditionStatus(c.Status), c.Reason, c.Message)
+		}
+	}
+
 	return r.Status().Update(ctx, webapp)
 }
 
@@ -264,10 +1353,138 @@ func (r *WebAppReconciler) updateCondition(webapp *appsv1alpha1.WebApp, conditio
 	}
 }
 
+// healthCheckClient doesn't follow redirects, so a 3xx answer is compared
+// against the expected status rather than whatever the redirect points to
+var healthCheckClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// checkHealth GETs path on the Service at serviceURL and fails unless it
+// answers with the expected status code within the timeout
+func checkHealth(ctx context.Context, serviceURL string, check *appsv1alpha1.HealthCheckSpec) error {
+	path := check.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	expected := int(check.ExpectedStatus)
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	timeout := time.Duration(check.TimeoutSeconds) * time.Second
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	url := "http://" + serviceURL + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := healthCheckClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("health check %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expected {
+		return fmt.Errorf("health check %s returned %d, expected %d", url, resp.StatusCode, expected)
+	}
+	return nil
+}
+
+// int32Value dereferences p, falling back to def when p is nil
+func int32Value(p *int32, def int32) int32 {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// validateImage checks that image is a well-formed reference and, when
+// rejectLatest is set, that it is pinned to a version tag or digest
+func validateImage(image string, rejectLatest bool) error {
+	if !imageReferencePattern.MatchString(image) {
+		return fmt.Errorf("image %q is not a valid image reference", image)
+	}
+
+	if !rejectLatest || strings.Contains(image, "@") {
+		return nil
+	}
+
+	tag := ""
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		tag = name[i+1:]
+	}
+
+	if tag == "" || tag == "latest" {
+		return fmt.Errorf("image %q must be pinned to a version tag or digest (for example nginx:1.25 or nginx@sha256:...), the latest tag is not allowed", image)
+	}
+
+	return nil
+}
+
+// mergeLabels combines user-supplied labels with the operator's required
+// labels. Required labels win so the Deployment selector keeps matching.
+func mergeLabels(custom, required map[string]string) map[string]string {
+	merged := make(map[string]string, len(custom)+len(required))
+	for k, v := range custom {
+		merged[k] = v
+	}
+	for k, v := range required {
+		merged[k] = v
+	}
+	return merged
+}
+
+// mergeInto copies desired entries into dst, reporting whether dst changed.
+// Keys present only in dst are left in place.
+func mergeInto(dst *map[string]string, desired map[string]string) bool {
+	changed := false
+	for k, v := range desired {
+		if current, ok := (*dst)[k]; ok && current == v {
+			continue
+		}
+		if *dst == nil {
+			*dst = make(map[string]string, len(desired))
+		}
+		(*dst)[k] = v
+		changed = true
+	}
+	return changed
+}
+
 func (r *WebAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&appsv1alpha1.WebApp{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
-		Complete(r)
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		// Roll the pods when a ConfigMap or Secret they read changes
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.findWebAppsForConfig(false))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findWebAppsForConfig(true))).
+		// Count down the traffic delay of pods whose containers became ready
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.findWebAppForPod))
+
+	// Only watch ServiceMonitors when the Prometheus Operator is installed
+	if _, err := mgr.GetRESTMapper().RESTMapping(serviceMonitorGVK.GroupKind(), serviceMonitorGVK.Version); err == nil {
+		monitor := &unstructured.Unstructured{}
+		monitor.SetGroupVersionKind(serviceMonitorGVK)
+		builder = builder.Owns(monitor)
+	}
+
+	// Likewise only watch HTTPRoutes when the Gateway API is installed
+	if _, err := mgr.GetRESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version); err == nil {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		builder = builder.Owns(route)
+	}
+
+	return builder.Complete(r)
 }
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// verifyImageDigest checks that every pod started from the current pod
+// template runs the digest spec.image is pinned to, as reported by the
+// kubelet in the container's imageID, and sets the DigestMismatch condition.
+// It reports whether no pod runs an unexpected digest.
+func (r *WebAppReconciler) verifyImageDigest(ctx context.Context, webapp *appsv1alpha1.WebApp, deployment *appsv1.Deployment) (bool, error) {
+	expected := imageDigest(webapp.Spec.Image)
+	if expected == "" {
+		// Nothing to verify against without a pinned digest
+		meta.RemoveStatusCondition(&webapp.Status.Conditions, "DigestMismatch")
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return false, err
+	}
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(webapp.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return false, err
+	}
+
+	var mismatched []string
+	for _, pod := range pods.Items {
+		// Pods of an older template are expected to run the previous image
+		if !podRunsImage(&pod, webapp.Spec.Image) {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != "webapp" {
+				continue
+			}
+			// Runtimes that report only the image ID, or a container that
+			// hasn't pulled yet, leave nothing to compare
+			if running := imageDigest(status.ImageID); running != "" && running != expected {
+				mismatched = append(mismatched, fmt.Sprintf("%s runs %s", pod.Name, running))
+			}
+		}
+	}
+
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		r.updateCondition(webapp, "DigestMismatch", metav1.ConditionTrue, "UnexpectedDigest",
+			fmt.Sprintf("Expected %s, but %s", expected, strings.Join(mismatched, ", ")))
+		return false, nil
+	}
+	r.updateCondition(webapp, "DigestMismatch", metav1.ConditionFalse, "DigestVerified",
+		fmt.Sprintf("All pods run %s", expected))
+	return true, nil
+}
+
+// podRunsImage reports whether the pod's app container was created with image
+func podRunsImage(pod *corev1.Pod, image string) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "webapp" {
+			return container.Image == image
+		}
+	}
+	return false
+}
+
+// imageDigest returns the digest of an image reference or imageID pinned by
+// digest, such as nginx@sha256:..., or empty when it isn't pinned
+func imageDigest(image string) string {
+	if i := strings.LastIndex(image, "@"); i >= 0 {
+		return image[i+1:]
+	}
+	return ""
+}
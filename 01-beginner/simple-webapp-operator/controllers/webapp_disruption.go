@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+const (
+	defaultTargetCPUUtilization int32 = 80
+
+	// defaultMaxUnavailable lets a quarter of the pods be evicted at once
+	defaultMaxUnavailable = "25%"
+)
+
+// defaultCPURequest is requested by the app container when autoscaling is
+// enabled, since CPU utilization is measured against the request
+var defaultCPURequest = resource.MustParse("100m")
+
+// reconcileAutoscaler creates or updates the WebApp's HorizontalPodAutoscaler,
+// or deletes it once autoscaling is turned off
+func (r *WebAppReconciler) reconcileAutoscaler(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	existing := &autoscalingv2.HorizontalPodAutoscaler{}
+	err := r.Get(ctx, types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	if webapp.Spec.Autoscaling == nil {
+		if found && metav1.IsControlledBy(existing, webapp) {
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil
+	}
+
+	desired := desiredAutoscaler(webapp)
+	if !found {
+		if err := controllerutil.SetControllerReference(webapp, desired, r.Scheme); err != nil {
+			return err
+		}
+		return r.Create(ctx, desired)
+	}
+
+	// Only the fields the operator sets are compared, since the API server
+	// fills in the default scaling behavior
+	if !equality.Semantic.DeepEqual(existing.Spec.ScaleTargetRef, desired.Spec.ScaleTargetRef) ||
+		!equality.Semantic.DeepEqual(existing.Spec.MinReplicas, desired.Spec.MinReplicas) ||
+		existing.Spec.MaxReplicas != desired.Spec.MaxReplicas ||
+		!equality.Semantic.DeepEqual(existing.Spec.Metrics, desired.Spec.Metrics) {
+		existing.Spec.ScaleTargetRef = desired.Spec.ScaleTargetRef
+		existing.Spec.MinReplicas = desired.Spec.MinReplicas
+		existing.Spec.MaxReplicas = desired.Spec.MaxReplicas
+		existing.Spec.Metrics = desired.Spec.Metrics
+		return r.Update(ctx, existing)
+	}
+	return nil
+}
+
+func desiredAutoscaler(webapp *appsv1alpha1.WebApp) *autoscalingv2.HorizontalPodAutoscaler {
+	autoscaling := webapp.Spec.Autoscaling
+	minReplicas := replicaFloor(webapp)
+	target := autoscaling.TargetCPUUtilizationPercentage
+	if target == 0 {
+		target = defaultTargetCPUUtilization
+	}
+
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webapp.Name,
+			Namespace: webapp.Namespace,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       webapp.Name,
+			},
+			MinReplicas: &minReplicas,
+			MaxReplicas: autoscaling.MaxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{
+				{
+					Type: autoscalingv2.ResourceMetricSourceType,
+					Resource: &autoscalingv2.ResourceMetricSource{
+						Name: corev1.ResourceCPU,
+						Target: autoscalingv2.MetricTarget{
+							Type:               autoscalingv2.UtilizationMetricType,
+							AverageUtilization: &target,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// applyCPURequest requests CPU for the app container so the autoscaler can
+// measure utilization
+func applyCPURequest(container *corev1.Container, autoscaling *appsv1alpha1.AutoscalingSpec) {
+	request := defaultCPURequest
+	if autoscaling.CPURequest != nil {
+		request = *autoscaling.CPURequest
+	}
+	if container.Resources.Requests == nil {
+		container.Resources.Requests = corev1.ResourceList{}
+	}
+	container.Resources.Requests[corev1.ResourceCPU] = request
+}
+
+// reconcileDisruptionBudget creates or updates the WebApp's
+// PodDisruptionBudget, or deletes it once the budget is removed. The
+// DisruptionBudget condition warns when node drains can't evict any pod.
+func (r *WebAppReconciler) reconcileDisruptionBudget(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	existing := &policyv1.PodDisruptionBudget{}
+	err := r.Get(ctx, types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	if webapp.Spec.DisruptionBudget == nil {
+		meta.RemoveStatusCondition(&webapp.Status.Conditions, "DisruptionBudget")
+		if found && metav1.IsControlledBy(existing, webapp) {
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil
+	}
+
+	maxUnavailable, raised := disruptionBudgetMaxUnavailable(webapp)
+	desired := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webapp.Name,
+			Namespace: webapp.Namespace,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: r.selectorLabels(webapp),
+			},
+		},
+	}
+
+	if !found {
+		if err := controllerutil.SetControllerReference(webapp, desired, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return err
+		}
+	} else if !equality.Semantic.DeepEqual(existing.Spec.MaxUnavailable, desired.Spec.MaxUnavailable) ||
+		!equality.Semantic.DeepEqual(existing.Spec.Selector, desired.Spec.Selector) {
+		existing.Spec.MaxUnavailable = desired.Spec.MaxUnavailable
+		existing.Spec.Selector = desired.Spec.Selector
+		if err := r.Update(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	// The PDB's status is only meaningful once it reflects the current spec
+	status := existing.Status
+	current := found && status.ObservedGeneration == existing.Generation
+	switch {
+	case raised:
+		r.updateCondition(webapp, "DisruptionBudget", metav1.ConditionFalse, "MaxUnavailableRaised",
+			fmt.Sprintf("maxUnavailable %s allows no evictions with %d replicas, using 1 so node drains can proceed",
+				webapp.Spec.DisruptionBudget.MaxUnavailable.String(), replicaFloor(webapp)))
+	case current && status.ExpectedPods > 0 && status.DisruptionsAllowed == 0:
+		r.updateCondition(webapp, "DisruptionBudget", metav1.ConditionFalse, "DisruptionsBlocked",
+			fmt.Sprintf("%d of %d pods are healthy, node drains can't evict any pod until more are ready",
+				status.CurrentHealthy, status.ExpectedPods))
+	default:
+		r.updateCondition(webapp, "DisruptionBudget", metav1.ConditionTrue, "DisruptionsAllowed",
+			fmt.Sprintf("Up to %d pod(s) may be evicted at once", maxUnavailable.IntValue()))
+	}
+	return nil
+}
+
+// disruptionBudgetMaxUnavailable resolves the configured maxUnavailable
+// against the replica floor, so the PDB never forbids every eviction when the
+// autoscaler sits at minReplicas. It reports whether the value was raised to 1.
+func disruptionBudgetMaxUnavailable(webapp *appsv1alpha1.WebApp) (intstr.IntOrString, bool) {
+	configured := intstr.FromString(defaultMaxUnavailable)
+	if webapp.Spec.DisruptionBudget.MaxUnavailable != nil {
+		configured = *webapp.Spec.DisruptionBudget.MaxUnavailable
+	}
+
+	// Percentages round up, as the disruption controller does for maxUnavailable
+	resolved, err := intstr.GetScaledValueFromIntOrPercent(&configured, int(replicaFloor(webapp)), true)
+	if err != nil || resolved < 1 {
+		return intstr.FromInt32(1), true
+	}
+	return intstr.FromInt32(int32(resolved)), false
+}
+
+// replicaFloor is the fewest pods the WebApp runs: autoscaling.minReplicas
+// when autoscaling, otherwise spec.replicas
+func replicaFloor(webapp *appsv1alpha1.WebApp) int32 {
+	if webapp.Spec.PersistentVolume != nil {
+		return 1
+	}
+	if autoscaling := webapp.Spec.Autoscaling; autoscaling != nil {
+		if autoscaling.MinReplicas == 0 {
+			return 1
+		}
+		return autoscaling.MinReplicas
+	}
+	if replicas := int32Value(webapp.Spec.Replicas, 1); replicas != 0 {
+		return replicas
+	}
+	return 1
+}
+
+// validateAutoscaling rejects autoscaling settings the operator can't honor
+func validateAutoscaling(webapp *appsv1alpha1.WebApp) error {
+	autoscaling := webapp.Spec.Autoscaling
+	if autoscaling == nil {
+		return nil
+	}
+	if webapp.Spec.Strategy == strategyBlueGreen {
+		return fmt.Errorf("autoscaling can't be used with the BlueGreen strategy, which switches between Deployments")
+	}
+	if webapp.Spec.PersistentVolume != nil {
+		return fmt.Errorf("autoscaling can't be used with persistentVolume, which pins the Deployment to one replica")
+	}
+	if autoscaling.MaxReplicas < replicaFloor(webapp) {
+		return fmt.Errorf("autoscaling.maxReplicas %d is below minReplicas %d", autoscaling.MaxReplicas, replicaFloor(webapp))
+	}
+	if request := autoscaling.CPURequest; request != nil && request.Sign() <= 0 {
+		return fmt.Errorf("autoscaling.cpuRequest must be positive")
+	}
+	return nil
+}
+
+// validateDisruptionBudget rejects a maxUnavailable that is neither a number
+// nor a percentage
+func validateDisruptionBudget(webapp *appsv1alpha1.WebApp) error {
+	budget := webapp.Spec.DisruptionBudget
+	if budget == nil || budget.MaxUnavailable == nil {
+		return nil
+	}
+	if _, err := intstr.GetScaledValueFromIntOrPercent(budget.MaxUnavailable, 1, true); err != nil {
+		return fmt.Errorf("invalid disruptionBudget.maxUnavailable: %w", err)
+	}
+	return nil
+}
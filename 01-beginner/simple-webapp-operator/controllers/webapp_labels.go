@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// Default keys and value of the labels identifying a WebApp's resources
+const (
+	defaultAppLabelKey       = "app"
+	defaultManagedByLabelKey = "managed-by"
+	defaultManagedByValue    = "webapp-operator"
+)
+
+// selectorLabels are the labels the operator sets on a WebApp's resources
+// and selects its pods by: the WebApp's name under the app key and the
+// operator under the managed-by key
+func (r *WebAppReconciler) selectorLabels(webapp *appsv1alpha1.WebApp) map[string]string {
+	managedByKey := r.ManagedByLabelKey
+	if managedByKey == "" {
+		managedByKey = defaultManagedByLabelKey
+	}
+	managedBy := r.ManagedByLabelValue
+	if managedBy == "" {
+		managedBy = defaultManagedByValue
+	}
+
+	return map[string]string{
+		r.appLabelKey(): webapp.Name,
+		managedByKey:    managedBy,
+	}
+}
+
+// appLabelKey is the key of the label holding the WebApp's name
+func (r *WebAppReconciler) appLabelKey() string {
+	if r.AppLabelKey == "" {
+		return defaultAppLabelKey
+	}
+	return r.AppLabelKey
+}
+
+// ValidateSelectorLabels checks the label keys and managed-by value passed
+// to the operator. Empty values fall back to the defaults.
+func ValidateSelectorLabels(appKey, managedByKey, managedByValue string) error {
+	for _, key := range []string{appKey, managedByKey} {
+		if key == "" {
+			continue
+		}
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+	}
+	if appKey != "" && appKey == managedByKey {
+		return fmt.Errorf("the app and managed-by label keys must differ, both are %q", appKey)
+	}
+	if errs := validation.IsValidLabelValue(managedByValue); len(errs) > 0 {
+		return fmt.Errorf("invalid managed-by label value %q: %s", managedByValue, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// replaceDeployment moves a Deployment whose selector no longer matches the
+// operator's labels onto the new labels. Selectors are immutable, so the
+// Deployment is deleted and recreated on the next reconcile. Its ReplicaSets
+// and pods are first given the new labels and then orphaned, so they keep
+// serving behind the Services and the recreated Deployment adopts them and
+// rolls them over like any older revision.
+func (r *WebAppReconciler) replaceDeployment(ctx context.Context, deployment *appsv1.Deployment, labels map[string]string) error {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return err
+	}
+
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := r.List(ctx, replicaSets, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for i := range replicaSets.Items {
+		replicaSet := &replicaSets.Items[i]
+		if !metav1.IsControlledBy(replicaSet, deployment) {
+			continue
+		}
+		patch := client.MergeFrom(replicaSet.DeepCopy())
+		if mergeInto(&replicaSet.Labels, labels) {
+			if err := r.Patch(ctx, replicaSet, patch); err != nil {
+				return err
+			}
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		patch := client.MergeFrom(pod.DeepCopy())
+		if mergeInto(&pod.Labels, labels) {
+			if err := r.Patch(ctx, pod, patch); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.FromContext(ctx).Info("Recreating Deployment to change its selector",
+		"deployment", deployment.Name, "from", selector.String())
+	return client.IgnoreNotFound(r.Delete(ctx, deployment, client.PropagationPolicy(metav1.DeletePropagationOrphan)))
+}
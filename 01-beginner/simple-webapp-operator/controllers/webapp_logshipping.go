@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"fmt"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+const (
+	// logShipperName is the container shipping the app's logs
+	logShipperName = "log-shipper"
+
+	// logVolumeName is the emptyDir the app writes its logs to
+	logVolumeName = "app-logs"
+)
+
+// applyLogShipping shares an emptyDir at the log path between the app and a
+// log shipper. The shipper runs as a native sidecar, an init container that
+// keeps running, so it starts before the app and stops only after the app
+// has exited and its last lines were written.
+func applyLogShipping(podSpec *corev1.PodSpec, webapp *appsv1alpha1.WebApp) {
+	logShipping := webapp.Spec.LogShipping
+	always := corev1.ContainerRestartPolicyAlways
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: logVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				SizeLimit: logShipping.SizeLimit,
+			},
+		},
+	})
+	podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      logVolumeName,
+		MountPath: logShipping.LogPath,
+	})
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+		Name:            logShipperName,
+		Image:           logShipping.Image,
+		Args:            logShipping.Args,
+		RestartPolicy:   &always,
+		SecurityContext: containerSecurityContext(webapp),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      logVolumeName,
+				MountPath: logShipping.LogPath,
+				ReadOnly:  true,
+			},
+		},
+	})
+}
+
+// initContainersMatch reports whether the existing init containers, i.e.
+// the log shipper, match the desired ones on the fields the operator sets
+func initContainersMatch(existing, desired []corev1.Container) bool {
+	if !sidecarsMatch(existing, desired) {
+		return false
+	}
+	for i := range desired {
+		if !equality.Semantic.DeepEqual(existing[i].RestartPolicy, desired[i].RestartPolicy) ||
+			!equality.Semantic.DeepEqual(existing[i].VolumeMounts, desired[i].VolumeMounts) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateLogShipping rejects a log shipper whose container, volume or log
+// path clashes with the sidecars and volumes set elsewhere in the spec
+func validateLogShipping(webapp *appsv1alpha1.WebApp) error {
+	logShipping := webapp.Spec.LogShipping
+	if logShipping == nil {
+		return nil
+	}
+
+	for _, sidecar := range webapp.Spec.Sidecars {
+		if sidecar.Name == logShipperName {
+			return fmt.Errorf("sidecar name %q is reserved for the log shipper", logShipperName)
+		}
+	}
+
+	logPath := path.Clean(logShipping.LogPath)
+	for _, volume := range webapp.Spec.EmptyDirVolumes {
+		if volume.Name == logVolumeName {
+			return fmt.Errorf("emptyDir volume name %q is reserved for the log shipper", logVolumeName)
+		}
+		if path.Clean(volume.MountPath) == logPath {
+			return fmt.Errorf("logShipping logPath %q is already used by emptyDir volume %q", logShipping.LogPath, volume.Name)
+		}
+	}
+	if pv := webapp.Spec.PersistentVolume; pv != nil && path.Clean(pv.MountPath) == logPath {
+		return fmt.Errorf("logShipping logPath %q is already used by the persistent volume", logShipping.LogPath)
+	}
+	return nil
+}
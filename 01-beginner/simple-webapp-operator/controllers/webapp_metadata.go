@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// ownedMetadataAnnotation on a Deployment records the label and annotation
+// keys the operator set on it and its pod template, so keys dropped from the
+// WebApp are removed again while keys added by others are kept
+const ownedMetadataAnnotation = "webapp.example.com/owned-metadata"
+
+// ownedMetadata lists the keys the operator set on a Deployment
+type ownedMetadata struct {
+	Labels         []string `json:"labels,omitempty"`
+	Annotations    []string `json:"annotations,omitempty"`
+	PodLabels      []string `json:"podLabels,omitempty"`
+	PodAnnotations []string `json:"podAnnotations,omitempty"`
+}
+
+// recordOwnedMetadata stores the keys of the Deployment's labels and
+// annotations, and those of its pod template, in ownedMetadataAnnotation
+func recordOwnedMetadata(deployment *appsv1.Deployment) error {
+	annotations := maps.Clone(deployment.Annotations)
+	delete(annotations, ownedMetadataAnnotation)
+
+	data, err := json.Marshal(ownedMetadata{
+		Labels:         slices.Sorted(maps.Keys(deployment.Labels)),
+		Annotations:    slices.Sorted(maps.Keys(annotations)),
+		PodLabels:      slices.Sorted(maps.Keys(deployment.Spec.Template.Labels)),
+		PodAnnotations: slices.Sorted(maps.Keys(deployment.Spec.Template.Annotations)),
+	})
+	if err != nil {
+		return err
+	}
+	deployment.Annotations = mergeLabels(deployment.Annotations, map[string]string{ownedMetadataAnnotation: string(data)})
+	return nil
+}
+
+// ownedMetadataOf reads the keys recorded on the Deployment. A Deployment
+// without a readable record owns no keys, so nothing is removed from it.
+func ownedMetadataOf(deployment *appsv1.Deployment) ownedMetadata {
+	var owned ownedMetadata
+	if data, ok := deployment.Annotations[ownedMetadataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &owned); err != nil {
+			return ownedMetadata{}
+		}
+	}
+	return owned
+}
+
+// syncOwned merges desired into dst and removes the owned keys that are no
+// longer desired. Keys present only in dst and not owned are left in place.
+// It reports whether dst changed.
+func syncOwned(dst *map[string]string, desired map[string]string, owned []string) bool {
+	changed := mergeInto(dst, desired)
+	for _, k := range owned {
+		if _, ok := desired[k]; ok {
+			continue
+		}
+		if _, ok := (*dst)[k]; ok {
+			delete(*dst, k)
+			changed = true
+		}
+	}
+	return changed
+}
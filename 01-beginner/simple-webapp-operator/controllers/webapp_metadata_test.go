@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// newTestReconciler returns a WebAppReconciler backed by a fake client
+// holding objs
+func newTestReconciler(t *testing.T, objs ...client.Object) *WebAppReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := appsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &WebAppReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		Scheme: scheme,
+	}
+}
+
+func TestMergeInto(t *testing.T) {
+	tests := []struct {
+		name        string
+		dst         map[string]string
+		desired     map[string]string
+		want        map[string]string
+		wantChanged bool
+	}{
+		{
+			name:        "nil destination",
+			desired:     map[string]string{"a": "1"},
+			want:        map[string]string{"a": "1"},
+			wantChanged: true,
+		},
+		{
+			name:    "nothing desired",
+			dst:     map[string]string{"a": "1"},
+			want:    map[string]string{"a": "1"},
+			desired: nil,
+		},
+		{
+			name:    "already merged",
+			dst:     map[string]string{"a": "1", "b": "2"},
+			desired: map[string]string{"a": "1"},
+			want:    map[string]string{"a": "1", "b": "2"},
+		},
+		{
+			name:        "changed value",
+			dst:         map[string]string{"a": "1"},
+			desired:     map[string]string{"a": "2"},
+			want:        map[string]string{"a": "2"},
+			wantChanged: true,
+		},
+		{
+			name:        "foreign keys are kept",
+			dst:         map[string]string{"sidecar.istio.io/status": "injected"},
+			desired:     map[string]string{"team": "shop"},
+			want:        map[string]string{"sidecar.istio.io/status": "injected", "team": "shop"},
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := maps.Clone(tt.dst)
+			if changed := mergeInto(&dst, tt.desired); changed != tt.wantChanged {
+				t.Errorf("mergeInto() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !maps.Equal(dst, tt.want) {
+				t.Errorf("mergeInto() = %v, want %v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncOwned(t *testing.T) {
+	tests := []struct {
+		name        string
+		dst         map[string]string
+		desired     map[string]string
+		owned       []string
+		want        map[string]string
+		wantChanged bool
+	}{
+		{
+			name:    "unchanged",
+			dst:     map[string]string{"team": "shop"},
+			desired: map[string]string{"team": "shop"},
+			owned:   []string{"team"},
+			want:    map[string]string{"team": "shop"},
+		},
+		{
+			name:        "owned key dropped from the spec is removed",
+			dst:         map[string]string{"team": "shop", "tier": "web"},
+			desired:     map[string]string{"team": "shop"},
+			owned:       []string{"team", "tier"},
+			want:        map[string]string{"team": "shop"},
+			wantChanged: true,
+		},
+		{
+			name:    "foreign key is kept",
+			dst:     map[string]string{"team": "shop", "sidecar.istio.io/status": "injected"},
+			desired: map[string]string{"team": "shop"},
+			owned:   []string{"team"},
+			want:    map[string]string{"team": "shop", "sidecar.istio.io/status": "injected"},
+		},
+		{
+			name:    "owned key already gone",
+			dst:     map[string]string{},
+			desired: nil,
+			owned:   []string{"tier"},
+			want:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := maps.Clone(tt.dst)
+			if changed := syncOwned(&dst, tt.desired, tt.owned); changed != tt.wantChanged {
+				t.Errorf("syncOwned() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !maps.Equal(dst, tt.want) {
+				t.Errorf("syncOwned() = %v, want %v", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestOwnedMetadataOf(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"app": "shop"},
+			Annotations: map[string]string{"team": "shop"},
+		},
+	}
+	deployment.Spec.Template.Annotations = map[string]string{"prometheus.io/scrape": "true"}
+	if err := recordOwnedMetadata(deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	owned := ownedMetadataOf(deployment)
+	if len(owned.Labels) != 1 || owned.Labels[0] != "app" {
+		t.Errorf("Labels = %v, want [app]", owned.Labels)
+	}
+	if len(owned.Annotations) != 1 || owned.Annotations[0] != "team" {
+		t.Errorf("Annotations = %v, want [team], without the record itself", owned.Annotations)
+	}
+	if len(owned.PodAnnotations) != 1 || owned.PodAnnotations[0] != "prometheus.io/scrape" {
+		t.Errorf("PodAnnotations = %v, want [prometheus.io/scrape]", owned.PodAnnotations)
+	}
+
+	deployment.Annotations[ownedMetadataAnnotation] = "not json"
+	if owned := ownedMetadataOf(deployment); len(owned.Labels)+len(owned.Annotations) != 0 {
+		t.Errorf("ownedMetadataOf() with an unreadable record = %+v, want no keys", owned)
+	}
+}
+
+func TestReconcileDeploymentKeepsInjectedAnnotations(t *testing.T) {
+	ctx := context.Background()
+	webapp := &appsv1alpha1.WebApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "default", UID: "uid"},
+		Spec: appsv1alpha1.WebAppSpec{
+			Image:          "nginx:1.27",
+			Port:           8080,
+			PodAnnotations: map[string]string{"prometheus.io/scrape": "true", "team": "shop"},
+		},
+	}
+	r := newTestReconciler(t, webapp)
+
+	if err := r.reconcileDeployment(ctx, webapp); err != nil {
+		t.Fatal(err)
+	}
+
+	// A mutating webhook adds its own annotation to the pod template
+	key := types.NamespacedName{Name: "shop", Namespace: "default"}
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, deployment); err != nil {
+		t.Fatal(err)
+	}
+	deployment.Spec.Template.Annotations["sidecar.istio.io/status"] = "injected"
+	if err := r.Update(ctx, deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user drops one of their annotations and changes the other
+	webapp.Spec.PodAnnotations = map[string]string{"prometheus.io/scrape": "false"}
+	if err := r.reconcileDeployment(ctx, webapp); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Get(ctx, key, deployment); err != nil {
+		t.Fatal(err)
+	}
+	annotations := deployment.Spec.Template.Annotations
+	if annotations["sidecar.istio.io/status"] != "injected" {
+		t.Errorf("injected annotation was removed: %v", annotations)
+	}
+	if annotations["prometheus.io/scrape"] != "false" {
+		t.Errorf("prometheus.io/scrape = %q, want false", annotations["prometheus.io/scrape"])
+	}
+	if _, ok := annotations["team"]; ok {
+		t.Errorf("annotation dropped from the spec is still set: %v", annotations)
+	}
+}
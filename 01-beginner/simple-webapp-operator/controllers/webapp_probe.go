@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// startupProbe returns the WebApp's startup probe with the fields the API
+// server defaults filled in, so it compares equal to the stored pod template
+func startupProbe(webapp *appsv1alpha1.WebApp) *corev1.Probe {
+	if webapp.Spec.StartupProbe == nil {
+		return nil
+	}
+
+	probe := webapp.Spec.StartupProbe.DeepCopy()
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = 1
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = 10
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = 1
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	if probe.HTTPGet != nil && probe.HTTPGet.Scheme == "" {
+		probe.HTTPGet.Scheme = corev1.URISchemeHTTP
+	}
+	if probe.GRPC != nil && probe.GRPC.Service == nil {
+		service := ""
+		probe.GRPC.Service = &service
+	}
+	return probe
+}
+
+// validateStartupProbe rejects a startup probe the API server would refuse on the pod
+func validateStartupProbe(webapp *appsv1alpha1.WebApp) error {
+	probe := webapp.Spec.StartupProbe
+	if probe == nil {
+		return nil
+	}
+
+	handlers := 0
+	for _, set := range []bool{probe.Exec != nil, probe.HTTPGet != nil, probe.TCPSocket != nil, probe.GRPC != nil} {
+		if set {
+			handlers++
+		}
+	}
+	if handlers != 1 {
+		return fmt.Errorf("startupProbe must set exactly one of exec, httpGet, tcpSocket and grpc")
+	}
+	if probe.SuccessThreshold > 1 {
+		return fmt.Errorf("startupProbe successThreshold must be 1, got %d", probe.SuccessThreshold)
+	}
+	return nil
+}
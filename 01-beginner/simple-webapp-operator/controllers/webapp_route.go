@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// httpRouteGVK and gatewayGVK identify the Gateway API kinds, which are
+// handled as unstructured so the operator does not depend on their API module
+var (
+	httpRouteGVK = schema.GroupVersionKind{
+		Group:   "gateway.networking.k8s.io",
+		Version: "v1",
+		Kind:    "HTTPRoute",
+	}
+	gatewayGVK = schema.GroupVersionKind{
+		Group:   "gateway.networking.k8s.io",
+		Version: "v1",
+		Kind:    "Gateway",
+	}
+)
+
+// reconcileHTTPRoute creates, updates or deletes the WebApp's HTTPRoute and
+// reports in the Routed condition whether the Gateway accepted it
+func (r *WebAppReconciler) reconcileHTTPRoute(ctx context.Context, webapp *appsv1alpha1.WebApp) error {
+	log := log.FromContext(ctx)
+
+	installed, err := r.httpRouteInstalled()
+	if err != nil {
+		return err
+	}
+	if !installed {
+		webapp.Status.Hostname = ""
+		if webapp.Spec.Gateway != nil {
+			log.Info("HTTPRoute CRD not installed, skipping")
+			r.updateCondition(webapp, "Routed", metav1.ConditionFalse, "HTTPRouteUnavailable",
+				"gateway.networking.k8s.io/v1 HTTPRoute is not installed in the cluster")
+		} else {
+			meta.RemoveStatusCondition(&webapp.Status.Conditions, "Routed")
+		}
+		return nil
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(httpRouteGVK)
+	err = r.Get(ctx, types.NamespacedName{Name: webapp.Name, Namespace: webapp.Namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	// Gateway removed, delete the HTTPRoute we own
+	if webapp.Spec.Gateway == nil {
+		webapp.Status.Hostname = ""
+		meta.RemoveStatusCondition(&webapp.Status.Conditions, "Routed")
+		if found && metav1.IsControlledBy(existing, webapp) {
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil
+	}
+
+	desired := r.createHTTPRoute(webapp)
+	if !found {
+		if err := controllerutil.SetControllerReference(webapp, desired, r.Scheme); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, desired); err != nil {
+			return err
+		}
+		existing = desired
+	} else if !routeSpecMatches(existing, desired) {
+		existing.Object["spec"] = desired.Object["spec"]
+		if err := r.Update(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	accepted, message := routeAccepted(existing)
+	switch {
+	case accepted:
+		hostname, err := r.routeHostname(ctx, webapp)
+		if err != nil {
+			return err
+		}
+		webapp.Status.Hostname = hostname
+		r.updateCondition(webapp, "Routed", metav1.ConditionTrue, "RouteAccepted", message)
+	case message != "":
+		webapp.Status.Hostname = ""
+		r.updateCondition(webapp, "Routed", metav1.ConditionFalse, "RouteNotAccepted", message)
+	default:
+		webapp.Status.Hostname = ""
+		r.updateCondition(webapp, "Routed", metav1.ConditionFalse, "RoutePending",
+			fmt.Sprintf("Waiting for Gateway %s to accept the HTTPRoute", gatewayNamespace(webapp)+"/"+webapp.Spec.Gateway.Name))
+	}
+	return nil
+}
+
+// createHTTPRoute builds an HTTPRoute sending all paths of spec.hostname to
+// the first Service's first port
+func (r *WebAppReconciler) createHTTPRoute(webapp *appsv1alpha1.WebApp) *unstructured.Unstructured {
+	gateway := webapp.Spec.Gateway
+	service := r.desiredServices(webapp)[0]
+
+	parentRef := map[string]interface{}{
+		"name":      gateway.Name,
+		"namespace": gatewayNamespace(webapp),
+	}
+	if gateway.SectionName != "" {
+		parentRef["sectionName"] = gateway.SectionName
+	}
+
+	spec := map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": service.Name,
+						"port": int64(service.Spec.Ports[0].Port),
+					},
+				},
+			},
+		},
+	}
+	if webapp.Spec.Hostname != "" {
+		spec["hostnames"] = []interface{}{webapp.Spec.Hostname}
+	}
+
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName(webapp.Name)
+	route.SetNamespace(webapp.Namespace)
+	route.SetLabels(r.selectorLabels(webapp))
+	route.Object["spec"] = spec
+	return route
+}
+
+// routeSpecMatches reports whether the route has the fields the operator
+// sets. The API server fills in defaults, such as the rule's path match and
+// the group and kind of each reference, so only the fields in desired are
+// compared, apart from hostnames, which are dropped along with spec.hostname.
+func routeSpecMatches(existing, desired *unstructured.Unstructured) bool {
+	existingHostnames, _, _ := unstructured.NestedStringSlice(existing.Object, "spec", "hostnames")
+	desiredHostnames, _, _ := unstructured.NestedStringSlice(desired.Object, "spec", "hostnames")
+	if !slices.Equal(existingHostnames, desiredHostnames) {
+		return false
+	}
+	return containsFields(existing.Object["spec"], desired.Object["spec"])
+}
+
+// containsFields reports whether every field set in desired has the same
+// value in existing. Lists must have the same length.
+func containsFields(existing, desired interface{}) bool {
+	switch desired := desired.(type) {
+	case map[string]interface{}:
+		existing, ok := existing.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range desired {
+			if !containsFields(existing[key], value) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		existing, ok := existing.([]interface{})
+		if !ok || len(existing) != len(desired) {
+			return false
+		}
+		for i := range desired {
+			if !containsFields(existing[i], desired[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equality.Semantic.DeepEqual(existing, desired)
+	}
+}
+
+// routeAccepted reads the Accepted condition the Gateway sets on the route's
+// status. It returns the condition's message, or an empty message while the
+// Gateway has not reported on the route yet.
+func routeAccepted(route *unstructured.Unstructured) (bool, string) {
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	for _, parent := range parents {
+		parentMap, ok := parent.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		conditions, _, _ := unstructured.NestedSlice(parentMap, "conditions")
+		for _, condition := range conditions {
+			conditionMap, ok := condition.(map[string]interface{})
+			if !ok || conditionMap["type"] != "Accepted" {
+				continue
+			}
+			message, _ := conditionMap["message"].(string)
+			if message == "" {
+				message, _ = conditionMap["reason"].(string)
+			}
+			return conditionMap["status"] == string(metav1.ConditionTrue), message
+		}
+	}
+	return false, ""
+}
+
+// routeHostname returns the hostname the route serves: spec.hostname, or else
+// the hostname of the Gateway listener it attaches to
+func (r *WebAppReconciler) routeHostname(ctx context.Context, webapp *appsv1alpha1.WebApp) (string, error) {
+	if webapp.Spec.Hostname != "" {
+		return webapp.Spec.Hostname, nil
+	}
+
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(gatewayGVK)
+	if err := r.Get(ctx, types.NamespacedName{Name: webapp.Spec.Gateway.Name, Namespace: gatewayNamespace(webapp)}, gateway); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	listeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	for _, listener := range listeners {
+		listenerMap, ok := listener.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if sectionName := webapp.Spec.Gateway.SectionName; sectionName != "" && listenerMap["name"] != sectionName {
+			continue
+		}
+		if hostname, _ := listenerMap["hostname"].(string); hostname != "" {
+			return hostname, nil
+		}
+	}
+	return "", nil
+}
+
+// gatewayNamespace returns the namespace of the WebApp's Gateway
+func gatewayNamespace(webapp *appsv1alpha1.WebApp) string {
+	if webapp.Spec.Gateway.Namespace != "" {
+		return webapp.Spec.Gateway.Namespace
+	}
+	return webapp.Namespace
+}
+
+// httpRouteInstalled reports whether the cluster serves the HTTPRoute CRD
+func (r *WebAppReconciler) httpRouteInstalled() (bool, error) {
+	_, err := r.RESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+const (
+	// activateAnnotation wakes a WebApp scaled to zero. Its value is the
+	// number of replicas to run, or empty for one.
+	activateAnnotation = "webapp.example.com/activate"
+
+	// maxActivationReplicas matches the Maximum of spec.replicas
+	maxActivationReplicas = 10
+)
+
+// desiredReplicas is the replica count of the WebApp's Deployment:
+// spec.replicas, unless the WebApp is scaled to zero and has been activated
+func desiredReplicas(webapp *appsv1alpha1.WebApp) int32 {
+	if replicas := int32Value(webapp.Spec.Replicas, 1); replicas != 0 {
+		return replicas
+	}
+	if replicas, err := activationReplicas(webapp); err == nil {
+		return replicas
+	}
+	return 0
+}
+
+// activationReplicas parses the activate annotation. It returns zero when
+// the WebApp isn't activated.
+func activationReplicas(webapp *appsv1alpha1.WebApp) (int32, error) {
+	value, ok := webapp.Annotations[activateAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	if strings.TrimSpace(value) == "" {
+		return 1, nil
+	}
+
+	replicas, err := strconv.ParseInt(strings.TrimSpace(value), 10, 32)
+	if err != nil || replicas < 1 || replicas > maxActivationReplicas {
+		return 0, fmt.Errorf("annotation %s must be a replica count between 1 and %d, got %q",
+			activateAnnotation, maxActivationReplicas, value)
+	}
+	return int32(replicas), nil
+}
+
+// validateActivation rejects an activate annotation that isn't a valid replica count
+func validateActivation(webapp *appsv1alpha1.WebApp) error {
+	_, err := activationReplicas(webapp)
+	return err
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	appsv1alpha1 "github.com/nutcas3/simple-webapp-operator/api/v1alpha1"
+)
+
+// trafficReadyCondition is the readiness gate holding a pod out of its
+// Services until the WebApp's traffic delay has passed
+const trafficReadyCondition corev1.PodConditionType = "webapp.example.com/traffic-ready"
+
+// trafficReadinessGates returns the readiness gate of a WebApp with a traffic delay
+func trafficReadinessGates(webapp *appsv1alpha1.WebApp) []corev1.PodReadinessGate {
+	if webapp.Spec.TrafficDelaySeconds == 0 {
+		return nil
+	}
+	return []corev1.PodReadinessGate{{ConditionType: trafficReadyCondition}}
+}
+
+// releaseTraffic opens the readiness gate of every pod whose containers have
+// been ready for the traffic delay. Until then the pod isn't ready, so its
+// Services route no traffic to it and the Deployment doesn't count it as
+// available. It returns when the next pod is due. Pods still gated when the
+// delay is removed are released right away.
+func (r *WebAppReconciler) releaseTraffic(ctx context.Context, webapp *appsv1alpha1.WebApp) (time.Duration, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(webapp.Namespace), client.MatchingLabels(r.selectorLabels(webapp))); err != nil {
+		return 0, err
+	}
+
+	delay := time.Duration(webapp.Spec.TrafficDelaySeconds) * time.Second
+	var next time.Duration
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !hasTrafficGate(pod) || podCondition(pod, trafficReadyCondition) == corev1.ConditionTrue {
+			continue
+		}
+
+		readySince := containersReadySince(pod)
+		if readySince.IsZero() {
+			continue
+		}
+		if wait := time.Until(readySince.Add(delay)); wait > 0 {
+			if next == 0 || wait < next {
+				next = wait
+			}
+			continue
+		}
+
+		patch := client.StrategicMergeFrom(pod.DeepCopy())
+		pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{
+			Type:               trafficReadyCondition,
+			Status:             corev1.ConditionTrue,
+			Reason:             "TrafficDelayElapsed",
+			LastTransitionTime: metav1.Now(),
+		})
+		if err := r.Status().Patch(ctx, pod, patch); client.IgnoreNotFound(err) != nil {
+			return 0, err
+		}
+		log.FromContext(ctx).Info("Releasing traffic to pod", "pod", pod.Name, "readySince", readySince)
+	}
+	return next, nil
+}
+
+// findWebAppForPod maps a pod with the traffic gate to its WebApp, so the
+// delay starts counting as soon as the pod's containers become ready
+func (r *WebAppReconciler) findWebAppForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !hasTrafficGate(pod) {
+		return nil
+	}
+	name, ok := pod.Labels[r.appLabelKey()]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: pod.Namespace}}}
+}
+
+// hasTrafficGate reports whether the pod was created with the traffic readiness gate
+func hasTrafficGate(pod *corev1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == trafficReadyCondition {
+			return true
+		}
+	}
+	return false
+}
+
+// podCondition returns the status of the pod's condition of the given type
+func podCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) corev1.ConditionStatus {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status
+		}
+	}
+	return corev1.ConditionUnknown
+}
+
+// containersReadySince returns when the pod's containers became ready, or
+// the zero time while they aren't
+func containersReadySince(pod *corev1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.ContainersReady && condition.Status == corev1.ConditionTrue {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,67 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PostgresServerSpec describes how to reach a PostgreSQL server. PostgresUsers
+// reference it through serverRef instead of repeating the connection settings.
+type PostgresServerSpec struct {
+	// Host is the PostgreSQL host applications connect to, such as a PgBouncer pooler
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Port is the PostgreSQL port applications connect to
+	// +kubebuilder:default=5432
+	Port int32 `json:"port,omitempty"`
+
+	// AdminHost is the host used for role and grant management. Set it to the
+	// PostgreSQL server when Host points at a connection pooler. Defaults to Host.
+	// +optional
+	AdminHost string `json:"adminHost,omitempty"`
+
+	// AdminPort is the port used with AdminHost. Defaults to Port.
+	// +optional
+	AdminPort int32 `json:"adminPort,omitempty"`
+
+	// AdminSecretRef references the secret containing admin credentials. It
+	// must be in the PostgresServer's namespace.
+	AdminSecretRef corev1.SecretReference `json:"adminSecretRef"`
+
+	// SSLMode is the libpq sslmode used for every connection to the server
+	// +kubebuilder:validation:Enum=disable;require;verify-ca;verify-full
+	// +kubebuilder:default=disable
+	SSLMode string `json:"sslMode,omitempty"`
+
+	// SSLRootCertConfigMapRef selects the CA bundle used to verify the server
+	// certificate with verify-ca or verify-full
+	// +optional
+	SSLRootCertConfigMapRef *corev1.ConfigMapKeySelector `json:"sslRootCertConfigMapRef,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="Port",type=integer,JSONPath=`.spec.port`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PostgresServer is the Schema for the postgresservers API
+type PostgresServer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PostgresServerSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PostgresServerList contains a list of PostgresServer
+type PostgresServerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgresServer `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgresServer{}, &PostgresServerList{})
+}
@@ -15,28 +15,163 @@ type PostgresUserSpec struct {
 	// +kubebuilder:validation:Required
 	Database string `json:"database"`
 
-	// Host is the PostgreSQL server host
-	// +kubebuilder:validation:Required
-	Host string `json:"host"`
-
-	// Port is the PostgreSQL server port
+	// AdoptExistingRole lets the operator take over a role of the same name
+	// that it didn't create, for example one made by another tool. Without it
+	// such a role is left untouched and the Conflict condition is set.
+	// +optional
+	AdoptExistingRole bool `json:"adoptExistingRole,omitempty"`
+
+	// ServerRef names a PostgresServer in the same namespace that supplies the
+	// host, ports, admin Secret and TLS settings. Those fields must then be
+	// left unset here; any port or sslMode set here is ignored.
+	// +optional
+	ServerRef *corev1.LocalObjectReference `json:"serverRef,omitempty"`
+
+	// Host is the PostgreSQL host applications connect to, such as a PgBouncer
+	// pooler. Required unless ServerRef is set.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Port is the PostgreSQL port applications connect to
 	// +kubebuilder:default=5432
 	Port int32 `json:"port,omitempty"`
 
-	// AdminSecretRef references the secret containing admin credentials
-	// +kubebuilder:validation:Required
-	AdminSecretRef corev1.SecretReference `json:"adminSecretRef"`
+	// AdminHost is the host used for role and grant management. Set it to the
+	// PostgreSQL server when Host points at a connection pooler. Defaults to Host.
+	// +optional
+	AdminHost string `json:"adminHost,omitempty"`
+
+	// AdminPort is the port used with AdminHost. Defaults to Port.
+	// +optional
+	AdminPort int32 `json:"adminPort,omitempty"`
+
+	// AuthMode is how the operator and the user authenticate. With iam, the
+	// operator connects with tokens from its token provider instead of the
+	// admin Secret's password, and the user's role gets no password.
+	// +kubebuilder:validation:Enum=password;iam
+	// +kubebuilder:default=password
+	AuthMode string `json:"authMode,omitempty"`
+
+	// PasswordEncryption is the hash the role's password is stored with.
+	// Empty uses the server's password_encryption setting. It applies the
+	// next time the password is set.
+	// +kubebuilder:validation:Enum=scram-sha-256;md5
+	// +optional
+	PasswordEncryption string `json:"passwordEncryption,omitempty"`
+
+	// SSLMode is the libpq sslmode used for every connection the operator opens
+	// +kubebuilder:validation:Enum=disable;require;verify-ca;verify-full
+	// +kubebuilder:default=disable
+	SSLMode string `json:"sslMode,omitempty"`
+
+	// SSLRootCertConfigMapRef selects the CA bundle used to verify the server
+	// certificate with verify-ca or verify-full
+	// +optional
+	SSLRootCertConfigMapRef *corev1.ConfigMapKeySelector `json:"sslRootCertConfigMapRef,omitempty"`
+
+	// AdminSecretRef references the secret containing admin credentials.
+	// Required unless ServerRef is set.
+	// +optional
+	AdminSecretRef corev1.SecretReference `json:"adminSecretRef,omitempty"`
 
 	// Privileges is the list of privileges to grant
 	// +kubebuilder:validation:MinItems=1
 	Privileges []string `json:"privileges"`
 
-	// SecretName is the name of the secret to create with user credentials
-	// +kubebuilder:validation:Required
-	SecretName string `json:"secretName"`
+	// GrantOption lists, per kind of object, the privileges the user may
+	// grant on to other roles. Privileges left out lose the grant option.
+	// +optional
+	GrantOption *GrantOptionSpec `json:"grantOption,omitempty"`
+
+	// SequencePrivileges is the list of privileges to grant on sequences
+	// +kubebuilder:validation:items:Enum=USAGE;SELECT;UPDATE
+	SequencePrivileges []string `json:"sequencePrivileges,omitempty"`
+
+	// FunctionPrivileges is the list of privileges to grant on functions
+	// +kubebuilder:validation:items:Enum=EXECUTE
+	FunctionPrivileges []string `json:"functionPrivileges,omitempty"`
+
+	// Schemas lists the schemas in which privileges and default privileges
+	// are granted. The user also gets USAGE on each. Every schema must exist.
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:default={"public"}
+	Schemas []string `json:"schemas,omitempty"`
+
+	// DefaultPrivilegesFor lists roles that create objects in Schemas,
+	// such as application roles running migrations. Objects they create later
+	// get the same privileges as those created by the admin user. The admin
+	// user must be a member of each role.
+	DefaultPrivilegesFor []string `json:"defaultPrivilegesFor,omitempty"`
+
+	// SecretName is the name of the secret to create with user credentials.
+	// Required when Login is enabled and must be empty otherwise.
+	SecretName string `json:"secretName,omitempty"`
 
 	// RotatePassword triggers password rotation when changed
 	RotatePassword bool `json:"rotatePassword,omitempty"`
+
+	// DualPasswordWindow stages rotated passwords instead of applying them at
+	// once. PostgreSQL keeps a single password per role, so the new password is
+	// first published under the Secret's password-next key and only becomes the
+	// role's password, and the Secret's password key, once the window has passed.
+	// +optional
+	DualPasswordWindow *metav1.Duration `json:"dualPasswordWindow,omitempty"`
+
+	// ValidateCredentials opens a test connection with the generated
+	// credentials after provisioning and reports the CredentialsValidated condition
+	// +optional
+	ValidateCredentials bool `json:"validateCredentials,omitempty"`
+
+	// Login controls whether the role may log in. Roles with login disabled
+	// get no password or Secret and exist only to be granted to other users.
+	// +kubebuilder:default=true
+	Login *bool `json:"login,omitempty"`
+
+	// ConnectionLimit caps concurrent connections for the role. Unset means unlimited.
+	// +kubebuilder:validation:Minimum=-1
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+
+	// ValidUntil is when the role's password expires. Unset means never.
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
+
+	// Comment is set on the role with COMMENT ON ROLE, for example to record
+	// which application or source network the role is meant for. Unset
+	// removes the comment.
+	Comment string `json:"comment,omitempty"`
+
+	// MemberOf lists existing roles this user is granted membership in
+	MemberOf []string `json:"memberOf,omitempty"`
+
+	// ReassignOwnedTo is the role that takes over objects owned by this user
+	// when it is deleted. Without it, deletion fails while the user owns objects.
+	// +optional
+	ReassignOwnedTo string `json:"reassignOwnedTo,omitempty"`
+
+	// DeletionPolicy is what happens to the role when the PostgresUser is
+	// deleted. Drop revokes the privileges the operator granted and drops the
+	// role. DropOwned also runs DROP OWNED BY in Database first, dropping the
+	// objects the role owns there and any other privileges it holds there.
+	// Retain leaves the role and its privileges in place.
+	// +kubebuilder:validation:Enum=Drop;DropOwned;Retain
+	// +kubebuilder:default=Drop
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// GrantOptionSpec names the privileges granted WITH GRANT OPTION. Each must
+// also be listed in the privileges for the same kind of object.
+type GrantOptionSpec struct {
+	// Tables lists table privileges from Privileges
+	// +optional
+	Tables []string `json:"tables,omitempty"`
+
+	// Sequences lists sequence privileges from SequencePrivileges
+	// +optional
+	Sequences []string `json:"sequences,omitempty"`
+
+	// Functions lists function privileges from FunctionPrivileges
+	// +optional
+	Functions []string `json:"functions,omitempty"`
 }
 
 // PostgresUserStatus defines the observed state of PostgresUser
@@ -50,6 +185,18 @@ type PostgresUserStatus struct {
 	// LastPasswordRotation is when the password was last rotated
 	LastPasswordRotation *metav1.Time `json:"lastPasswordRotation,omitempty"`
 
+	// PasswordRotationStarted is when the pending password was staged in the
+	// Secret. It is cleared once the rotation completes.
+	PasswordRotationStarted *metav1.Time `json:"passwordRotationStarted,omitempty"`
+
+	// RoleManaged is true once the operator has created or adopted the role.
+	// Only managed roles are altered or dropped.
+	RoleManaged bool `json:"roleManaged,omitempty"`
+
+	// LastForceSyncToken is the value of the force-sync annotation that was
+	// last applied, so the same token does not trigger another forced sync
+	LastForceSyncToken string `json:"lastForceSyncToken,omitempty"`
+
 	// Conditions represent the latest observations
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
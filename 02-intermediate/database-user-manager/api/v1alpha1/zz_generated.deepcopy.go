@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -30,19 +31,78 @@ func (in *PostgresUser) DeepCopyInto(out *PostgresUser) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgresUserSpec) DeepCopyInto(out *PostgresUserSpec) {
 	*out = *in
+	if in.ServerRef != nil {
+		in, out := &in.ServerRef, &out.ServerRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.DualPasswordWindow != nil {
+		in, out := &in.DualPasswordWindow, &out.DualPasswordWindow
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.SSLRootCertConfigMapRef != nil {
+		in, out := &in.SSLRootCertConfigMapRef, &out.SSLRootCertConfigMapRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
 	out.AdminSecretRef = in.AdminSecretRef
 	if in.Privileges != nil {
 		in, out := &in.Privileges, &out.Privileges
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.GrantOption != nil {
+		in, out := &in.GrantOption, &out.GrantOption
+		*out = new(GrantOptionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SequencePrivileges != nil {
+		in, out := &in.SequencePrivileges, &out.SequencePrivileges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.FunctionPrivileges != nil {
+		in, out := &in.FunctionPrivileges, &out.FunctionPrivileges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Schemas != nil {
+		in, out := &in.Schemas, &out.Schemas
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DefaultPrivilegesFor != nil {
+		in, out := &in.DefaultPrivilegesFor, &out.DefaultPrivilegesFor
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Login != nil {
+		in, out := &in.Login, &out.Login
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ConnectionLimit != nil {
+		in, out := &in.ConnectionLimit, &out.ConnectionLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ValidUntil != nil {
+		in, out := &in.ValidUntil, &out.ValidUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.MemberOf != nil {
+		in, out := &in.MemberOf, &out.MemberOf
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresUserSpec.
@@ -62,6 +122,10 @@ func (in *PostgresUserStatus) DeepCopyInto(out *PostgresUserStatus) {
 		in, out := &in.LastPasswordRotation, &out.LastPasswordRotation
 		*out = (*in).DeepCopy()
 	}
+	if in.PasswordRotationStarted != nil {
+		in, out := &in.PasswordRotationStarted, &out.PasswordRotationStarted
+		*out = (*in).DeepCopy()
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -130,3 +194,112 @@ func (in *PostgresUserList) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresServer) DeepCopyInto(out *PostgresServer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresServer.
+func (in *PostgresServer) DeepCopy() *PostgresServer {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresServer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresServerList) DeepCopyInto(out *PostgresServerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PostgresServer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresServerList.
+func (in *PostgresServerList) DeepCopy() *PostgresServerList {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresServerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PostgresServerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresServerSpec) DeepCopyInto(out *PostgresServerSpec) {
+	*out = *in
+	out.AdminSecretRef = in.AdminSecretRef
+	if in.SSLRootCertConfigMapRef != nil {
+		in, out := &in.SSLRootCertConfigMapRef, &out.SSLRootCertConfigMapRef
+		*out = new(v1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresServerSpec.
+func (in *PostgresServerSpec) DeepCopy() *PostgresServerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresServerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GrantOptionSpec) DeepCopyInto(out *GrantOptionSpec) {
+	*out = *in
+	if in.Tables != nil {
+		in, out := &in.Tables, &out.Tables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Sequences != nil {
+		in, out := &in.Sequences, &out.Sequences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Functions != nil {
+		in, out := &in.Functions, &out.Functions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GrantOptionSpec.
+func (in *GrantOptionSpec) DeepCopy() *GrantOptionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GrantOptionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"time"
+
+	databasev1alpha1 "github.com/nutcas3/database-user-manager/api/v1alpha1"
+)
+
+// passwordLiteral matches the quoted password of CREATE or ALTER USER ... PASSWORD '...'
+var passwordLiteral = regexp.MustCompile(`(?i)(\bPASSWORD\s+)'(?:[^']|'')*'`)
+
+// execer is a connection or transaction statements are run on
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// exec runs query on db, which is connected to database on the user's
+// server, and records the statement and its outcome in the audit log
+func (r *PostgresUserReconciler) exec(ctx context.Context, db execer, user *databasev1alpha1.PostgresUser, database, query string) error {
+	start := time.Now()
+	_, err := db.ExecContext(ctx, query)
+	r.audit(user, database, query, time.Since(start), err)
+	return err
+}
+
+// commit commits tx and records the outcome in the audit log, since the
+// statements logged before it only take effect once it succeeds
+func (r *PostgresUserReconciler) commit(tx *sql.Tx, user *databasev1alpha1.PostgresUser, database string) error {
+	start := time.Now()
+	err := tx.Commit()
+	r.audit(user, database, "COMMIT", time.Since(start), err)
+	return err
+}
+
+// audit writes one audit log entry. Password literals are redacted from the
+// statement and from the error, which PostgreSQL may quote it in.
+func (r *PostgresUserReconciler) audit(user *databasev1alpha1.PostgresUser, database, statement string, duration time.Duration, err error) {
+	host, port := adminEndpoint(user)
+	keysAndValues := []any{
+		"statement", redactPasswords(statement),
+		"host", host,
+		"port", port,
+		"database", database,
+		"postgresUser", user.Namespace + "/" + user.Name,
+		"durationMs", duration.Milliseconds(),
+	}
+	if err != nil {
+		keysAndValues = append(keysAndValues, "outcome", "failed", "error", redactPasswords(err.Error()))
+	} else {
+		keysAndValues = append(keysAndValues, "outcome", "succeeded")
+	}
+	r.AuditLog.Info("Executed SQL statement", keysAndValues...)
+}
+
+// redactPasswords replaces every password literal in statement
+func redactPasswords(statement string) string {
+	return passwordLiteral.ReplaceAllString(statement, "${1}'<redacted>'")
+}
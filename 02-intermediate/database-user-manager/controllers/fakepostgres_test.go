@@ -0,0 +1,470 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// fakePostgres stands in for a PostgreSQL server. It answers the catalog
+// queries the reconciler runs from its roles, databases and schemas, and
+// records every statement executed along with the database it ran in.
+// Grants on schemas and schema objects are applied to the objects created
+// with createObject, and default privileges to the objects created after
+// them, so has_*_privilege answers like the server would. Statements in a
+// transaction only take effect once it commits.
+type fakePostgres struct {
+	mu         sync.Mutex
+	roles      map[string]bool
+	databases  map[string]bool
+	schemas    map[string]bool
+	statements []fakeStatement
+
+	// adminPassword, when set, is the only password connections are accepted with
+	adminPassword string
+
+	// passwords are the passwords roles were given, which they must log in with
+	passwords map[string]string
+
+	// owners are the roles owning objects, which can't be dropped
+	owners map[string]bool
+
+	// failures makes statements starting with a key fail with its error
+	failures map[string]error
+
+	// objects are the tables, sequences and functions in the schemas
+	objects []fakeObject
+
+	// privileges maps a grant to whether it carries the grant option
+	privileges map[fakeGrant]bool
+
+	// defaults maps a default privilege to whether it carries the grant option
+	defaults map[fakeDefault]bool
+}
+
+// fakeStatement is one statement executed on a fakePostgres
+type fakeStatement struct {
+	database string
+	query    string
+}
+
+// fakeObject is a table, sequence or function, by the keyword GRANT ON ALL uses
+type fakeObject struct {
+	kind   string
+	schema string
+	name   string
+	owner  string
+}
+
+// fakeGrant is a privilege of grantee on a schema object, or on the schema
+// itself when object is empty
+type fakeGrant struct {
+	grantee   string
+	schema    string
+	object    string
+	privilege string
+}
+
+// fakeDefault is a privilege granted to grantee on objects of kind that
+// creator creates in schema
+type fakeDefault struct {
+	creator   string
+	schema    string
+	kind      string
+	privilege string
+	grantee   string
+}
+
+// currentFakePostgres is the server connections opened with the fake driver reach
+var currentFakePostgres *fakePostgres
+
+func init() {
+	sql.Register("fakepostgres", fakeDriver{})
+	sqlDriver = "fakepostgres"
+}
+
+// newFakePostgres returns a server with the postgres database and the public
+// schema, which connections reach until the test ends
+func newFakePostgres(t *testing.T) *fakePostgres {
+	t.Helper()
+	pg := &fakePostgres{
+		roles:      map[string]bool{},
+		databases:  map[string]bool{"postgres": true},
+		schemas:    map[string]bool{"public": true},
+		passwords:  map[string]string{},
+		owners:     map[string]bool{},
+		failures:   map[string]error{},
+		privileges: map[fakeGrant]bool{},
+		defaults:   map[fakeDefault]bool{},
+	}
+	currentFakePostgres = pg
+	t.Cleanup(func() { currentFakePostgres = nil })
+	return pg
+}
+
+// open connects to database on the server as the admin user
+func (pg *fakePostgres) open(t *testing.T, database string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(sqlDriver, "user=postgres dbname="+database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// executed returns the statements run in database, in order
+func (pg *fakePostgres) executed(database string) []string {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	var queries []string
+	for _, s := range pg.statements {
+		if s.database == database {
+			queries = append(queries, s.query)
+		}
+	}
+	return queries
+}
+
+// createObject creates an object of kind TABLES, SEQUENCES or FUNCTIONS
+// owned by creator, which picks up the default privileges creator set
+func (pg *fakePostgres) createObject(creator, kind, schema, name string) {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	pg.objects = append(pg.objects, fakeObject{kind: kind, schema: schema, name: name, owner: creator})
+	for d, grantOption := range pg.defaults {
+		if d.creator == creator && d.schema == schema && d.kind == kind {
+			pg.privileges[fakeGrant{d.grantee, schema, name, d.privilege}] = grantOption
+		}
+	}
+}
+
+// hasPrivilege answers has_table_privilege and its kin for the named object,
+// where privilege may end in WITH GRANT OPTION
+func (pg *fakePostgres) hasPrivilege(role, schema, name, privilege string) bool {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	for _, object := range pg.objects {
+		if object.schema == schema && object.name == name && object.owner == role {
+			return true
+		}
+	}
+	privilege, withGrantOption := strings.CutSuffix(strings.ToUpper(privilege), " WITH GRANT OPTION")
+	grantOption, held := pg.privileges[fakeGrant{role, schema, name, privilege}]
+	return held && (grantOption || !withGrantOption)
+}
+
+// record logs a statement and returns the failure configured for it
+func (pg *fakePostgres) record(database, query string) error {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	pg.statements = append(pg.statements, fakeStatement{database, query})
+	for prefix, err := range pg.failures {
+		if strings.HasPrefix(query, prefix) {
+			return err
+		}
+	}
+	return nil
+}
+
+const fakeIdentifier = `"((?:[^"]|"")*)"`
+
+var (
+	grantOnAll   = regexp.MustCompile(`^GRANT (\w+) ON ALL (\w+) IN SCHEMA ` + fakeIdentifier + ` TO ` + fakeIdentifier + `( WITH GRANT OPTION)?$`)
+	revokeOnAll  = regexp.MustCompile(`^REVOKE (GRANT OPTION FOR )?(\w+) ON ALL (\w+) IN SCHEMA ` + fakeIdentifier + ` FROM ` + fakeIdentifier + `$`)
+	setPassword  = regexp.MustCompile(`^(?:CREATE|ALTER) USER ` + fakeIdentifier + ` WITH PASSWORD '((?:[^']|'')*)'$`)
+	grantUsage   = regexp.MustCompile(`^(GRANT|REVOKE) USAGE ON SCHEMA ` + fakeIdentifier + ` (?:TO|FROM) ` + fakeIdentifier + `$`)
+	grantDefault = regexp.MustCompile(`^ALTER DEFAULT PRIVILEGES(?: FOR ROLE ` + fakeIdentifier + `)? IN SCHEMA ` + fakeIdentifier +
+		` (GRANT|REVOKE) (GRANT OPTION FOR )?(\w+) ON (\w+) (?:TO|FROM) ` + fakeIdentifier + `( WITH GRANT OPTION)?$`)
+)
+
+// apply carries out the effect of a statement run by user
+func (pg *fakePostgres) apply(user, query string) error {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+
+	if name, ok := strings.CutPrefix(query, "CREATE USER "); ok {
+		pg.roles[unquoteIdentifier(name)] = true
+	}
+	if name, ok := strings.CutPrefix(query, "REASSIGN OWNED BY "); ok {
+		delete(pg.owners, unquoteIdentifier(name))
+	}
+	if name, ok := strings.CutPrefix(query, "DROP OWNED BY "); ok {
+		delete(pg.owners, unquoteIdentifier(name))
+	}
+	if name, ok := strings.CutPrefix(query, "DROP USER IF EXISTS "); ok {
+		name = unquoteIdentifier(name)
+		if pg.owners[name] {
+			return &pq.Error{Code: "2BP01", Message: fmt.Sprintf("role %q cannot be dropped because some objects depend on it", name), Detail: "owner of table orders"}
+		}
+		delete(pg.roles, name)
+	}
+
+	unquote := func(s string) string { return strings.ReplaceAll(s, `""`, `"`) }
+	if m := setPassword.FindStringSubmatch(query); m != nil {
+		pg.passwords[unquote(m[1])] = strings.ReplaceAll(m[2], "''", "'")
+	}
+	if m := grantOnAll.FindStringSubmatch(query); m != nil {
+		privilege, kind, schema, grantee := strings.ToUpper(m[1]), m[2], unquote(m[3]), unquote(m[4])
+		for _, object := range pg.objects {
+			if object.kind == kind && object.schema == schema {
+				key := fakeGrant{grantee, schema, object.name, privilege}
+				pg.privileges[key] = pg.privileges[key] || m[5] != ""
+			}
+		}
+	}
+	if m := revokeOnAll.FindStringSubmatch(query); m != nil {
+		onlyGrantOption, privilege, kind, schema, grantee := m[1] != "", strings.ToUpper(m[2]), m[3], unquote(m[4]), unquote(m[5])
+		for _, object := range pg.objects {
+			key := fakeGrant{grantee, schema, object.name, privilege}
+			if _, ok := pg.privileges[key]; !ok || object.kind != kind || object.schema != schema {
+				continue
+			}
+			if onlyGrantOption {
+				pg.privileges[key] = false
+			} else {
+				delete(pg.privileges, key)
+			}
+		}
+	}
+	if m := grantUsage.FindStringSubmatch(query); m != nil {
+		key := fakeGrant{unquote(m[3]), unquote(m[2]), "", "USAGE"}
+		if m[1] == "GRANT" {
+			pg.privileges[key] = false
+		} else {
+			delete(pg.privileges, key)
+		}
+	}
+	if m := grantDefault.FindStringSubmatch(query); m != nil {
+		// Without FOR ROLE, default privileges apply to objects the current user creates
+		creator := user
+		if m[1] != "" {
+			creator = unquote(m[1])
+		}
+		key := fakeDefault{creator, unquote(m[2]), m[6], strings.ToUpper(m[5]), unquote(m[7])}
+		switch {
+		case m[3] == "GRANT":
+			pg.defaults[key] = pg.defaults[key] || m[8] != ""
+		case m[4] != "":
+			if _, ok := pg.defaults[key]; ok {
+				pg.defaults[key] = false
+			}
+		default:
+			delete(pg.defaults, key)
+		}
+	}
+	return nil
+}
+
+// missingPrivileges counts the objects of kind in schemas on which role
+// lacks privilege, which may end in WITH GRANT OPTION
+func (pg *fakePostgres) missingPrivileges(role, privilege, kind string, schemas []string) int64 {
+	privilege, withGrantOption := strings.CutSuffix(strings.ToUpper(privilege), " WITH GRANT OPTION")
+	var missing int64
+	for _, object := range pg.objects {
+		if object.kind != kind || !slices.Contains(schemas, object.schema) || object.owner == role {
+			continue
+		}
+		grantOption, held := pg.privileges[fakeGrant{role, object.schema, object.name, privilege}]
+		if !held || (withGrantOption && !grantOption) {
+			missing++
+		}
+	}
+	return missing
+}
+
+func (pg *fakePostgres) query(query string, args []driver.NamedValue) ([]driver.Value, error) {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	switch query {
+	case missingTablePrivilegeQuery:
+		return []driver.Value{pg.missingPrivileges(args[0].Value.(string), args[1].Value.(string), "TABLES", parseArray(args[2].Value))}, nil
+	case missingSequencePrivilegeQuery:
+		return []driver.Value{pg.missingPrivileges(args[0].Value.(string), args[1].Value.(string), "SEQUENCES", parseArray(args[2].Value))}, nil
+	case missingFunctionPrivilegeQuery:
+		return []driver.Value{pg.missingPrivileges(args[0].Value.(string), args[1].Value.(string), "FUNCTIONS", parseArray(args[2].Value))}, nil
+	}
+	switch {
+	case strings.Contains(query, "has_schema_privilege"):
+		role, schema := args[0].Value.(string), args[1].Value.(string)
+		_, usage := pg.privileges[fakeGrant{role, schema, "", "USAGE"}]
+		return []driver.Value{pg.schemas[schema], pg.schemas[schema] && usage}, nil
+	case strings.Contains(query, "FROM pg_roles"):
+		return []driver.Value{pg.roles[args[0].Value.(string)]}, nil
+	case strings.Contains(query, "FROM pg_database"):
+		return []driver.Value{pg.databases[args[0].Value.(string)]}, nil
+	case strings.Contains(query, "FROM pg_namespace"):
+		// Missing schemas for NOT EXISTS, present ones otherwise
+		want := !strings.Contains(query, "NOT EXISTS")
+		var names []string
+		for _, name := range parseArray(args[0].Value) {
+			if pg.schemas[name] == want {
+				names = append(names, name)
+			}
+		}
+		return []driver.Value{"{" + strings.Join(names, ",") + "}"}, nil
+	case query == "SHOW server_version_num":
+		return []driver.Value{"160000"}, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+// parseArray reads a text array as pq.Array passes it
+func parseArray(value driver.Value) []string {
+	var names []string
+	for _, name := range strings.Split(strings.Trim(value.(string), "{}"), ",") {
+		names = append(names, strings.Trim(name, `"`))
+	}
+	return names
+}
+
+// unquoteIdentifier returns the first identifier quoted by quoteIdentifier in s
+func unquoteIdentifier(s string) string {
+	s = strings.TrimPrefix(s, `"`)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '"' {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '"' {
+			i++
+			continue
+		}
+		return strings.ReplaceAll(s[:i], `""`, `"`)
+	}
+	return s
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	pg := currentFakePostgres
+	if pg == nil {
+		return nil, errors.New("no fake PostgreSQL server")
+	}
+	var database, user, password string
+	for _, field := range strings.Fields(dsn) {
+		if name, ok := strings.CutPrefix(field, "dbname="); ok {
+			database = name
+		}
+		if name, ok := strings.CutPrefix(field, "user="); ok {
+			user = name
+		}
+		if value, ok := strings.CutPrefix(field, "password="); ok {
+			password = strings.Trim(value, "'")
+		}
+	}
+
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	want, ok := pg.passwords[user]
+	if !ok {
+		want = pg.adminPassword
+	}
+	if want != "" && password != want {
+		return nil, fmt.Errorf("password authentication failed for user %q", user)
+	}
+	if !pg.databases[database] {
+		return nil, fmt.Errorf("database %q does not exist", database)
+	}
+	return &fakeConn{pg: pg, database: database, user: user}, nil
+}
+
+// fakeConn is a connection to one database of a fakePostgres
+type fakeConn struct {
+	pg       *fakePostgres
+	database string
+	user     string
+
+	// pending holds the statements of the open transaction, if any
+	pending *[]string
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if err := c.pg.record(c.database, "BEGIN"); err != nil {
+		return nil, err
+	}
+	c.pending = &[]string{}
+	return fakeTx{c}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.pg.record(c.database, query); err != nil {
+		return nil, err
+	}
+	if c.pending != nil {
+		*c.pending = append(*c.pending, query)
+	} else if err := c.pg.apply(c.user, query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values, err := c.pg.query(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{values: values}, nil
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	pending := *tx.c.pending
+	tx.c.pending = nil
+	if err := tx.c.pg.record(tx.c.database, "COMMIT"); err != nil {
+		return err
+	}
+	for _, query := range pending {
+		if err := tx.c.pg.apply(tx.c.user, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.c.pending = nil
+	return tx.c.pg.record(tx.c.database, "ROLLBACK")
+}
+
+// fakeRows is a result of a single row
+type fakeRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (r *fakeRows) Columns() []string {
+	columns := make([]string, len(r.values))
+	for i := range columns {
+		columns[i] = fmt.Sprintf("column%d", i+1)
+	}
+	return columns
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	copy(dest, r.values)
+	r.done = true
+	return nil
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	databasev1alpha1 "github.com/nutcas3/database-user-manager/api/v1alpha1"
+)
+
+const (
+	// maintenanceAnnotation set to "true" on a PostgresUser, or on the
+	// PostgresServer it references, defers all database operations
+	maintenanceAnnotation = "database.example.com/maintenance"
+
+	// maintenanceRetry is how often a user under maintenance is checked again
+	maintenanceRetry = time.Minute
+)
+
+// underMaintenance names the object whose maintenance annotation holds the
+// user back, or returns "" when there is none. A server that can't be
+// fetched doesn't hold the user back; resolving it reports the error.
+func (r *PostgresUserReconciler) underMaintenance(ctx context.Context, user *databasev1alpha1.PostgresUser) string {
+	if user.Annotations[maintenanceAnnotation] == "true" {
+		return "PostgresUser " + user.Name
+	}
+	if user.Spec.ServerRef == nil {
+		return ""
+	}
+
+	server := &databasev1alpha1.PostgresServer{}
+	if err := r.Get(ctx, types.NamespacedName{Name: user.Spec.ServerRef.Name, Namespace: user.Namespace}, server); err != nil {
+		return ""
+	}
+	if server.Annotations[maintenanceAnnotation] == "true" {
+		return "PostgresServer " + server.Name
+	}
+	return ""
+}
+
+// deferForMaintenance records in the Maintenance condition that the user's
+// database operations, including dropping the role on deletion, wait until
+// the maintenance annotation is removed. Ready keeps its last value, since
+// the role itself is unchanged.
+func (r *PostgresUserReconciler) deferForMaintenance(ctx context.Context, user *databasev1alpha1.PostgresUser, source string) (ctrl.Result, error) {
+	log.FromContext(ctx).Info("Deferring database operations during maintenance", "annotatedBy", source)
+
+	if !meta.IsStatusConditionTrue(user.Status.Conditions, "Maintenance") {
+		setCondition(user, "Maintenance", metav1.ConditionTrue, "MaintenanceInProgress",
+			fmt.Sprintf("Database operations are deferred while %s is annotated %s=true", source, maintenanceAnnotation))
+		if err := r.Status().Update(ctx, user); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: maintenanceRetry}, nil
+}
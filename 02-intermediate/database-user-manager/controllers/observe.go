@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/lib/pq"
+	databasev1alpha1 "github.com/nutcas3/database-user-manager/api/v1alpha1"
+)
+
+// observeInterval is how often observe-only mode re-checks a user, since
+// changes made directly in the database raise no Kubernetes events
+const observeInterval = 10 * time.Minute
+
+// Queries counting the objects in schemas $3 that a GRANT ... ON ALL
+// <objects> covers and on which user $1 lacks privilege $2
+const (
+	missingTablePrivilegeQuery = `SELECT count(*) FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ANY($3) AND c.relkind IN ('r', 'p', 'v', 'm', 'f')
+		AND NOT has_table_privilege($1, c.oid, $2)`
+	missingSequencePrivilegeQuery = `SELECT count(*) FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ANY($3) AND c.relkind = 'S'
+		AND NOT has_sequence_privilege($1, c.oid, $2)`
+	missingFunctionPrivilegeQuery = `SELECT count(*) FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = ANY($3)
+		AND NOT has_function_privilege($1, p.oid, $2)`
+)
+
+// observe compares the database with the spec and reports the differences in
+// the InSync condition, without running any DDL or writing the Secret
+func (r *PostgresUserReconciler) observe(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	drift, err := r.detectDrift(ctx, db, user)
+	if err != nil {
+		log.Error(err, "Failed to observe user")
+		r.updateStatus(ctx, user, false, fmt.Sprintf("Observation failed: %v", err))
+		return ctrl.Result{}, err
+	}
+
+	if len(drift) > 0 {
+		log.Info("User differs from the database", "differences", drift)
+		setCondition(user, "InSync", metav1.ConditionFalse, "DriftDetected", strings.Join(drift, "; "))
+		r.updateStatus(ctx, user, false, fmt.Sprintf("Observed %d difference(s) from the database", len(drift)))
+	} else {
+		setCondition(user, "InSync", metav1.ConditionTrue, "Observed", "Role, memberships and privileges match the spec")
+		r.updateStatus(ctx, user, true, "User matches the database")
+	}
+
+	return ctrl.Result{RequeueAfter: observeInterval}, nil
+}
+
+// detectDrift lists how the role, its comment, its memberships and its privileges differ
+// from the spec. Privileges are effective ones, so grants through PUBLIC or
+// role membership count, and only missing privileges are reported.
+func (r *PostgresUserReconciler) detectDrift(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) ([]string, error) {
+	var canLogin bool
+	var connectionLimit int32
+	var validUntil sql.NullInt64
+	var comment string
+	err := db.QueryRowContext(ctx, `SELECT rolcanlogin, rolconnlimit,
+		CASE WHEN rolvaliduntil IS NULL OR rolvaliduntil = 'infinity' THEN NULL
+		ELSE EXTRACT(EPOCH FROM rolvaliduntil)::bigint END,
+		coalesce(shobj_description(oid, 'pg_authid'), '')
+		FROM pg_roles WHERE rolname = $1`, user.Spec.Username).Scan(&canLogin, &connectionLimit, &validUntil, &comment)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []string{fmt.Sprintf("role %s does not exist", user.Spec.Username)}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var drift []string
+	if canLogin != loginEnabled(user) {
+		drift = append(drift, fmt.Sprintf("login is %t, want %t", canLogin, loginEnabled(user)))
+	}
+
+	wantLimit := int32(-1)
+	if user.Spec.ConnectionLimit != nil {
+		wantLimit = *user.Spec.ConnectionLimit
+	}
+	if connectionLimit != wantLimit {
+		drift = append(drift, fmt.Sprintf("connection limit is %d, want %d", connectionLimit, wantLimit))
+	}
+
+	switch {
+	case user.Spec.ValidUntil == nil && validUntil.Valid:
+		drift = append(drift, fmt.Sprintf("password expires at %s, want no expiry", time.Unix(validUntil.Int64, 0).UTC().Format(time.RFC3339)))
+	case user.Spec.ValidUntil != nil && (!validUntil.Valid || validUntil.Int64 != user.Spec.ValidUntil.Unix()):
+		drift = append(drift, fmt.Sprintf("password expiry differs from %s", user.Spec.ValidUntil.UTC().Format(time.RFC3339)))
+	}
+
+	if comment != user.Spec.Comment {
+		drift = append(drift, fmt.Sprintf("comment is %q, want %q", comment, user.Spec.Comment))
+	}
+
+	for _, role := range user.Spec.MemberOf {
+		var member bool
+		if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pg_auth_members m
+			JOIN pg_roles g ON g.oid = m.roleid JOIN pg_roles u ON u.oid = m.member
+			WHERE g.rolname = $1 AND u.rolname = $2)`, role, user.Spec.Username).Scan(&member); err != nil {
+			return nil, err
+		}
+		if !member {
+			drift = append(drift, fmt.Sprintf("not a member of %s", role))
+		}
+	}
+
+	var canConnect bool
+	if err := db.QueryRowContext(ctx, "SELECT has_database_privilege($1, $2, 'CONNECT')",
+		user.Spec.Username, user.Spec.Database).Scan(&canConnect); err != nil {
+		return nil, err
+	}
+	if !canConnect {
+		drift = append(drift, fmt.Sprintf("missing CONNECT on database %s", user.Spec.Database))
+	}
+
+	objectDrift, err := r.detectObjectPrivilegeDrift(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return append(drift, objectDrift...), nil
+}
+
+// detectObjectPrivilegeDrift reports missing USAGE on the user's schemas, and
+// table, sequence and function privileges in them that the user lacks on at
+// least one object
+func (r *PostgresUserReconciler) detectObjectPrivilegeDrift(ctx context.Context, user *databasev1alpha1.PostgresUser) ([]string, error) {
+	targetDB, err := r.connectToDatabase(ctx, user, user.Spec.Database)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database %s: %w", user.Spec.Database, err)
+	}
+	defer targetDB.Close()
+
+	var drift []string
+	for _, schema := range schemas(user) {
+		var exists, usage bool
+		if err := targetDB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM pg_namespace WHERE nspname = $2),
+			coalesce((SELECT has_schema_privilege($1, oid, 'USAGE') FROM pg_namespace WHERE nspname = $2), false)`,
+			user.Spec.Username, schema).Scan(&exists, &usage); err != nil {
+			return nil, fmt.Errorf("failed to check schema %s: %w", schema, err)
+		}
+		if !exists {
+			drift = append(drift, fmt.Sprintf("schema %s does not exist", schema))
+		} else if !usage {
+			drift = append(drift, fmt.Sprintf("missing USAGE on schema %s", schema))
+		}
+	}
+
+	objects := objectPrivileges(user)
+	checks := []struct {
+		objects string
+		query   string
+		granted objectPrivilege
+		all     []string
+	}{
+		{"tables", missingTablePrivilegeQuery, objects[0],
+			[]string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"}},
+		{"sequences", missingSequencePrivilegeQuery, objects[1],
+			[]string{"USAGE", "SELECT", "UPDATE"}},
+		{"functions", missingFunctionPrivilegeQuery, objects[2],
+			[]string{"EXECUTE"}},
+	}
+
+	inSchemas := "in schema " + strings.Join(schemas(user), ", ")
+	if len(schemas(user)) > 1 {
+		inSchemas = "in schemas " + strings.Join(schemas(user), ", ")
+	}
+	for _, check := range checks {
+		for _, priv := range check.granted.privileges {
+			grantOption := ""
+			if check.granted.withGrantOption(priv) {
+				grantOption = " WITH GRANT OPTION"
+			}
+
+			// has_*_privilege doesn't accept ALL, so check each privilege it stands for
+			expanded := []string{priv}
+			if upper := strings.ToUpper(priv); upper == "ALL" || upper == "ALL PRIVILEGES" {
+				expanded = check.all
+			}
+			for _, p := range expanded {
+				var missing int
+				if err := targetDB.QueryRowContext(ctx, check.query, user.Spec.Username, p+grantOption, pq.Array(schemas(user))).Scan(&missing); err != nil {
+					return nil, fmt.Errorf("failed to check %s on %s: %w", p, check.objects, err)
+				}
+				if missing > 0 {
+					drift = append(drift, fmt.Sprintf("missing %s%s on %d %s %s", p, grantOption, missing, check.objects, inSchemas))
+				}
+			}
+		}
+	}
+	return drift, nil
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	databasev1alpha1 "github.com/nutcas3/database-user-manager/api/v1alpha1"
+)
+
+// resolveServer fills in the connection settings of a PostgresUser that
+// references a PostgresServer. Only the in-memory copy is changed, so the
+// caller must not write the user's spec back afterwards.
+func (r *PostgresUserReconciler) resolveServer(ctx context.Context, user *databasev1alpha1.PostgresUser) error {
+	if user.Spec.ServerRef == nil {
+		return nil
+	}
+
+	if user.Spec.Host != "" || user.Spec.AdminHost != "" || user.Spec.AdminSecretRef.Name != "" ||
+		user.Spec.SSLRootCertConfigMapRef != nil {
+		return fmt.Errorf("host, adminHost, adminSecretRef and sslRootCertConfigMapRef must be empty when serverRef is set")
+	}
+
+	server := &databasev1alpha1.PostgresServer{}
+	if err := r.Get(ctx, types.NamespacedName{Name: user.Spec.ServerRef.Name, Namespace: user.Namespace}, server); err != nil {
+		return fmt.Errorf("failed to get PostgresServer %s: %w", user.Spec.ServerRef.Name, err)
+	}
+
+	user.Spec.Host = server.Spec.Host
+	user.Spec.Port = server.Spec.Port
+	user.Spec.AdminHost = server.Spec.AdminHost
+	user.Spec.AdminPort = server.Spec.AdminPort
+	user.Spec.AdminSecretRef = server.Spec.AdminSecretRef
+	user.Spec.SSLMode = server.Spec.SSLMode
+	user.Spec.SSLRootCertConfigMapRef = server.Spec.SSLRootCertConfigMapRef
+	return nil
+}
+
+// findUsersForServer maps a PostgresServer to the PostgresUsers referencing
+// it, so connection changes apply to all of them
+func (r *PostgresUserReconciler) findUsersForServer(ctx context.Context, server client.Object) []reconcile.Request {
+	users := &databasev1alpha1.PostgresUserList{}
+	if err := r.List(ctx, users, client.InNamespace(server.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+
+	var requests []reconcile.Request
+	for _, user := range users.Items {
+		if user.Spec.ServerRef != nil && user.Spec.ServerRef.Name == server.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      user.Name,
+					Namespace: user.Namespace,
+				},
+			})
+		}
+	}
+
+	return requests
+}
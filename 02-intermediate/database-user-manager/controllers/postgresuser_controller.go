@@ -5,36 +5,97 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"net"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
-	_ "github.com/lib/pq"
+	"github.com/go-logr/logr"
+	"github.com/lib/pq"
 	databasev1alpha1 "github.com/nutcas3/database-user-manager/api/v1alpha1"
 )
 
 const (
 	finalizerName = "postgresuser.database.example.com/finalizer"
+
+	// nextPasswordKey holds a staged password during a dual-password rotation
+	nextPasswordKey = "password-next"
+
+	// forceSyncAnnotation set to a new token re-applies the role, its
+	// password and the Secret, e.g. after a role was changed by hand
+	forceSyncAnnotation = "postgresuser.database.example.com/force-sync"
+
+	// authModeIAM authenticates with short-lived cloud IAM tokens instead of passwords
+	authModeIAM = "iam"
+
+	// rdsIAMRole is the RDS role that lets its members log in with IAM tokens
+	rdsIAMRole = "rds_iam"
+
+	// Deletion policies other than the default Drop
+	deletionPolicyDropOwned = "DropOwned"
+	deletionPolicyRetain    = "Retain"
+
+	// missingDatabaseRetry is how often a user whose database doesn't exist
+	// is checked again, since databases are created outside the cluster
+	missingDatabaseRetry = time.Minute
+)
+
+// sqlDriver names the database/sql driver connections are opened with
+var sqlDriver = "postgres"
+
+var (
+	// errOwnsObjects is returned when a user cannot be dropped because it still owns objects
+	errOwnsObjects = errors.New("user still owns database objects")
+
+	// errReleaseFailed is returned when a user cannot be dropped because its
+	// privileges or objects in the target database could not be released
+	errReleaseFailed = errors.New("failed to release the user's privileges and objects")
+
+	// errSchemaNotFound is returned when a schema listed in Schemas doesn't exist
+	errSchemaNotFound = errors.New("schema does not exist")
 )
 
 // PostgresUserReconciler reconciles a PostgresUser object
 type PostgresUserReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// TokenProvider issues tokens for PostgresUsers with authMode iam
+	TokenProvider TokenProvider
+
+	// ObserveOnly reports drift between the spec and the database without
+	// running any DDL or writing Secrets
+	ObserveOnly bool
+
+	// AuditLog records every SQL statement the operator executes, with
+	// passwords redacted. The zero value discards the entries.
+	AuditLog logr.Logger
 }
 
 // +kubebuilder:rbac:groups=database.example.com,resources=postgresusers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=database.example.com,resources=postgresusers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=database.example.com,resources=postgresusers/finalizers,verbs=update
+// +kubebuilder:rbac:groups=database.example.com,resources=postgresservers,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *PostgresUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -45,21 +106,42 @@ func (r *PostgresUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Leave the database alone during planned maintenance, when DDL would fail
+	if source := r.underMaintenance(ctx, user); source != "" {
+		return r.deferForMaintenance(ctx, user, source)
+	}
+	meta.RemoveStatusCondition(&user.Status.Conditions, "Maintenance")
+
 	// Handle deletion
 	if !user.DeletionTimestamp.IsZero() {
 		return r.handleDeletion(ctx, user)
 	}
 
-	// Add finalizer if not present
-	if !controllerutil.ContainsFinalizer(user, finalizerName) {
+	// Add finalizer if not present; observing never drops roles, so it needs none
+	if !r.ObserveOnly && !controllerutil.ContainsFinalizer(user, finalizerName) {
 		controllerutil.AddFinalizer(user, finalizerName)
 		if err := r.Update(ctx, user); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
+	// Take connection settings from the referenced PostgresServer
+	if err := r.resolveServer(ctx, user); err != nil {
+		log.Error(err, "Failed to resolve PostgresServer")
+		r.updateStatus(ctx, user, false, fmt.Sprintf("Invalid server reference: %v", err))
+		// A missing server is retried when it is created
+		return ctrl.Result{}, nil
+	}
+
+	// Validate spec
+	if err := validateSpec(user); err != nil {
+		log.Error(err, "Invalid PostgresUser spec")
+		r.updateStatus(ctx, user, false, fmt.Sprintf("Invalid spec: %v", err))
+		return ctrl.Result{}, nil
+	}
+
 	// Connect to database
-	db, err := r.connectToDatabase(ctx, user)
+	db, err := r.connectToDatabase(ctx, user, "postgres")
 	if err != nil {
 		log.Error(err, "Failed to connect to database")
 		r.updateStatus(ctx, user, false, fmt.Sprintf("Connection failed: %v", err))
@@ -67,6 +149,44 @@ func (r *PostgresUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 	}
 	defer db.Close()
 
+	// When admin traffic bypasses a pooler, make sure the app-facing endpoint is up too
+	if user.Spec.AdminHost != "" || user.Spec.AdminPort != 0 {
+		if err := checkReachable(ctx, user.Spec.Host, appPort(user)); err != nil {
+			log.Error(err, "Application endpoint unreachable")
+			r.updateStatus(ctx, user, false, fmt.Sprintf("Application endpoint unreachable: %v", err))
+			return ctrl.Result{}, err
+		}
+	}
+
+	if r.ObserveOnly {
+		return r.observe(ctx, db, user)
+	}
+
+	// A new force-sync token re-applies state the normal pass leaves alone
+	forceSyncToken := user.Annotations[forceSyncAnnotation]
+	forceSync := forceSyncToken != "" && forceSyncToken != user.Status.LastForceSyncToken
+	if forceSync {
+		log.Info("Forcing a full sync", "token", forceSyncToken)
+	}
+
+	// Make sure the database exists, so a missing one is named instead of
+	// surfacing as a failed GRANT CONNECT. This comes before the role is
+	// created or its password rotated, since the Secret is only written
+	// once the grants succeed.
+	dbExists, err := databaseExists(ctx, db, user.Spec.Database)
+	if err != nil {
+		log.Error(err, "Failed to check if database exists")
+		return ctrl.Result{}, err
+	}
+	if !dbExists {
+		log.Info("Database does not exist", "database", user.Spec.Database)
+		msg := fmt.Sprintf("Database %s does not exist", user.Spec.Database)
+		setCondition(user, "DatabaseExists", metav1.ConditionFalse, "DatabaseNotFound", msg)
+		r.updateStatus(ctx, user, false, msg)
+		return ctrl.Result{RequeueAfter: missingDatabaseRetry}, nil
+	}
+	setCondition(user, "DatabaseExists", metav1.ConditionTrue, "DatabaseFound", fmt.Sprintf("Database %s exists", user.Spec.Database))
+
 	// Check if user exists
 	exists, err := r.userExists(ctx, db, user)
 	if err != nil {
@@ -74,8 +194,39 @@ func (r *PostgresUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		return ctrl.Result{}, err
 	}
 
+	// Leave a role made by someone else alone unless adoption is allowed,
+	// since changing its password would lock out that tool's consumers
+	adopting := exists && !roleManaged(user)
+	if adopting && !user.Spec.AdoptExistingRole {
+		msg := fmt.Sprintf("Role %s already exists and was not created by this PostgresUser; set adoptExistingRole to manage it", user.Spec.Username)
+		log.Info("Role exists but is not managed", "username", user.Spec.Username)
+		setCondition(user, "Conflict", metav1.ConditionTrue, "RoleNotManaged", msg)
+		r.recordEvent(user, corev1.EventTypeWarning, "RoleConflict", msg)
+		r.updateStatus(ctx, user, false, msg)
+		return ctrl.Result{}, nil
+	}
+	if adopting {
+		log.Info("Adopting existing role", "username", user.Spec.Username)
+	}
+
 	var password string
-	if !exists || user.Spec.RotatePassword {
+	var requeueAfter time.Duration
+	if iamAuth(user) {
+		// IAM users log in with tokens, so there is no password to manage
+		if err := r.reconcileIAMUser(ctx, db, user, exists); err != nil {
+			log.Error(err, "Failed to set up IAM user")
+			r.updateStatus(ctx, user, false, fmt.Sprintf("IAM user setup failed: %v", err))
+			return ctrl.Result{}, err
+		}
+	} else if exists && user.Spec.RotatePassword && user.Spec.DualPasswordWindow != nil && loginEnabled(user) {
+		// Stage the new password so consumers can roll over before it takes effect
+		password, requeueAfter, err = r.stagedRotation(ctx, db, user)
+		if err != nil {
+			log.Error(err, "Failed to rotate password")
+			r.updateStatus(ctx, user, false, fmt.Sprintf("Password rotation failed: %v", err))
+			return ctrl.Result{}, err
+		}
+	} else if !exists || user.Spec.RotatePassword {
 		// Create or update user
 		password, err = r.createOrUpdateUser(ctx, db, user)
 		if err != nil {
@@ -85,9 +236,11 @@ func (r *PostgresUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 
 		// Update password rotation timestamp
-		now := metav1.Now()
-		user.Status.LastPasswordRotation = &now
-	} else {
+		if password != "" {
+			now := metav1.Now()
+			user.Status.LastPasswordRotation = &now
+		}
+	} else if user.Spec.SecretName != "" {
 		// Get existing password from secret
 		secret := &corev1.Secret{}
 		if err := r.Get(ctx, types.NamespacedName{
@@ -95,45 +248,204 @@ func (r *PostgresUserReconciler) Reconcile(ctx context.Context, req ctrl.Request
 			Namespace: user.Namespace,
 		}, secret); err == nil {
 			password = string(secret.Data["password"])
+		} else if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to get credentials secret")
+			return ctrl.Result{}, err
+		}
+
+		// Push the stored password back onto the role, or issue a new one if the
+		// Secret lost it. An adopted role gets the same, so the Secret works with it.
+		// A Secret that was never written, because a pass failed after creating
+		// the role, also gets a new password.
+		if (forceSync || adopting || password == "") && loginEnabled(user) {
+			password, err = r.resetPassword(ctx, db, user, password)
+			if err != nil {
+				log.Error(err, "Failed to reset password")
+				r.updateStatus(ctx, user, false, fmt.Sprintf("Forced sync failed: %v", err))
+				return ctrl.Result{}, err
+			}
 		}
 	}
 
+	if !user.Status.RoleManaged {
+		user.Status.RoleManaged = true
+		setCondition(user, "Conflict", metav1.ConditionFalse, "RoleManaged", fmt.Sprintf("Role %s is managed by this PostgresUser", user.Spec.Username))
+		if adopting {
+			r.recordEvent(user, corev1.EventTypeNormal, "RoleAdopted", fmt.Sprintf("Adopted existing role %s", user.Spec.Username))
+		}
+	}
+
+	// Reconcile role attributes independently of the password
+	if err := r.reconcileAttributes(ctx, db, user); err != nil {
+		log.Error(err, "Failed to update user attributes")
+		r.updateStatus(ctx, user, false, fmt.Sprintf("Attribute update failed: %v", err))
+		return ctrl.Result{}, err
+	}
+
 	// Grant privileges
-	if err := r.grantPrivileges(ctx, db, user); err != nil {
+	if err := r.grantPrivileges(ctx, user); errors.Is(err, errSchemaNotFound) {
+		// Like a missing database, a missing schema is created outside the cluster
+		log.Info("Schema does not exist", "error", err.Error())
+		setCondition(user, "PrivilegesGranted", metav1.ConditionFalse, "SchemaNotFound", err.Error())
+		r.updateStatus(ctx, user, false, err.Error())
+		return ctrl.Result{RequeueAfter: missingDatabaseRetry}, nil
+	} else if err != nil {
 		log.Error(err, "Failed to grant privileges")
+		setCondition(user, "PrivilegesGranted", metav1.ConditionFalse, "GrantRolledBack", fmt.Sprintf("Privilege grants were rolled back: %v", err))
 		r.updateStatus(ctx, user, false, fmt.Sprintf("Privilege grant failed: %v", err))
 		return ctrl.Result{}, err
 	}
+	setCondition(user, "PrivilegesGranted", metav1.ConditionTrue, "GrantsCommitted", "All privilege grants were committed")
+
+	// Grant role memberships
+	if err := r.grantMemberships(ctx, db, user); err != nil {
+		log.Error(err, "Failed to grant role memberships")
+		r.updateStatus(ctx, user, false, fmt.Sprintf("Membership grant failed: %v", err))
+		return ctrl.Result{}, err
+	}
 
 	// Create or update secret with credentials
-	if password != "" {
+	if password != "" || iamAuth(user) {
 		if err := r.createOrUpdateSecret(ctx, user, password); err != nil {
 			log.Error(err, "Failed to create/update secret")
+			// Keep RoleManaged, so the retry doesn't take the new role for someone else's
+			r.updateStatus(ctx, user, false, fmt.Sprintf("Secret update failed: %v", err))
 			return ctrl.Result{}, err
 		}
 	}
 
+	// Confirm the new credentials can actually log in, e.g. past pg_hba rules
+	if user.Spec.ValidateCredentials && loginEnabled(user) && (password != "" || iamAuth(user)) {
+		if err := r.validateCredentials(ctx, user, password); err != nil {
+			log.Error(err, "Credential validation failed")
+			setCondition(user, "CredentialsValidated", metav1.ConditionFalse, "ConnectionFailed", fmt.Sprintf("Could not connect as %s: %v", user.Spec.Username, err))
+		} else {
+			setCondition(user, "CredentialsValidated", metav1.ConditionTrue, "ConnectionSucceeded", fmt.Sprintf("Connected as %s", user.Spec.Username))
+		}
+	} else if !user.Spec.ValidateCredentials {
+		meta.RemoveStatusCondition(&user.Status.Conditions, "CredentialsValidated")
+	}
+
+	if forceSync {
+		user.Status.LastForceSyncToken = forceSyncToken
+		r.recordEvent(user, corev1.EventTypeNormal, "ForceSync", fmt.Sprintf("Forced sync %q re-applied the role, grants and Secret", forceSyncToken))
+	}
+
 	// Update status
 	r.updateStatus(ctx, user, true, "User ready")
 
 	log.Info("Successfully reconciled PostgresUser")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// stagedRotation rotates the password in two steps. It first publishes a new
+// password under the Secret's password-next key, then, once DualPasswordWindow
+// has passed, applies it to the role and promotes it to the password key. It
+// returns the password currently valid for the role and when to check again.
+func (r *PostgresUserReconciler) stagedRotation(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) (string, time.Duration, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      user.Spec.SecretName,
+		Namespace: user.Namespace,
+	}, secret); err != nil {
+		return "", 0, err
+	}
+	current := string(secret.Data["password"])
+	next := string(secret.Data[nextPasswordKey])
+
+	if next == "" {
+		if secret.Data == nil {
+			secret.Data = make(map[string][]byte)
+		}
+		secret.Data[nextPasswordKey] = []byte(generatePassword(32))
+		if err := r.Update(ctx, secret); err != nil {
+			return "", 0, err
+		}
+		now := metav1.Now()
+		user.Status.PasswordRotationStarted = &now
+		setCondition(user, "RotationInProgress", metav1.ConditionTrue, "NewPasswordStaged",
+			fmt.Sprintf("New password staged in %s/%s, applied after %s", secret.Name, nextPasswordKey, user.Spec.DualPasswordWindow.Duration))
+		r.recordEvent(user, corev1.EventTypeNormal, "RotationStarted", "New password staged in Secret key "+nextPasswordKey)
+		return current, user.Spec.DualPasswordWindow.Duration, nil
+	}
+
+	if user.Status.PasswordRotationStarted == nil {
+		now := metav1.Now()
+		user.Status.PasswordRotationStarted = &now
+	}
+	if remaining := time.Until(user.Status.PasswordRotationStarted.Add(user.Spec.DualPasswordWindow.Duration)); remaining > 0 {
+		return current, remaining, nil
+	}
+
+	// Apply to the role first; if the Secret update then fails, the next pass
+	// applies the same staged password again before retrying it
+	if err := r.setPassword(ctx, db, user, "ALTER", next); err != nil {
+		return "", 0, err
+	}
+	secret.Data["password"] = []byte(next)
+	delete(secret.Data, nextPasswordKey)
+	if err := r.Update(ctx, secret); err != nil {
+		return "", 0, err
+	}
+
+	now := metav1.Now()
+	user.Status.LastPasswordRotation = &now
+	user.Status.PasswordRotationStarted = nil
+	setCondition(user, "RotationInProgress", metav1.ConditionFalse, "RotationComplete", "Staged password is now active")
+	r.recordEvent(user, corev1.EventTypeNormal, "RotationComplete", "Staged password applied to the role")
+	return next, 0, nil
+}
+
+// recordEvent emits an event when the reconciler has a recorder
+func (r *PostgresUserReconciler) recordEvent(user *databasev1alpha1.PostgresUser, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(user, eventType, reason, message)
+	}
 }
 
 func (r *PostgresUserReconciler) handleDeletion(ctx context.Context, user *databasev1alpha1.PostgresUser) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	if controllerutil.ContainsFinalizer(user, finalizerName) {
+		// Resolve the server on a copy, since the user is written back below
+		conn := user.DeepCopy()
+
 		// Connect to database
-		db, err := r.connectToDatabase(ctx, user)
-		if err != nil {
+		var db *sql.DB
+		var err error
+		if r.ObserveOnly {
+			// Leave the role in place; only a writing operator may drop it
+			log.Info("Observe-only mode, not dropping user", "username", user.Spec.Username)
+		} else if !roleManaged(user) {
+			// The role belongs to someone else, or was never created
+			log.Info("Role is not managed, not dropping user", "username", user.Spec.Username)
+		} else if user.Spec.DeletionPolicy == deletionPolicyRetain {
+			log.Info("Deletion policy is Retain, not dropping user", "username", user.Spec.Username)
+		} else if err = r.resolveServer(ctx, conn); err != nil {
+			log.Error(err, "Failed to resolve PostgresServer for cleanup")
+			// Continue with finalizer removal, as when the server is unreachable
+		} else if db, err = r.connectToDatabase(ctx, conn, "postgres"); err != nil {
 			log.Error(err, "Failed to connect to database for cleanup")
 			// Continue with finalizer removal even if connection fails
 		} else {
 			defer db.Close()
 
 			// Drop user
-			if err := r.dropUser(ctx, db, user); err != nil {
+			if err := r.dropUser(ctx, db, conn); err != nil {
+				if errors.Is(err, errOwnsObjects) || errors.Is(err, errReleaseFailed) {
+					// Keep the finalizer so the role is not leaked; the user
+					// can set reassignOwnedTo or deletionPolicy to unblock deletion
+					reason := "DependentObjects"
+					if errors.Is(err, errReleaseFailed) {
+						reason = "ReleaseFailed"
+					}
+					setCondition(user, "DropBlocked", metav1.ConditionTrue, reason, err.Error())
+					r.recordEvent(user, corev1.EventTypeWarning, "DropBlocked", err.Error())
+					if statusErr := r.updateStatus(ctx, user, false, err.Error()); statusErr != nil {
+						log.Error(statusErr, "Failed to update status")
+					}
+					return ctrl.Result{}, err
+				}
 				log.Error(err, "Failed to drop user")
 				// Continue with finalizer removal
 			}
@@ -149,7 +461,7 @@ func (r *PostgresUserReconciler) handleDeletion(ctx context.Context, user *datab
 	return ctrl.Result{}, nil
 }
 
-func (r *PostgresUserReconciler) connectToDatabase(ctx context.Context, user *databasev1alpha1.PostgresUser) (*sql.DB, error) {
+func (r *PostgresUserReconciler) connectToDatabase(ctx context.Context, user *databasev1alpha1.PostgresUser, dbname string) (*sql.DB, error) {
 	// Get admin credentials
 	secret := &corev1.Secret{}
 	if err := r.Get(ctx, types.NamespacedName{
@@ -159,18 +471,30 @@ func (r *PostgresUserReconciler) connectToDatabase(ctx context.Context, user *da
 		return nil, fmt.Errorf("failed to get admin secret: %w", err)
 	}
 
-	port := user.Spec.Port
-	if port == 0 {
-		port = 5432
+	sslParams, err := r.sslParams(ctx, user)
+	if err != nil {
+		return nil, err
 	}
 
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=postgres sslmode=disable",
-		user.Spec.Host,
+	host, port := adminEndpoint(user)
+	password := string(secret.Data["password"])
+	if iamAuth(user) {
+		// The admin Secret only names the admin user; a fresh token replaces its password
+		password, err = r.iamToken(ctx, host, port, string(secret.Data["username"]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get admin IAM token: %w", err)
+		}
+	}
+
+	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s %s",
+		host,
 		port,
 		string(secret.Data["username"]),
-		string(secret.Data["password"]))
+		quoteConnValue(password),
+		dbname,
+		sslParams)
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := sql.Open(sqlDriver, connStr)
 	if err != nil {
 		return nil, err
 	}
@@ -183,6 +507,72 @@ func (r *PostgresUserReconciler) connectToDatabase(ctx context.Context, user *da
 	return db, nil
 }
 
+// sslParams returns the TLS connection parameters for the user. The CA bundle
+// is passed inline rather than through a temporary file, because pooled
+// connections may be re-established after the first one is opened.
+func (r *PostgresUserReconciler) sslParams(ctx context.Context, user *databasev1alpha1.PostgresUser) (string, error) {
+	mode := user.Spec.SSLMode
+	if mode == "" {
+		mode = "disable"
+	}
+	params := "sslmode=" + mode
+
+	ref := user.Spec.SSLRootCertConfigMapRef
+	if ref == nil || mode == "disable" {
+		return params, nil
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: user.Namespace}, configMap); err != nil {
+		return "", fmt.Errorf("failed to get CA ConfigMap: %w", err)
+	}
+	ca, ok := configMap.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("CA ConfigMap %s has no key %s", ref.Name, ref.Key)
+	}
+
+	return fmt.Sprintf("%s sslinline=true sslrootcert=%s", params, quoteConnValue(ca)), nil
+}
+
+// quoteConnValue quotes a value for a libpq key/value connection string
+func quoteConnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `'` + strings.ReplaceAll(value, `'`, `\'`) + `'`
+}
+
+// validateCredentials connects to the application endpoint as the user
+func (r *PostgresUserReconciler) validateCredentials(ctx context.Context, user *databasev1alpha1.PostgresUser, password string) error {
+	sslParams, err := r.sslParams(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	if iamAuth(user) {
+		password, err = r.iamToken(ctx, user.Spec.Host, appPort(user), user.Spec.Username)
+		if err != nil {
+			return err
+		}
+	}
+
+	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s %s",
+		user.Spec.Host,
+		appPort(user),
+		user.Spec.Username,
+		quoteConnValue(password),
+		user.Spec.Database,
+		sslParams)
+
+	db, err := sql.Open(sqlDriver, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
+}
+
 func (r *PostgresUserReconciler) userExists(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) (bool, error) {
 	var exists bool
 	query := "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)"
@@ -190,70 +580,299 @@ func (r *PostgresUserReconciler) userExists(ctx context.Context, db *sql.DB, use
 	return exists, err
 }
 
-func (r *PostgresUserReconciler) createOrUpdateUser(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) (string, error) {
-	password := generatePassword(32)
+// roleManaged reports whether the operator created or adopted the user's role.
+// Users that were reconciled before RoleManaged was recorded count as managed.
+func roleManaged(user *databasev1alpha1.PostgresUser) bool {
+	return user.Status.RoleManaged || user.Status.Ready || user.Status.LastPasswordRotation != nil
+}
+
+// databaseExists reports whether the named database exists on the server
+func databaseExists(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname = $1)", name).Scan(&exists)
+	return exists, err
+}
 
+func (r *PostgresUserReconciler) createOrUpdateUser(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) (string, error) {
 	exists, err := r.userExists(ctx, db, user)
 	if err != nil {
 		return "", err
 	}
 
-	if exists {
-		// Update password
-		query := fmt.Sprintf("ALTER USER %s WITH PASSWORD '%s'",
-			quoteIdentifier(user.Spec.Username),
-			password)
-		if _, err := db.ExecContext(ctx, query); err != nil {
-			return "", err
+	// Roles without login carry no password
+	if !loginEnabled(user) {
+		if exists {
+			return "", nil
 		}
-	} else {
-		// Create user
-		query := fmt.Sprintf("CREATE USER %s WITH PASSWORD '%s'",
-			quoteIdentifier(user.Spec.Username),
-			password)
-		if _, err := db.ExecContext(ctx, query); err != nil {
+		query := fmt.Sprintf("CREATE USER %s WITH NOLOGIN",
+			quoteIdentifier(user.Spec.Username))
+		if err := r.exec(ctx, db, user, "postgres", query); err != nil {
 			return "", err
 		}
+		return "", nil
+	}
+
+	verb := "CREATE"
+	if exists {
+		verb = "ALTER"
+	}
+
+	password := generatePassword(32)
+	if err := r.setPassword(ctx, db, user, verb, password); err != nil {
+		return "", err
 	}
 
 	return password, nil
 }
 
-func (r *PostgresUserReconciler) grantPrivileges(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) error {
-	// Grant database access
-	query := fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s",
-		quoteIdentifier(user.Spec.Database),
-		quoteIdentifier(user.Spec.Username))
-	if _, err := db.ExecContext(ctx, query); err != nil {
+// reconcileIAMUser creates the role for token login and clears any password,
+// which would otherwise keep working next to IAM. On RDS the role is granted
+// rds_iam; where that role doesn't exist, e.g. on Cloud SQL, the IAM user must
+// be registered with the cloud provider.
+func (r *PostgresUserReconciler) reconcileIAMUser(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser, exists bool) error {
+	query := fmt.Sprintf("ALTER USER %s WITH PASSWORD NULL", quoteIdentifier(user.Spec.Username))
+	if !exists {
+		query = fmt.Sprintf("CREATE USER %s WITH LOGIN", quoteIdentifier(user.Spec.Username))
+	}
+	if err := r.exec(ctx, db, user, "postgres", query); err != nil {
 		return err
 	}
 
-	// Connect to target database
-	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		user.Spec.Host,
-		user.Spec.Port,
-		"postgres", // Use admin user
-		"",         // Would need admin password
-		user.Spec.Database)
+	var hasRDSIAM bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM pg_roles WHERE rolname = $1)", rdsIAMRole).Scan(&hasRDSIAM); err != nil {
+		return err
+	}
+	if !hasRDSIAM {
+		return nil
+	}
+	return r.exec(ctx, db, user, "postgres", fmt.Sprintf("GRANT %s TO %s", quoteIdentifier(rdsIAMRole), quoteIdentifier(user.Spec.Username)))
+}
+
+// iamToken returns a token for username at host:port from the configured provider
+func (r *PostgresUserReconciler) iamToken(ctx context.Context, host string, port int32, username string) (string, error) {
+	if r.TokenProvider == nil {
+		return "", fmt.Errorf("authMode iam needs the operator to be started with --iam-token-command")
+	}
+	return r.TokenProvider.Token(ctx, host, port, username)
+}
 
-	targetDB, err := sql.Open("postgres", connStr)
+// resetPassword sets the role's password to password, generating a new one
+// when it is empty, and returns the password now in effect
+func (r *PostgresUserReconciler) resetPassword(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser, password string) (string, error) {
+	if password == "" {
+		password = generatePassword(32)
+	}
+	if err := r.setPassword(ctx, db, user, "ALTER", password); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// setPassword runs CREATE or ALTER USER with password. When PasswordEncryption
+// is set, password_encryption is switched for this statement only, inside a
+// transaction, so the pooled admin connection keeps the server default.
+func (r *PostgresUserReconciler) setPassword(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser, verb, password string) error {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if encryption := user.Spec.PasswordEncryption; encryption != "" {
+		if encryption == "scram-sha-256" {
+			var version int
+			if err := tx.QueryRowContext(ctx, "SHOW server_version_num").Scan(&version); err != nil {
+				return err
+			}
+			if version < 100000 {
+				return fmt.Errorf("scram-sha-256 requires PostgreSQL 10 or later, server is %d", version)
+			}
+		}
+		if err := r.exec(ctx, tx, user, "postgres", "SET LOCAL password_encryption = "+quoteLiteral(encryption)); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf("%s USER %s WITH PASSWORD %s", verb, quoteIdentifier(user.Spec.Username), quoteLiteral(password))
+	if err := r.exec(ctx, tx, user, "postgres", query); err != nil {
+		return err
+	}
+	return r.commit(tx, user, "postgres")
+}
+
+// reconcileAttributes applies login, connection limit, expiry and the comment to the role.
+// It never touches the password, so changing an attribute doesn't rotate it.
+func (r *PostgresUserReconciler) reconcileAttributes(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) error {
+	login := "LOGIN"
+	if !loginEnabled(user) {
+		login = "NOLOGIN"
+	}
+
+	connectionLimit := int32(-1)
+	if user.Spec.ConnectionLimit != nil {
+		connectionLimit = *user.Spec.ConnectionLimit
+	}
+
+	validUntil := "infinity"
+	if user.Spec.ValidUntil != nil {
+		validUntil = user.Spec.ValidUntil.UTC().Format(time.RFC3339)
+	}
+
+	query := fmt.Sprintf("ALTER USER %s WITH %s CONNECTION LIMIT %d VALID UNTIL %s",
+		quoteIdentifier(user.Spec.Username),
+		login,
+		connectionLimit,
+		quoteLiteral(validUntil))
+	if err := r.exec(ctx, db, user, "postgres", query); err != nil {
+		return err
+	}
+
+	comment := "NULL"
+	if user.Spec.Comment != "" {
+		comment = quoteLiteral(user.Spec.Comment)
+	}
+	return r.exec(ctx, db, user, "postgres", fmt.Sprintf("COMMENT ON ROLE %s IS %s", quoteIdentifier(user.Spec.Username), comment))
+}
+
+// grantPrivileges applies all grants in a single transaction on the target
+// database so a failure midway never leaves the user partially privileged
+func (r *PostgresUserReconciler) grantPrivileges(ctx context.Context, user *databasev1alpha1.PostgresUser) error {
+	// Connect to target database
+	targetDB, err := r.connectToDatabase(ctx, user, user.Spec.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", user.Spec.Database, err)
+	}
 	defer targetDB.Close()
 
-	// Grant privileges on all tables
-	for _, priv := range user.Spec.Privileges {
-		query := fmt.Sprintf("GRANT %s ON ALL TABLES IN SCHEMA public TO %s",
-			priv, quoteIdentifier(user.Spec.Username))
-		if _, err := targetDB.ExecContext(ctx, query); err != nil {
+	// Check the schemas up front, so a missing one is named instead of
+	// failing the first GRANT that mentions it
+	var missing []string
+	if err := targetDB.QueryRowContext(ctx, `SELECT coalesce(array_agg(s.name), '{}') FROM unnest($1::text[]) AS s(name)
+		WHERE NOT EXISTS (SELECT 1 FROM pg_namespace n WHERE n.nspname = s.name)`, pq.Array(schemas(user))).Scan(pq.Array(&missing)); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s in database %s", errSchemaNotFound, strings.Join(missing, ", "), user.Spec.Database)
+	}
+
+	// Grant database and schema access
+	queries := []string{
+		fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s",
+			quoteIdentifier(user.Spec.Database),
+			quoteIdentifier(user.Spec.Username)),
+	}
+	for _, schema := range schemas(user) {
+		queries = append(queries, fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s",
+			quoteIdentifier(schema), quoteIdentifier(user.Spec.Username)))
+	}
+
+	defaultFor := defaultPrivilegesFor(user)
+	for _, schema := range schemas(user) {
+		inSchema := "IN SCHEMA " + quoteIdentifier(schema)
+		for _, op := range objectPrivileges(user) {
+			for _, priv := range op.privileges {
+				withGrantOption := op.withGrantOption(priv)
+				grantOption := ""
+				if withGrantOption {
+					grantOption = " WITH GRANT OPTION"
+				}
+
+				// Grant privileges on all existing objects
+				queries = append(queries, fmt.Sprintf("GRANT %s ON ALL %s %s TO %s%s",
+					priv, op.objects, inSchema, quoteIdentifier(user.Spec.Username), grantOption))
+
+				// Grant on future objects
+				for _, forRole := range defaultFor {
+					queries = append(queries, fmt.Sprintf("ALTER DEFAULT PRIVILEGES%s %s GRANT %s ON %s TO %s%s",
+						forRole, inSchema, priv, op.objects, quoteIdentifier(user.Spec.Username), grantOption))
+				}
+
+				// Drop a grant option left over from when it was enabled
+				if !withGrantOption {
+					queries = append(queries, fmt.Sprintf("REVOKE GRANT OPTION FOR %s ON ALL %s %s FROM %s",
+						priv, op.objects, inSchema, quoteIdentifier(user.Spec.Username)))
+					for _, forRole := range defaultFor {
+						queries = append(queries, fmt.Sprintf("ALTER DEFAULT PRIVILEGES%s %s REVOKE GRANT OPTION FOR %s ON %s FROM %s",
+							forRole, inSchema, priv, op.objects, quoteIdentifier(user.Spec.Username)))
+					}
+				}
+			}
+		}
+	}
+
+	tx, err := targetDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	for _, query := range queries {
+		if err := r.exec(ctx, tx, user, user.Spec.Database, query); err != nil {
 			return err
 		}
+	}
+
+	return r.commit(tx, user, user.Spec.Database)
+}
+
+// objectPrivilege is the privileges granted on one kind of schema object,
+// and those of them granted with the grant option
+type objectPrivilege struct {
+	objects     string
+	privileges  []string
+	grantOption []string
+}
+
+// withGrantOption reports whether priv is granted with the grant option
+func (op objectPrivilege) withGrantOption(priv string) bool {
+	for _, p := range op.grantOption {
+		if strings.EqualFold(p, priv) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectPrivileges returns the privileges granted on tables, sequences and functions
+func objectPrivileges(user *databasev1alpha1.PostgresUser) []objectPrivilege {
+	grantOption := user.Spec.GrantOption
+	if grantOption == nil {
+		grantOption = &databasev1alpha1.GrantOptionSpec{}
+	}
+	return []objectPrivilege{
+		{"TABLES", user.Spec.Privileges, grantOption.Tables},
+		{"SEQUENCES", user.Spec.SequencePrivileges, grantOption.Sequences},
+		{"FUNCTIONS", user.Spec.FunctionPrivileges, grantOption.Functions},
+	}
+}
+
+// defaultPrivilegesFor returns the FOR ROLE clauses of ALTER DEFAULT
+// PRIVILEGES. Default privileges apply to objects the admin user creates,
+// plus those created by each listed creator role.
+func defaultPrivilegesFor(user *databasev1alpha1.PostgresUser) []string {
+	defaultFor := []string{""}
+	for _, role := range user.Spec.DefaultPrivilegesFor {
+		defaultFor = append(defaultFor, " FOR ROLE "+quoteIdentifier(role))
+	}
+	return defaultFor
+}
+
+// schemas returns the schemas privileges are granted in, defaulting to public
+func schemas(user *databasev1alpha1.PostgresUser) []string {
+	if len(user.Spec.Schemas) == 0 {
+		return []string{"public"}
+	}
+	return user.Spec.Schemas
+}
 
-		// Grant on future tables
-		query = fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT %s ON TABLES TO %s",
-			priv, quoteIdentifier(user.Spec.Username))
-		if _, err := targetDB.ExecContext(ctx, query); err != nil {
+func (r *PostgresUserReconciler) grantMemberships(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) error {
+	for _, role := range user.Spec.MemberOf {
+		query := fmt.Sprintf("GRANT %s TO %s",
+			quoteIdentifier(role),
+			quoteIdentifier(user.Spec.Username))
+		if err := r.exec(ctx, db, user, "postgres", query); err != nil {
 			return err
 		}
 	}
@@ -261,10 +880,128 @@ func (r *PostgresUserReconciler) grantPrivileges(ctx context.Context, db *sql.DB
 	return nil
 }
 
+// dropUser revokes the privileges the operator granted, hands over or drops
+// the objects the user owns as configured, and drops the role
 func (r *PostgresUserReconciler) dropUser(ctx context.Context, db *sql.DB, user *databasev1alpha1.PostgresUser) error {
+	exists, err := r.userExists(ctx, db, user)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+
+	// Privileges and objects in a database that is gone went with it
+	dbExists, err := databaseExists(ctx, db, user.Spec.Database)
+	if err != nil {
+		return err
+	}
+	if dbExists {
+		if err := r.releaseObjects(ctx, user); err != nil {
+			return fmt.Errorf("%w: %w", errReleaseFailed, err)
+		}
+	}
+
 	query := fmt.Sprintf("DROP USER IF EXISTS %s", quoteIdentifier(user.Spec.Username))
-	_, err := db.ExecContext(ctx, query)
-	return err
+	if err := r.exec(ctx, db, user, "postgres", query); err != nil {
+		var pqErr *pq.Error
+		// 2BP01 is dependent_objects_still_exist
+		if errors.As(err, &pqErr) && pqErr.Code == "2BP01" {
+			return fmt.Errorf("%w; set reassignOwnedTo to transfer them or deletionPolicy DropOwned to drop them, "+
+				"which only covers database %s, so objects in other databases must be released by hand: %s",
+				errOwnsObjects, user.Spec.Database, pqErr.Detail)
+		}
+		return err
+	}
+	return nil
+}
+
+// releaseObjects revokes the managed privileges in the target database in
+// a single transaction. It then hands the user's objects to ReassignOwnedTo
+// or, with the DropOwned policy, drops them, along with any privileges
+// granted outside the operator, so the role can be dropped. Both only reach
+// the target database; objects in other databases still block the drop.
+func (r *PostgresUserReconciler) releaseObjects(ctx context.Context, user *databasev1alpha1.PostgresUser) error {
+	log := log.FromContext(ctx)
+
+	targetDB, err := r.connectToDatabase(ctx, user, user.Spec.Database)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database %s: %w", user.Spec.Database, err)
+	}
+	defer targetDB.Close()
+
+	// Schemas dropped since the grants hold no privileges to revoke
+	var present []string
+	if err := targetDB.QueryRowContext(ctx, `SELECT coalesce(array_agg(s.name), '{}') FROM unnest($1::text[]) AS s(name)
+		WHERE EXISTS (SELECT 1 FROM pg_namespace n WHERE n.nspname = s.name)`, pq.Array(schemas(user))).Scan(pq.Array(&present)); err != nil {
+		return err
+	}
+
+	var queries []string
+	defaultFor := defaultPrivilegesFor(user)
+	for _, schema := range present {
+		inSchema := "IN SCHEMA " + quoteIdentifier(schema)
+		for _, op := range objectPrivileges(user) {
+			for _, priv := range op.privileges {
+				queries = append(queries, fmt.Sprintf("REVOKE %s ON ALL %s %s FROM %s",
+					priv, op.objects, inSchema, quoteIdentifier(user.Spec.Username)))
+				for _, forRole := range defaultFor {
+					queries = append(queries, fmt.Sprintf("ALTER DEFAULT PRIVILEGES%s %s REVOKE %s ON %s FROM %s",
+						forRole, inSchema, priv, op.objects, quoteIdentifier(user.Spec.Username)))
+				}
+			}
+		}
+		queries = append(queries, fmt.Sprintf("REVOKE USAGE ON SCHEMA %s FROM %s",
+			quoteIdentifier(schema), quoteIdentifier(user.Spec.Username)))
+	}
+	queries = append(queries, fmt.Sprintf("REVOKE CONNECT ON DATABASE %s FROM %s",
+		quoteIdentifier(user.Spec.Database), quoteIdentifier(user.Spec.Username)))
+
+	tx, err := targetDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback is a no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	for _, query := range queries {
+		if err := r.exec(ctx, tx, user, user.Spec.Database, query); err != nil {
+			return fmt.Errorf("failed to revoke privileges of %s: %w", user.Spec.Username, err)
+		}
+	}
+	if err := r.commit(tx, user, user.Spec.Database); err != nil {
+		return fmt.Errorf("failed to revoke privileges of %s: %w", user.Spec.Username, err)
+	}
+	log.Info("Revoked privileges", "username", user.Spec.Username, "database", user.Spec.Database)
+	r.recordEvent(user, corev1.EventTypeNormal, "PrivilegesRevoked",
+		fmt.Sprintf("Revoked the privileges of %s in database %s", user.Spec.Username, user.Spec.Database))
+
+	var reason, message string
+	queries = nil
+	switch {
+	case user.Spec.ReassignOwnedTo != "":
+		queries = append(queries, fmt.Sprintf("REASSIGN OWNED BY %s TO %s",
+			quoteIdentifier(user.Spec.Username),
+			quoteIdentifier(user.Spec.ReassignOwnedTo)))
+		reason = "OwnedObjectsReassigned"
+		message = fmt.Sprintf("Reassigned objects owned by %s in database %s to %s",
+			user.Spec.Username, user.Spec.Database, user.Spec.ReassignOwnedTo)
+	case user.Spec.DeletionPolicy == deletionPolicyDropOwned:
+		reason = "OwnedObjectsDropped"
+		message = fmt.Sprintf("Dropped objects owned by %s in database %s", user.Spec.Username, user.Spec.Database)
+	default:
+		return nil
+	}
+	queries = append(queries, fmt.Sprintf("DROP OWNED BY %s", quoteIdentifier(user.Spec.Username)))
+
+	for _, query := range queries {
+		if err := r.exec(ctx, targetDB, user, user.Spec.Database, query); err != nil {
+			return fmt.Errorf("failed to release objects owned by %s: %w", user.Spec.Username, err)
+		}
+	}
+	log.Info("Released owned objects", "username", user.Spec.Username, "database", user.Spec.Database)
+	r.recordEvent(user, corev1.EventTypeNormal, reason, message)
+	return nil
 }
 
 func (r *PostgresUserReconciler) createOrUpdateSecret(ctx context.Context, user *databasev1alpha1.PostgresUser, password string) error {
@@ -280,9 +1017,17 @@ func (r *PostgresUserReconciler) createOrUpdateSecret(ctx context.Context, user
 			secret.Data = make(map[string][]byte)
 		}
 		secret.Data["username"] = []byte(user.Spec.Username)
-		secret.Data["password"] = []byte(password)
+		if iamAuth(user) {
+			// Consumers fetch their own tokens; a stale password would only mislead them
+			secret.Data["authMode"] = []byte(authModeIAM)
+			delete(secret.Data, "password")
+			delete(secret.Data, nextPasswordKey)
+		} else {
+			secret.Data["authMode"] = []byte("password")
+			secret.Data["password"] = []byte(password)
+		}
 		secret.Data["host"] = []byte(user.Spec.Host)
-		secret.Data["port"] = []byte(fmt.Sprintf("%d", user.Spec.Port))
+		secret.Data["port"] = []byte(fmt.Sprintf("%d", appPort(user)))
 		secret.Data["database"] = []byte(user.Spec.Database)
 
 		// Set owner reference
@@ -297,31 +1042,190 @@ func (r *PostgresUserReconciler) updateStatus(ctx context.Context, user *databas
 	user.Status.Message = message
 
 	// Update conditions
+	if ready {
+		setCondition(user, "Ready", metav1.ConditionTrue, "ReconciliationSucceeded", message)
+	} else {
+		setCondition(user, "Ready", metav1.ConditionFalse, "ReconciliationFailed", message)
+	}
+
+	return r.Status().Update(ctx, user)
+}
+
+// setCondition updates or adds a condition to the status
+func setCondition(user *databasev1alpha1.PostgresUser, conditionType string, status metav1.ConditionStatus, reason, message string) {
 	condition := metav1.Condition{
-		Type:               "Ready",
-		Status:             metav1.ConditionFalse,
-		Reason:             "ReconciliationFailed",
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
 		Message:            message,
 		LastTransitionTime: metav1.Now(),
 	}
 
-	if ready {
-		condition.Status = metav1.ConditionTrue
-		condition.Reason = "ReconciliationSucceeded"
+	// Find and update existing condition or append new one
+	for i, c := range user.Status.Conditions {
+		if c.Type == conditionType {
+			user.Status.Conditions[i] = condition
+			return
+		}
 	}
 
-	user.Status.Conditions = []metav1.Condition{condition}
-
-	return r.Status().Update(ctx, user)
+	user.Status.Conditions = append(user.Status.Conditions, condition)
 }
 
 func (r *PostgresUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&databasev1alpha1.PostgresUser{}).
 		Owns(&corev1.Secret{}).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.findUsersForAdminSecret),
+		).
+		Watches(
+			&databasev1alpha1.PostgresServer{},
+			handler.EnqueueRequestsFromMapFunc(r.findUsersForServer),
+		).
 		Complete(r)
 }
 
+// findUsersForAdminSecret maps an admin Secret to the PostgresUsers that
+// connect with it, so rotated admin credentials are picked up right away.
+// Connections are opened per reconcile, so nothing cached outlives the rotation.
+func (r *PostgresUserReconciler) findUsersForAdminSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	users := &databasev1alpha1.PostgresUserList{}
+	if err := r.List(ctx, users, client.InNamespace(secret.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+
+	// Users referencing a PostgresServer connect with the server's admin Secret
+	servers := &databasev1alpha1.PostgresServerList{}
+	if err := r.List(ctx, servers, client.InNamespace(secret.GetNamespace())); err != nil {
+		return []reconcile.Request{}
+	}
+	serversUsingSecret := map[string]bool{}
+	for _, server := range servers.Items {
+		if server.Spec.AdminSecretRef.Name == secret.GetName() {
+			serversUsingSecret[server.Name] = true
+		}
+	}
+
+	var requests []reconcile.Request
+	for _, user := range users.Items {
+		if user.Spec.AdminSecretRef.Name == secret.GetName() ||
+			(user.Spec.ServerRef != nil && serversUsingSecret[user.Spec.ServerRef.Name]) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      user.Name,
+					Namespace: user.Namespace,
+				},
+			})
+		}
+	}
+
+	return requests
+}
+
+// validateSpec rejects field combinations the CRD schema cannot express
+func validateSpec(user *databasev1alpha1.PostgresUser) error {
+	if user.Spec.DualPasswordWindow != nil && user.Spec.DualPasswordWindow.Duration < 0 {
+		return fmt.Errorf("dualPasswordWindow must not be negative")
+	}
+
+	for _, role := range user.Spec.DefaultPrivilegesFor {
+		if role == user.Spec.Username {
+			return fmt.Errorf("defaultPrivilegesFor must not list the user itself")
+		}
+	}
+
+	if user.Spec.Host == "" {
+		return fmt.Errorf("host is required unless serverRef is set")
+	}
+	if user.Spec.AdminSecretRef.Name == "" {
+		return fmt.Errorf("adminSecretRef is required unless serverRef is set")
+	}
+
+	for _, op := range objectPrivileges(user) {
+		for _, priv := range op.grantOption {
+			if !slices.ContainsFunc(op.privileges, func(p string) bool { return strings.EqualFold(p, priv) }) {
+				return fmt.Errorf("grantOption lists %s on %s, which is not granted", priv, strings.ToLower(op.objects))
+			}
+		}
+	}
+
+	if user.Spec.ReassignOwnedTo == user.Spec.Username {
+		return fmt.Errorf("reassignOwnedTo must name a role other than the user itself")
+	}
+
+	if iamAuth(user) {
+		if !loginEnabled(user) {
+			return fmt.Errorf("authMode iam requires login to be enabled")
+		}
+		if user.Spec.RotatePassword || user.Spec.DualPasswordWindow != nil || user.Spec.PasswordEncryption != "" {
+			return fmt.Errorf("rotatePassword, dualPasswordWindow and passwordEncryption cannot be set with authMode iam")
+		}
+		if user.Spec.SSLMode == "" || user.Spec.SSLMode == "disable" {
+			return fmt.Errorf("authMode iam requires an sslMode other than disable")
+		}
+	}
+
+	if !loginEnabled(user) {
+		if user.Spec.SecretName != "" {
+			return fmt.Errorf("secretName must be empty when login is disabled")
+		}
+		if user.Spec.RotatePassword {
+			return fmt.Errorf("rotatePassword cannot be set when login is disabled")
+		}
+		return nil
+	}
+
+	if user.Spec.SecretName == "" {
+		return fmt.Errorf("secretName is required when login is enabled")
+	}
+
+	return nil
+}
+
+// appPort returns the port applications connect to, defaulting to 5432
+func appPort(user *databasev1alpha1.PostgresUser) int32 {
+	if user.Spec.Port == 0 {
+		return 5432
+	}
+	return user.Spec.Port
+}
+
+// adminEndpoint returns the host and port used for role management, which
+// default to the application endpoint when no admin endpoint is set
+func adminEndpoint(user *databasev1alpha1.PostgresUser) (string, int32) {
+	host := user.Spec.AdminHost
+	if host == "" {
+		host = user.Spec.Host
+	}
+	port := user.Spec.AdminPort
+	if port == 0 {
+		port = appPort(user)
+	}
+	return host, port
+}
+
+// checkReachable verifies a TCP connection can be opened to host:port
+func checkReachable(ctx context.Context, host string, port int32) error {
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// iamAuth reports whether the user authenticates with IAM tokens
+func iamAuth(user *databasev1alpha1.PostgresUser) bool {
+	return user.Spec.AuthMode == authModeIAM
+}
+
+// loginEnabled reports whether the role may log in, defaulting to true
+func loginEnabled(user *databasev1alpha1.PostgresUser) bool {
+	return user.Spec.Login == nil || *user.Spec.Login
+}
+
 func generatePassword(length int) string {
 	bytes := make([]byte, length)
 	if _, err := rand.Read(bytes); err != nil {
@@ -333,3 +1237,7 @@ func generatePassword(length int) string {
 func quoteIdentifier(name string) string {
 	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
 }
+
+func quoteLiteral(value string) string {
+	return `'` + strings.ReplaceAll(value, `'`, `''`) + `'`
+}
@@ -0,0 +1,1003 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	databasev1alpha1 "github.com/nutcas3/database-user-manager/api/v1alpha1"
+)
+
+// newTestReconciler returns a PostgresUserReconciler backed by a fake client
+// holding objs
+func newTestReconciler(t *testing.T, objs ...client.Object) *PostgresUserReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := databasev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &PostgresUserReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&databasev1alpha1.PostgresUser{}).
+			Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+// adminSecret returns the admin credentials postgresUser connects with
+func adminSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pg-admin", Namespace: "default"},
+		Data:       map[string][]byte{"username": []byte("postgres"), "password": []byte("admin")},
+	}
+}
+
+// postgresUser returns a PostgresUser for role app in database shop
+func postgresUser(name string) *databasev1alpha1.PostgresUser {
+	return &databasev1alpha1.PostgresUser{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Generation: 1},
+		Spec: databasev1alpha1.PostgresUserSpec{
+			Username:       "app",
+			Database:       "shop",
+			Host:           "db.example.com",
+			AdminSecretRef: corev1.SecretReference{Name: "pg-admin"},
+			Privileges:     []string{"SELECT"},
+			SecretName:     name + "-credentials",
+		},
+	}
+}
+
+// reconcileUser runs one reconciliation of the named user in default and
+// returns the user as stored afterwards
+func reconcileUser(t *testing.T, r *PostgresUserReconciler, name string) (ctrl.Result, *databasev1alpha1.PostgresUser) {
+	t.Helper()
+	ctx := context.Background()
+	key := types.NamespacedName{Name: name, Namespace: "default"}
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	stored := &databasev1alpha1.PostgresUser{}
+	if err := r.Get(ctx, key, stored); err != nil {
+		t.Fatal(err)
+	}
+	return result, stored
+}
+
+// getSecret returns the named Secret in default
+func getSecret(t *testing.T, r *PostgresUserReconciler, name string) *corev1.Secret {
+	t.Helper()
+	secret := &corev1.Secret{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "default"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestReconcileAttributes(t *testing.T) {
+	limit := int32(5)
+	login := false
+	validUntil := metav1.NewTime(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name   string
+		mutate func(*databasev1alpha1.PostgresUser)
+		want   []string
+	}{
+		{
+			name: "defaults",
+			want: []string{
+				`ALTER USER "app" WITH LOGIN CONNECTION LIMIT -1 VALID UNTIL 'infinity'`,
+				`COMMENT ON ROLE "app" IS NULL`,
+			},
+		},
+		{
+			name: "all attributes",
+			mutate: func(user *databasev1alpha1.PostgresUser) {
+				user.Spec.Login = &login
+				user.Spec.ConnectionLimit = &limit
+				user.Spec.ValidUntil = &validUntil
+				user.Spec.Comment = "owned by team's shop"
+			},
+			want: []string{
+				`ALTER USER "app" WITH NOLOGIN CONNECTION LIMIT 5 VALID UNTIL '2027-01-01T00:00:00Z'`,
+				`COMMENT ON ROLE "app" IS 'owned by team''s shop'`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := newFakePostgres(t)
+			user := postgresUser("app")
+			if tt.mutate != nil {
+				tt.mutate(user)
+			}
+			r := newTestReconciler(t)
+
+			if err := r.reconcileAttributes(context.Background(), pg.open(t, "postgres"), user); err != nil {
+				t.Fatal(err)
+			}
+			if got := pg.executed("postgres"); !slices.Equal(got, tt.want) {
+				t.Errorf("executed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangingConnectionLimitKeepsPassword(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.roles["app"] = true
+	pg.databases["shop"] = true
+
+	limit := int32(5)
+	user := postgresUser("app")
+	user.Spec.ConnectionLimit = &limit
+	user.Status.RoleManaged = true
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-credentials", Namespace: "default"},
+		Data:       map[string][]byte{"username": []byte("app"), "password": []byte("unchanged")},
+	}
+	r := newTestReconciler(t, adminSecret(), credentials, user)
+
+	_, stored := reconcileUser(t, r, "app")
+
+	if !stored.Status.Ready {
+		t.Fatalf("user not ready: %s", stored.Status.Message)
+	}
+	executed := pg.executed("postgres")
+	if !slices.Contains(executed, `ALTER USER "app" WITH LOGIN CONNECTION LIMIT 5 VALID UNTIL 'infinity'`) {
+		t.Errorf("connection limit was not applied, executed %q", executed)
+	}
+	for _, query := range executed {
+		if strings.Contains(query, "PASSWORD") {
+			t.Errorf("password was changed: %q", redactPasswords(query))
+		}
+	}
+	if password := string(getSecret(t, r, "app-credentials").Data["password"]); password != "unchanged" {
+		t.Errorf("Secret password = %q, want unchanged", password)
+	}
+}
+
+func TestGrantPrivilegesCoversSequencesAndFunctions(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.createObject("postgres", "SEQUENCES", "public", "orders_id_seq")
+	pg.createObject("postgres", "FUNCTIONS", "public", "order_total")
+	user := postgresUser("app")
+	user.Spec.Privileges = nil
+	user.Spec.SequencePrivileges = []string{"USAGE", "SELECT"}
+	user.Spec.FunctionPrivileges = []string{"EXECUTE"}
+	r := newTestReconciler(t, adminSecret())
+	ctx := context.Background()
+
+	if err := r.grantPrivileges(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"BEGIN",
+		`GRANT CONNECT ON DATABASE "shop" TO "app"`,
+		`GRANT USAGE ON SCHEMA "public" TO "app"`,
+		`GRANT USAGE ON ALL SEQUENCES IN SCHEMA "public" TO "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" GRANT USAGE ON SEQUENCES TO "app"`,
+		`REVOKE GRANT OPTION FOR USAGE ON ALL SEQUENCES IN SCHEMA "public" FROM "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" REVOKE GRANT OPTION FOR USAGE ON SEQUENCES FROM "app"`,
+		`GRANT SELECT ON ALL SEQUENCES IN SCHEMA "public" TO "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" GRANT SELECT ON SEQUENCES TO "app"`,
+		`REVOKE GRANT OPTION FOR SELECT ON ALL SEQUENCES IN SCHEMA "public" FROM "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" REVOKE GRANT OPTION FOR SELECT ON SEQUENCES FROM "app"`,
+		`GRANT EXECUTE ON ALL FUNCTIONS IN SCHEMA "public" TO "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" GRANT EXECUTE ON FUNCTIONS TO "app"`,
+		`REVOKE GRANT OPTION FOR EXECUTE ON ALL FUNCTIONS IN SCHEMA "public" FROM "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" REVOKE GRANT OPTION FOR EXECUTE ON FUNCTIONS FROM "app"`,
+		"COMMIT",
+	}
+	if got := pg.executed("shop"); !slices.Equal(got, want) {
+		t.Errorf("executed\n%q\nwant\n%q", got, want)
+	}
+
+	// Existing objects and those created afterwards are both covered
+	pg.createObject("postgres", "SEQUENCES", "public", "invoices_id_seq")
+	pg.createObject("postgres", "FUNCTIONS", "public", "invoice_total")
+	drift, err := r.detectObjectPrivilegeDrift(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("detectObjectPrivilegeDrift() = %q, want no drift", drift)
+	}
+	for _, object := range []string{"orders_id_seq", "invoices_id_seq"} {
+		if !pg.hasPrivilege("app", "public", object, "USAGE") || !pg.hasPrivilege("app", "public", object, "SELECT") {
+			t.Errorf("app lacks USAGE or SELECT on sequence %s", object)
+		}
+	}
+}
+
+func TestGrantPrivilegesRollsBackOnFailure(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.createObject("postgres", "SEQUENCES", "public", "orders_id_seq")
+	pg.createObject("postgres", "FUNCTIONS", "public", "order_total")
+	pg.failures["GRANT EXECUTE"] = errors.New("permission denied for function order_total")
+	user := postgresUser("app")
+	user.Spec.Privileges = nil
+	user.Spec.SequencePrivileges = []string{"USAGE"}
+	user.Spec.FunctionPrivileges = []string{"EXECUTE"}
+	r := newTestReconciler(t, adminSecret())
+	ctx := context.Background()
+
+	if err := r.grantPrivileges(ctx, user); err == nil {
+		t.Fatal("grantPrivileges() succeeded, want the EXECUTE grant's error")
+	}
+
+	executed := pg.executed("shop")
+	if executed[0] != "BEGIN" || executed[len(executed)-2] != `GRANT EXECUTE ON ALL FUNCTIONS IN SCHEMA "public" TO "app"` ||
+		executed[len(executed)-1] != "ROLLBACK" || slices.Contains(executed, "COMMIT") {
+		t.Errorf("executed %q, want the grants rolled back after the failing one", executed)
+	}
+
+	// The sequence grant before the failure was rolled back with it
+	if pg.hasPrivilege("app", "public", "orders_id_seq", "USAGE") {
+		t.Error("app holds USAGE on orders_id_seq after the rollback")
+	}
+	drift, err := r.detectObjectPrivilegeDrift(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"missing USAGE on schema public",
+		"missing USAGE on 1 sequences in schema public",
+		"missing EXECUTE on 1 functions in schema public",
+	}
+	if !slices.Equal(drift, want) {
+		t.Errorf("detectObjectPrivilegeDrift() = %q, want %q", drift, want)
+	}
+}
+
+func TestDeletingUserOwningObjects(t *testing.T) {
+	tests := []struct {
+		name            string
+		reassignOwnedTo string
+		wantDropped     bool
+	}{
+		{name: "reassigned", reassignOwnedTo: "shop_owner", wantDropped: true},
+		{name: "no reassignment target"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pg := newFakePostgres(t)
+			pg.databases["shop"] = true
+			pg.roles["app"] = true
+			pg.owners["app"] = true
+
+			now := metav1.Now()
+			user := postgresUser("app")
+			user.Spec.ReassignOwnedTo = tt.reassignOwnedTo
+			user.Status.RoleManaged = true
+			user.Finalizers = []string{finalizerName}
+			user.DeletionTimestamp = &now
+			r := newTestReconciler(t, adminSecret(), user)
+
+			key := types.NamespacedName{Name: "app", Namespace: "default"}
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+
+			if dropped := !pg.roles["app"]; dropped != tt.wantDropped {
+				t.Fatalf("role dropped = %v, want %v (err %v)", dropped, tt.wantDropped, err)
+			}
+			if !tt.wantDropped {
+				if err == nil {
+					t.Error("Reconcile() succeeded, want an error naming the owned objects")
+				}
+				stored := &databasev1alpha1.PostgresUser{}
+				if err := r.Get(ctx, key, stored); err != nil {
+					t.Fatal(err)
+				}
+				condition := meta.FindStatusCondition(stored.Status.Conditions, "DropBlocked")
+				if condition == nil || condition.Reason != "DependentObjects" {
+					t.Errorf("DropBlocked condition = %+v, want reason DependentObjects", condition)
+				}
+				if !slices.Contains(stored.Finalizers, finalizerName) {
+					t.Error("finalizer was removed while the role still exists")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			executed := pg.executed("shop")
+			for _, want := range []string{`REASSIGN OWNED BY "app" TO "shop_owner"`, `DROP OWNED BY "app"`} {
+				if !slices.Contains(executed, want) {
+					t.Errorf("missing %q in %q", want, executed)
+				}
+			}
+			if err := r.Get(ctx, key, &databasev1alpha1.PostgresUser{}); !apierrors.IsNotFound(err) {
+				t.Errorf("user still exists after its role was dropped: %v", err)
+			}
+		})
+	}
+}
+
+func TestGrantPrivilegesWithGrantOption(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.createObject("postgres", "TABLES", "public", "orders")
+	user := postgresUser("app")
+	user.Spec.Privileges = []string{"SELECT", "INSERT"}
+	user.Spec.DefaultPrivilegesFor = []string{"migrator"}
+	user.Spec.GrantOption = &databasev1alpha1.GrantOptionSpec{Tables: []string{"select", "insert"}}
+	r := newTestReconciler(t, adminSecret())
+	ctx := context.Background()
+
+	if err := r.grantPrivileges(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	pg.createObject("migrator", "TABLES", "public", "invoices")
+	for _, table := range []string{"orders", "invoices"} {
+		for _, privilege := range []string{"SELECT WITH GRANT OPTION", "INSERT WITH GRANT OPTION"} {
+			if !pg.hasPrivilege("app", "public", table, privilege) {
+				t.Errorf("app lacks %s on %s", privilege, table)
+			}
+		}
+	}
+
+	// Dropping INSERT from the grant option revokes it on existing tables,
+	// and on those created afterwards, while keeping INSERT itself
+	user.Spec.GrantOption.Tables = []string{"select"}
+	if err := r.grantPrivileges(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	pg.createObject("migrator", "TABLES", "public", "refunds")
+
+	tests := []struct {
+		privilege string
+		want      bool
+	}{
+		{"SELECT WITH GRANT OPTION", true},
+		{"INSERT", true},
+		{"INSERT WITH GRANT OPTION", false},
+	}
+	for _, table := range []string{"orders", "invoices", "refunds"} {
+		for _, tt := range tests {
+			if got := pg.hasPrivilege("app", "public", table, tt.privilege); got != tt.want {
+				t.Errorf("has_table_privilege(app, %s, %s) = %v, want %v", table, tt.privilege, got, tt.want)
+			}
+		}
+	}
+	drift, err := r.detectObjectPrivilegeDrift(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("detectObjectPrivilegeDrift() = %q, want no drift", drift)
+	}
+
+	// The grant option is revoked after the grants, in the same transaction
+	executed := pg.executed("shop")
+	second := executed[slices.Index(executed[1:], "BEGIN")+1:]
+	grant := slices.Index(second, `GRANT INSERT ON ALL TABLES IN SCHEMA "public" TO "app"`)
+	revoke := slices.Index(second, `REVOKE GRANT OPTION FOR INSERT ON ALL TABLES IN SCHEMA "public" FROM "app"`)
+	if grant < 0 || revoke < grant || second[len(second)-1] != "COMMIT" {
+		t.Errorf("second pass executed %q, want the INSERT grant option revoked after the grant before COMMIT", second)
+	}
+	if slices.Contains(second, `REVOKE GRANT OPTION FOR SELECT ON ALL TABLES IN SCHEMA "public" FROM "app"`) {
+		t.Error("revoked the SELECT grant option that is still wanted")
+	}
+}
+
+func TestValidateSpecGrantOption(t *testing.T) {
+	user := postgresUser("app")
+	user.Spec.GrantOption = &databasev1alpha1.GrantOptionSpec{Sequences: []string{"USAGE"}}
+	if err := validateSpec(user); err == nil {
+		t.Error("validateSpec() accepted a grant option on a privilege that is not granted")
+	}
+
+	user.Spec.SequencePrivileges = []string{"usage"}
+	if err := validateSpec(user); err != nil {
+		t.Errorf("validateSpec() error = %v", err)
+	}
+}
+
+func TestFindUsersForAdminSecret(t *testing.T) {
+	direct := postgresUser("direct")
+	viaServer := postgresUser("via-server")
+	viaServer.Spec.Host = ""
+	viaServer.Spec.AdminSecretRef = corev1.SecretReference{}
+	viaServer.Spec.ServerRef = &corev1.LocalObjectReference{Name: "main"}
+	other := postgresUser("other")
+	other.Spec.AdminSecretRef.Name = "other-admin"
+	elsewhere := postgresUser("elsewhere")
+	elsewhere.Namespace = "team-a"
+	server := &databasev1alpha1.PostgresServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "main", Namespace: "default"},
+		Spec: databasev1alpha1.PostgresServerSpec{
+			Host:           "db.example.com",
+			AdminSecretRef: corev1.SecretReference{Name: "pg-admin"},
+		},
+	}
+	r := newTestReconciler(t, direct, viaServer, other, elsewhere, server)
+
+	var got []string
+	for _, request := range r.findUsersForAdminSecret(context.Background(), adminSecret()) {
+		got = append(got, request.String())
+	}
+	slices.Sort(got)
+	if want := []string{"default/direct", "default/via-server"}; !slices.Equal(got, want) {
+		t.Errorf("findUsersForAdminSecret() = %v, want %v", got, want)
+	}
+}
+
+func TestReconcileAfterAdminSecretRotation(t *testing.T) {
+	ctx := context.Background()
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.adminPassword = "admin"
+	secret := adminSecret()
+	r := newTestReconciler(t, secret, postgresUser("app"))
+
+	reconcileUser(t, r, "app")
+
+	// The password is rotated on the server and then in the Secret
+	pg.adminPassword = "rotated"
+	secret.Data["password"] = []byte("rotated")
+	if err := r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stored := reconcileUser(t, r, "app")
+	if !stored.Status.Ready {
+		t.Errorf("user not ready after the rotation: %s", stored.Status.Message)
+	}
+}
+
+func TestDefaultPrivilegesFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		creators []string
+		want     []string
+	}{
+		{name: "admin only", want: []string{""}},
+		{
+			name:     "creator roles",
+			creators: []string{"migrator", `odd"name`},
+			want:     []string{"", ` FOR ROLE "migrator"`, ` FOR ROLE "odd""name"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := postgresUser("app")
+			user.Spec.DefaultPrivilegesFor = tt.creators
+			if got := defaultPrivilegesFor(user); !slices.Equal(got, tt.want) {
+				t.Errorf("defaultPrivilegesFor() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrantPrivilegesCoversObjectsOfCreatorRoles(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.schemas["billing"] = true
+	user := postgresUser("app")
+	user.Spec.Schemas = []string{"public", "billing"}
+	user.Spec.DefaultPrivilegesFor = []string{"migrator"}
+	r := newTestReconciler(t, adminSecret())
+
+	if err := r.grantPrivileges(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tables the admin or the migrator create later are readable by the user
+	// in every schema, but not those of a creator role that isn't listed
+	for _, schema := range []string{"public", "billing"} {
+		pg.createObject("postgres", "TABLES", schema, "by_admin")
+		pg.createObject("migrator", "TABLES", schema, "by_migrator")
+		pg.createObject("analyst", "TABLES", schema, "by_analyst")
+	}
+	for _, schema := range []string{"public", "billing"} {
+		for table, want := range map[string]bool{"by_admin": true, "by_migrator": true, "by_analyst": false} {
+			if got := pg.hasPrivilege("app", schema, table, "SELECT"); got != want {
+				t.Errorf("has_table_privilege(app, %s.%s, SELECT) = %v, want %v", schema, table, got, want)
+			}
+		}
+	}
+	drift, err := r.detectObjectPrivilegeDrift(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"missing SELECT on 2 tables in schemas public, billing"}; !slices.Equal(drift, want) {
+		t.Errorf("detectObjectPrivilegeDrift() = %q, want %q", drift, want)
+	}
+
+	user.Spec.DefaultPrivilegesFor = []string{"app"}
+	if err := validateSpec(user); err == nil {
+		t.Error("validateSpec() accepted the user as its own creator role")
+	}
+}
+
+func TestReconcileSetsRoleComment(t *testing.T) {
+	ctx := context.Background()
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	user := postgresUser("app")
+	user.Spec.Comment = "source: 10.0.0.0/8 (payments)"
+	r := newTestReconciler(t, adminSecret(), user)
+
+	_, stored := reconcileUser(t, r, "app")
+	if want := `COMMENT ON ROLE "app" IS 'source: 10.0.0.0/8 (payments)'`; !slices.Contains(pg.executed("postgres"), want) {
+		t.Errorf("missing %q", want)
+	}
+
+	// Removing the comment from the spec clears it on the role
+	stored.Spec.Comment = ""
+	if err := r.Update(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	reconcileUser(t, r, "app")
+	executed := pg.executed("postgres")
+	if last := executed[len(executed)-1]; last != `COMMENT ON ROLE "app" IS NULL` {
+		t.Errorf("last statement = %q, want the comment cleared", last)
+	}
+}
+
+func TestMissingDatabase(t *testing.T) {
+	pg := newFakePostgres(t)
+	r := newTestReconciler(t, adminSecret(), postgresUser("app"))
+
+	result, stored := reconcileUser(t, r, "app")
+
+	if result.RequeueAfter != missingDatabaseRetry {
+		t.Errorf("RequeueAfter = %v, want %v", result.RequeueAfter, missingDatabaseRetry)
+	}
+	condition := meta.FindStatusCondition(stored.Status.Conditions, "DatabaseExists")
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != "DatabaseNotFound" ||
+		!strings.Contains(condition.Message, "shop") {
+		t.Errorf("DatabaseExists condition = %+v, want False naming shop", condition)
+	}
+	if stored.Status.Ready {
+		t.Error("user is ready without its database")
+	}
+	if pg.roles["app"] {
+		t.Error("created the role without the database")
+	}
+	for _, query := range pg.executed("postgres") {
+		if strings.HasPrefix(query, "GRANT") {
+			t.Errorf("granted %q without the database", query)
+		}
+	}
+
+	// The database is created outside the cluster and picked up on the retry
+	pg.databases["shop"] = true
+	_, stored = reconcileUser(t, r, "app")
+	if !meta.IsStatusConditionTrue(stored.Status.Conditions, "DatabaseExists") || !stored.Status.Ready {
+		t.Errorf("user not ready once the database exists: %s", stored.Status.Message)
+	}
+
+	// The Secret holds the password the role logs in with
+	password := string(getSecret(t, r, "app-credentials").Data["password"])
+	if password == "" || password != pg.passwords["app"] {
+		t.Errorf("Secret password %q, role password %q", password, pg.passwords["app"])
+	}
+	if err := r.validateCredentials(context.Background(), stored, password); err != nil {
+		t.Errorf("can't log in with the Secret's password: %v", err)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	tests := []struct {
+		name    string
+		schemas []string
+		want    []string
+	}{
+		{name: "default", want: []string{"public"}},
+		{name: "listed", schemas: []string{"billing", "public"}, want: []string{"billing", "public"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := postgresUser("app")
+			user.Spec.Schemas = tt.schemas
+			if got := schemas(user); !slices.Equal(got, tt.want) {
+				t.Errorf("schemas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrantPrivilegesAcrossSchemas(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.schemas["billing"] = true
+	pg.createObject("postgres", "TABLES", "public", "orders")
+	pg.createObject("postgres", "TABLES", "billing", "invoices")
+	user := postgresUser("app")
+	user.Spec.Schemas = []string{"public", "billing"}
+	r := newTestReconciler(t, adminSecret())
+	ctx := context.Background()
+
+	drift, err := r.detectObjectPrivilegeDrift(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"missing USAGE on schema public",
+		"missing USAGE on schema billing",
+		"missing SELECT on 2 tables in schemas public, billing",
+	}
+	if !slices.Equal(drift, want) {
+		t.Errorf("detectObjectPrivilegeDrift() before granting = %q, want %q", drift, want)
+	}
+
+	if err := r.grantPrivileges(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	want = []string{
+		"BEGIN",
+		`GRANT CONNECT ON DATABASE "shop" TO "app"`,
+		`GRANT USAGE ON SCHEMA "public" TO "app"`,
+		`GRANT USAGE ON SCHEMA "billing" TO "app"`,
+	}
+	for _, schema := range []string{`"public"`, `"billing"`} {
+		want = append(want,
+			`GRANT SELECT ON ALL TABLES IN SCHEMA `+schema+` TO "app"`,
+			`ALTER DEFAULT PRIVILEGES IN SCHEMA `+schema+` GRANT SELECT ON TABLES TO "app"`,
+			`REVOKE GRANT OPTION FOR SELECT ON ALL TABLES IN SCHEMA `+schema+` FROM "app"`,
+			`ALTER DEFAULT PRIVILEGES IN SCHEMA `+schema+` REVOKE GRANT OPTION FOR SELECT ON TABLES FROM "app"`,
+		)
+	}
+	want = append(want, "COMMIT")
+	if got := pg.executed("shop"); !slices.Equal(got, want) {
+		t.Errorf("executed\n%q\nwant\n%q", got, want)
+	}
+
+	drift, err = r.detectObjectPrivilegeDrift(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drift) != 0 {
+		t.Errorf("detectObjectPrivilegeDrift() = %q, want no drift", drift)
+	}
+}
+
+func TestGrantPrivilegesAcrossSchemasRollsBack(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	pg.schemas["billing"] = true
+	pg.createObject("postgres", "TABLES", "public", "orders")
+	pg.createObject("postgres", "TABLES", "billing", "invoices")
+	pg.failures[`GRANT SELECT ON ALL TABLES IN SCHEMA "billing"`] = errors.New("permission denied for table invoices")
+	user := postgresUser("app")
+	user.Spec.Schemas = []string{"public", "billing"}
+	r := newTestReconciler(t, adminSecret())
+
+	if err := r.grantPrivileges(context.Background(), user); err == nil {
+		t.Fatal("grantPrivileges() succeeded, want the billing grant's error")
+	}
+
+	// Nothing granted in public survives the failure in billing
+	executed := pg.executed("shop")
+	if executed[0] != "BEGIN" || executed[len(executed)-1] != "ROLLBACK" || slices.Contains(executed, "COMMIT") {
+		t.Errorf("executed %q, want one transaction rolled back", executed)
+	}
+	if pg.hasPrivilege("app", "public", "orders", "SELECT") {
+		t.Error("app holds SELECT on public.orders after the rollback")
+	}
+	drift, err := r.detectObjectPrivilegeDrift(context.Background(), user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(drift, "missing USAGE on schema public") {
+		t.Errorf("detectObjectPrivilegeDrift() = %q, want USAGE on public missing", drift)
+	}
+}
+
+func TestGrantPrivilegesMissingSchema(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	user := postgresUser("app")
+	user.Spec.Schemas = []string{"public", "billing", "reporting"}
+	r := newTestReconciler(t, adminSecret())
+
+	err := r.grantPrivileges(context.Background(), user)
+	if !errors.Is(err, errSchemaNotFound) || !strings.Contains(err.Error(), "billing, reporting") {
+		t.Errorf("grantPrivileges() error = %v, want errSchemaNotFound naming billing and reporting", err)
+	}
+	if executed := pg.executed("shop"); len(executed) != 0 {
+		t.Errorf("executed %q before finding the missing schemas", executed)
+	}
+}
+
+func TestReconcileAfterMissingSchema(t *testing.T) {
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	user := postgresUser("app")
+	user.Spec.Schemas = []string{"billing"}
+	r := newTestReconciler(t, adminSecret(), user)
+
+	result, stored := reconcileUser(t, r, "app")
+	if result.RequeueAfter != missingDatabaseRetry || stored.Status.Ready {
+		t.Fatalf("result %+v, ready %v, want a retry while billing is missing", result, stored.Status.Ready)
+	}
+
+	// The role was created before the schema check, but its Secret is written
+	// once the schema appears
+	pg.schemas["billing"] = true
+	_, stored = reconcileUser(t, r, "app")
+	if !stored.Status.Ready {
+		t.Fatalf("user not ready once the schema exists: %s", stored.Status.Message)
+	}
+	password := string(getSecret(t, r, "app-credentials").Data["password"])
+	if password == "" || password != pg.passwords["app"] {
+		t.Errorf("Secret password %q, role password %q", password, pg.passwords["app"])
+	}
+}
+
+func TestExistingRoleAdoption(t *testing.T) {
+	tests := []struct {
+		name      string
+		adopt     bool
+		wantEvent string
+	}{
+		{name: "conflict without adoption", wantEvent: "Warning RoleConflict "},
+		{name: "adopted", adopt: true, wantEvent: "Normal RoleAdopted "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := newFakePostgres(t)
+			pg.databases["shop"] = true
+			pg.roles["app"] = true
+			user := postgresUser("app")
+			user.Spec.AdoptExistingRole = tt.adopt
+			r := newTestReconciler(t, adminSecret(), user)
+
+			_, stored := reconcileUser(t, r, "app")
+
+			var events []string
+			for len(r.Recorder.(*record.FakeRecorder).Events) > 0 {
+				events = append(events, <-r.Recorder.(*record.FakeRecorder).Events)
+			}
+			if !slices.ContainsFunc(events, func(e string) bool { return strings.HasPrefix(e, tt.wantEvent) }) {
+				t.Errorf("events = %q, want one starting with %q", events, tt.wantEvent)
+			}
+			resetPassword := slices.ContainsFunc(pg.executed("postgres"), func(q string) bool {
+				return strings.HasPrefix(q, `ALTER USER "app" WITH PASSWORD `)
+			})
+			credentials := &corev1.Secret{}
+			secretErr := r.Get(context.Background(), types.NamespacedName{Name: "app-credentials", Namespace: "default"}, credentials)
+
+			if !tt.adopt {
+				condition := meta.FindStatusCondition(stored.Status.Conditions, "Conflict")
+				if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "RoleNotManaged" {
+					t.Errorf("Conflict condition = %+v, want True with reason RoleNotManaged", condition)
+				}
+				if stored.Status.Ready || stored.Status.RoleManaged {
+					t.Error("unmanaged role was taken over")
+				}
+				if resetPassword {
+					t.Error("password of the unmanaged role was reset")
+				}
+				if !apierrors.IsNotFound(secretErr) {
+					t.Errorf("credentials Secret written for an unmanaged role: %v", secretErr)
+				}
+				return
+			}
+
+			if !stored.Status.Ready || !stored.Status.RoleManaged {
+				t.Errorf("adopted role not ready and managed: %s", stored.Status.Message)
+			}
+			if meta.IsStatusConditionTrue(stored.Status.Conditions, "Conflict") {
+				t.Error("Conflict condition still set after adoption")
+			}
+			// The Secret only works with the role once its password is set
+			if !resetPassword {
+				t.Error("password of the adopted role was not set")
+			}
+			if secretErr != nil || len(credentials.Data["password"]) == 0 {
+				t.Errorf("credentials Secret = %v, %v, want a password", credentials.Data, secretErr)
+			}
+		})
+	}
+}
+
+func TestSecretWriteFailureAfterCreatingRole(t *testing.T) {
+	ctx := context.Background()
+	pg := newFakePostgres(t)
+	pg.databases["shop"] = true
+	r := newTestReconciler(t, adminSecret(), postgresUser("app"))
+	failed := false
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.Secret); ok && !failed {
+				failed = true
+				return apierrors.NewServiceUnavailable("etcd is unavailable")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+
+	key := types.NamespacedName{Name: "app", Namespace: "default"}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err == nil {
+		t.Fatal("Reconcile() succeeded, want the Secret write error")
+	}
+	stored := &databasev1alpha1.PostgresUser{}
+	if err := r.Get(ctx, key, stored); err != nil {
+		t.Fatal(err)
+	}
+	if !stored.Status.RoleManaged || stored.Status.LastPasswordRotation == nil {
+		t.Errorf("status after the failed write = %+v, want the created role recorded as managed", stored.Status)
+	}
+
+	// The retry writes the Secret instead of reporting a conflict
+	_, stored = reconcileUser(t, r, "app")
+	if !stored.Status.Ready || meta.IsStatusConditionTrue(stored.Status.Conditions, "Conflict") {
+		t.Errorf("user not ready after the retry: %s", stored.Status.Message)
+	}
+	password := string(getSecret(t, r, "app-credentials").Data["password"])
+	if password == "" || password != pg.passwords["app"] {
+		t.Errorf("Secret password %q, role password %q", password, pg.passwords["app"])
+	}
+}
+
+func TestObjectPrivileges(t *testing.T) {
+	user := postgresUser("app")
+	user.Spec.Privileges = []string{"SELECT", "INSERT"}
+	user.Spec.FunctionPrivileges = []string{"EXECUTE"}
+	user.Spec.GrantOption = &databasev1alpha1.GrantOptionSpec{Tables: []string{"INSERT"}}
+
+	got := objectPrivileges(user)
+	want := []objectPrivilege{
+		{"TABLES", []string{"SELECT", "INSERT"}, []string{"INSERT"}},
+		{"SEQUENCES", nil, nil},
+		{"FUNCTIONS", []string{"EXECUTE"}, nil},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("objectPrivileges() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].objects != want[i].objects || !slices.Equal(got[i].privileges, want[i].privileges) ||
+			!slices.Equal(got[i].grantOption, want[i].grantOption) {
+			t.Errorf("objectPrivileges()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if !got[0].withGrantOption("insert") || got[0].withGrantOption("SELECT") {
+		t.Error("withGrantOption() does not match the grant option list case-insensitively")
+	}
+}
+
+func TestReleaseObjects(t *testing.T) {
+	revokes := []string{
+		"BEGIN",
+		`REVOKE SELECT ON ALL TABLES IN SCHEMA "public" FROM "app"`,
+		`ALTER DEFAULT PRIVILEGES IN SCHEMA "public" REVOKE SELECT ON TABLES FROM "app"`,
+		`ALTER DEFAULT PRIVILEGES FOR ROLE "migrator" IN SCHEMA "public" REVOKE SELECT ON TABLES FROM "app"`,
+		`REVOKE USAGE ON SCHEMA "public" FROM "app"`,
+		`REVOKE CONNECT ON DATABASE "shop" FROM "app"`,
+		"COMMIT",
+	}
+	tests := []struct {
+		name            string
+		deletionPolicy  string
+		reassignOwnedTo string
+		want            []string
+	}{
+		{name: "default policy", want: revokes},
+		{
+			name:           "drop owned",
+			deletionPolicy: deletionPolicyDropOwned,
+			want:           append(slices.Clone(revokes), `DROP OWNED BY "app"`),
+		},
+		{
+			name:            "reassign owned",
+			reassignOwnedTo: "shop_owner",
+			want:            append(slices.Clone(revokes), `REASSIGN OWNED BY "app" TO "shop_owner"`, `DROP OWNED BY "app"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := newFakePostgres(t)
+			pg.databases["shop"] = true
+			user := postgresUser("app")
+			// The billing schema was dropped after the grants, so it is skipped
+			user.Spec.Schemas = []string{"public", "billing"}
+			user.Spec.DefaultPrivilegesFor = []string{"migrator"}
+			user.Spec.DeletionPolicy = tt.deletionPolicy
+			user.Spec.ReassignOwnedTo = tt.reassignOwnedTo
+			r := newTestReconciler(t, adminSecret())
+
+			if err := r.releaseObjects(context.Background(), user); err != nil {
+				t.Fatal(err)
+			}
+			if got := pg.executed("shop"); !slices.Equal(got, tt.want) {
+				t.Errorf("executed\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeletingUserWithGrants(t *testing.T) {
+	tests := []struct {
+		name       string
+		failRevoke bool
+	}{
+		{name: "completes cleanly"},
+		{name: "revoke fails", failRevoke: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pg := newFakePostgres(t)
+			pg.databases["shop"] = true
+			pg.roles["app"] = true
+			if tt.failRevoke {
+				pg.failures["REVOKE SELECT"] = errors.New("permission denied for schema public")
+			}
+
+			now := metav1.Now()
+			user := postgresUser("app")
+			user.Status.RoleManaged = true
+			user.Finalizers = []string{finalizerName}
+			user.DeletionTimestamp = &now
+			r := newTestReconciler(t, adminSecret(), user)
+
+			key := types.NamespacedName{Name: "app", Namespace: "default"}
+			_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+
+			if !tt.failRevoke {
+				if err != nil {
+					t.Fatalf("Reconcile() error = %v", err)
+				}
+				if pg.roles["app"] {
+					t.Error("role was not dropped")
+				}
+				if executed := pg.executed("shop"); !slices.Contains(executed, `REVOKE CONNECT ON DATABASE "shop" FROM "app"`) {
+					t.Errorf("privileges were not revoked before the drop: %q", executed)
+				}
+				if err := r.Get(ctx, key, &databasev1alpha1.PostgresUser{}); !apierrors.IsNotFound(err) {
+					t.Errorf("user still exists after its role was dropped: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, errReleaseFailed) {
+				t.Errorf("Reconcile() error = %v, want errReleaseFailed", err)
+			}
+			if !pg.roles["app"] || slices.Contains(pg.executed("postgres"), `DROP USER IF EXISTS "app"`) {
+				t.Error("role was dropped although its privileges were not released")
+			}
+			stored := &databasev1alpha1.PostgresUser{}
+			if err := r.Get(ctx, key, stored); err != nil {
+				t.Fatal(err)
+			}
+			condition := meta.FindStatusCondition(stored.Status.Conditions, "DropBlocked")
+			if condition == nil || condition.Reason != "ReleaseFailed" {
+				t.Errorf("DropBlocked condition = %+v, want reason ReleaseFailed", condition)
+			}
+			if executed := pg.executed("shop"); executed[len(executed)-1] != "ROLLBACK" {
+				t.Errorf("failed revokes were not rolled back: %q", executed)
+			}
+		})
+	}
+}
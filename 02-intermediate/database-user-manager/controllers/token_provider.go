@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TokenProvider issues short-lived authentication tokens for users with
+// authMode iam, such as RDS IAM or Cloud SQL IAM database tokens
+type TokenProvider interface {
+	// Token returns a token that authenticates username against host:port
+	Token(ctx context.Context, host string, port int32, username string) (string, error)
+}
+
+// CommandTokenProvider runs an external command and uses its trimmed stdout
+// as the token. $PGHOST, $PGPORT and $PGUSER are expanded in the arguments
+// and also set in the command's environment, for example:
+//
+//	aws rds generate-db-auth-token --hostname $PGHOST --port $PGPORT --username $PGUSER
+type CommandTokenProvider struct {
+	Command []string
+}
+
+func (p *CommandTokenProvider) Token(ctx context.Context, host string, port int32, username string) (string, error) {
+	if len(p.Command) == 0 {
+		return "", fmt.Errorf("no token command configured")
+	}
+
+	vars := map[string]string{
+		"PGHOST": host,
+		"PGPORT": strconv.Itoa(int(port)),
+		"PGUSER": username,
+	}
+	expand := func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	}
+	args := make([]string, len(p.Command))
+	for i, arg := range p.Command {
+		args[i] = os.Expand(arg, expand)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	for name, value := range vars {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("token command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("token command printed no token")
+	}
+	return token, nil
+}
@@ -3,6 +3,7 @@ module github.com/nutcas3/database-user-manager
 go 1.26
 
 require (
+	github.com/go-logr/logr v1.4.3
 	github.com/lib/pq v1.10.9
 	k8s.io/api v0.35.1
 	k8s.io/apimachinery v0.35.1
@@ -18,7 +19,6 @@ require (
 	github.com/evanphx/json-patch/v5 v5.9.0 // indirect
 	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/fxamacker/cbor/v2 v2.9.0 // indirect
-	github.com/go-logr/logr v1.4.3 // indirect
 	github.com/go-logr/zapr v1.3.0 // indirect
 	github.com/go-openapi/jsonpointer v0.21.0 // indirect
 	github.com/go-openapi/jsonreference v0.20.2 // indirect
@@ -29,10 +29,24 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var iamTokenCommand []string
+	var observeOnly bool
+	var auditLogPath string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election for controller manager.")
+	flag.BoolVar(&observeOnly, "observe-only", false,
+		"Report whether users and privileges match the database without making any changes.")
+	flag.Func("iam-token-command",
+		"Command printing an IAM database token for PostgresUsers with authMode iam, one argument per use of the flag. "+
+			"$PGHOST, $PGPORT and $PGUSER are expanded.",
+		func(arg string) error {
+			iamTokenCommand = append(iamTokenCommand, arg)
+			return nil
+		})
+	flag.StringVar(&auditLogPath, "audit-log-path", "",
+		"File the audit log of executed SQL statements is appended to as JSON lines. Defaults to the operator log.")
 
 	opts := zap.Options{
 		Development: true,
@@ -42,6 +56,27 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	// The audit log goes to its own file when one is given so it can be
+	// shipped and retained apart from the operator's debug output
+	auditLog := ctrl.Log.WithName("audit")
+	var auditFile *os.File
+	if auditLogPath != "" {
+		var err error
+		auditFile, err = os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit log", "path", auditLogPath)
+			os.Exit(1)
+		}
+		auditLog = zap.New(zap.WriteTo(auditFile)).WithName("audit")
+	}
+
+	// os.Exit skips deferred calls, so every exit from here on goes through
+	// exit to flush the audit log to disk first
+	exit := func(code int) {
+		closeAuditFile(auditFile)
+		os.Exit(code)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		HealthProbeBindAddress: probeAddr,
@@ -50,29 +85,52 @@ func main() {
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+		exit(1)
+	}
+
+	var tokenProvider controllers.TokenProvider
+	if len(iamTokenCommand) > 0 {
+		tokenProvider = &controllers.CommandTokenProvider{Command: iamTokenCommand}
 	}
 
 	if err = (&controllers.PostgresUserReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Scheme:        mgr.GetScheme(),
+		Recorder:      mgr.GetEventRecorderFor("postgresuser-controller"),
+		TokenProvider: tokenProvider,
+		ObserveOnly:   observeOnly,
+		AuditLog:      auditLog,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PostgresUser")
-		os.Exit(1)
+		exit(1)
 	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+		exit(1)
 	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		exit(1)
 	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+		exit(1)
+	}
+	closeAuditFile(auditFile)
+}
+
+// closeAuditFile syncs and closes the audit log file, if one was opened
+func closeAuditFile(f *os.File) {
+	if f == nil {
+		return
+	}
+	if err := f.Sync(); err != nil {
+		setupLog.Error(err, "unable to sync audit log")
+	}
+	if err := f.Close(); err != nil {
+		setupLog.Error(err, "unable to close audit log")
 	}
 }
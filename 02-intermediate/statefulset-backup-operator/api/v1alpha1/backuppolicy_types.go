@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -10,9 +11,32 @@ type BackupPolicySpec struct {
 	// +kubebuilder:validation:Required
 	Schedule string `json:"schedule"`
 
-	// PVCSelector selects PVCs to backup
-	// +kubebuilder:validation:Required
-	PVCSelector metav1.LabelSelector `json:"pvcSelector"`
+	// ScheduleJitter delays each scheduled run by up to this long so policies
+	// sharing a schedule don't all start at once. The delay is derived from the
+	// policy's name and the scheduled time, so it is stable across reconciles.
+	// +optional
+	ScheduleJitter *metav1.Duration `json:"scheduleJitter,omitempty"`
+
+	// BackupWindow confines scheduled backups to recurring hours. A run due
+	// while the window is closed waits for the next window to open.
+	// +optional
+	BackupWindow *BackupWindow `json:"backupWindow,omitempty"`
+
+	// MinInterval is the shortest time allowed between two backup runs,
+	// whether scheduled or triggered. A run due sooner is deferred until the
+	// interval has passed since lastScheduleTime.
+	// +optional
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+
+	// PVCSelector selects PVCs to backup. It may be left empty when
+	// StatefulSetSelector is set.
+	// +optional
+	PVCSelector metav1.LabelSelector `json:"pvcSelector,omitempty"`
+
+	// StatefulSetSelector selects StatefulSets whose volumeClaimTemplate PVCs
+	// are backed up. Results are combined with PVCSelector.
+	// +optional
+	StatefulSetSelector *metav1.LabelSelector `json:"statefulSetSelector,omitempty"`
 
 	// BackupStrategy defines how to perform backups
 	// +kubebuilder:validation:Enum=snapshot;tar;custom
@@ -28,12 +52,179 @@ type BackupPolicySpec struct {
 	// +kubebuilder:default="busybox:latest"
 	BackupImage string `json:"backupImage,omitempty"`
 
+	// ImagePullPolicy is the pull policy of BackupImage. Defaults to Always
+	// for the latest tag or an untagged image, and IfNotPresent otherwise.
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets are used to pull BackupImage and the post-backup hook
+	// image from private registries
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// ActiveDeadlineSeconds bounds how long a backup Job may run. A Job past
+	// the deadline is terminated and recorded as failed. Unset means no limit.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// FileNameTemplate is a Go template for the archive file name, rendered
+	// with .PVC, .Timestamp, .Namespace and .PolicyName. The result must be a
+	// plain file name and must change with .Timestamp.
+	// +kubebuilder:default="{{.PVC}}-{{.Timestamp}}.tar.gz"
+	// +optional
+	FileNameTemplate string `json:"fileNameTemplate,omitempty"`
+
+	// ExcludePaths lists paths relative to the PVC root to leave out of tar backups
+	ExcludePaths []string `json:"excludePaths,omitempty"`
+
+	// Split writes one tar archive per top-level directory of the PVC instead
+	// of a single archive, creating them in parallel. Unset keeps one archive.
+	// +optional
+	Split *SplitSpec `json:"split,omitempty"`
+
+	// CheckFreeSpace makes tar backups compare the free space on the storage
+	// PVC with the size of the source before writing anything, and fail with
+	// reason InsufficientSpace instead of leaving a truncated archive
+	// +optional
+	CheckFreeSpace bool `json:"checkFreeSpace,omitempty"`
+
+	// ServiceAccountName is the ServiceAccount backup Jobs run as
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// PodSecurityContext is applied to backup Job pods. Defaults to running
+	// as a non-root user with the RuntimeDefault seccomp profile.
+	PodSecurityContext *corev1.PodSecurityContext `json:"podSecurityContext,omitempty"`
+
+	// SecurityContext is applied to the backup container. Defaults to
+	// dropping all capabilities and disallowing privilege escalation.
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
 	// BackupStoragePVC is the PVC to store backups
 	// +kubebuilder:validation:Required
 	BackupStoragePVC string `json:"backupStoragePVC"`
 
+	// StorageRoutes send backups of matching PVCs to a different storage PVC.
+	// The first matching route wins; unmatched PVCs use BackupStoragePVC.
+	// A backup.example.com/storage-pvc annotation on a PVC overrides both.
+	StorageRoutes []StorageRoute `json:"storageRoutes,omitempty"`
+
 	// Suspend pauses backup scheduling
 	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun only records which PVCs the policy selects without creating backup jobs
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// CloudEventsSink is an HTTP endpoint that receives a CloudEvent when a
+	// backup Job starts, succeeds or fails
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	CloudEventsSink string `json:"cloudEventsSink,omitempty"`
+
+	// PostBackupHook runs a Job after each successful backup, for example to
+	// register the archive in an external catalog
+	// +optional
+	PostBackupHook *PostBackupHook `json:"postBackupHook,omitempty"`
+
+	// WarmStandby extracts the latest backup of each PVC into a standby PVC
+	// after it succeeds, keeping a ready-to-mount copy for fast restores
+	// +optional
+	WarmStandby *WarmStandbySpec `json:"warmStandby,omitempty"`
+
+	// RetentionEnabled controls automatic cleanup of old backups. When false,
+	// new backups are still created but no backup Jobs are ever deleted.
+	// +kubebuilder:default=true
+	RetentionEnabled *bool `json:"retentionEnabled,omitempty"`
+}
+
+// BackupWindow is a recurring period in which scheduled backups may start
+type BackupWindow struct {
+	// Start is a cron expression for when each window opens
+	// +kubebuilder:validation:Required
+	Start string `json:"start"`
+
+	// Duration is how long each window stays open
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+
+	// CancelAtWindowEnd terminates backup Jobs still running when the window
+	// closes. By default they are allowed to finish.
+	// +optional
+	CancelAtWindowEnd bool `json:"cancelAtWindowEnd,omitempty"`
+}
+
+// SplitSpec tunes split tar backups
+type SplitSpec struct {
+	// Parallelism is how many archives are written at once
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=4
+	Parallelism int32 `json:"parallelism,omitempty"`
+}
+
+// PostBackupHook is a container run once for every successful backup. It
+// receives the backup's metadata in BACKUP_* environment variables.
+type PostBackupHook struct {
+	// Image is the container image the hook runs
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Command overrides the image's entrypoint
+	Command []string `json:"command,omitempty"`
+
+	// Args are passed to the entrypoint
+	Args []string `json:"args,omitempty"`
+
+	// BlockCompletion keeps a backup Running until its hook succeeds, and
+	// marks it Failed if the hook fails. Otherwise the hook's outcome is only
+	// reported in the backup's hookStatus.
+	BlockCompletion bool `json:"blockCompletion,omitempty"`
+}
+
+// WarmStandbySpec configures the standby copy of backed-up PVCs
+type WarmStandbySpec struct {
+	// PVC is the standby PVC in the policy's namespace. Each source PVC is
+	// extracted into a directory of the same name on it.
+	// +kubebuilder:validation:MinLength=1
+	PVC string `json:"pvc"`
+
+	// MinInterval is the shortest time between two refreshes of a PVC's
+	// standby copy. Backups finishing sooner are not extracted; the latest
+	// backup is extracted once the interval has passed. Unset refreshes the
+	// copy after every backup.
+	// +optional
+	MinInterval *metav1.Duration `json:"minInterval,omitempty"`
+}
+
+// WarmStandbyStatus reports the standby copy of one source PVC
+type WarmStandbyStatus struct {
+	// PVC is the source PVC
+	PVC string `json:"pvc"`
+
+	// BackupJob is the backup whose archive was last extracted
+	BackupJob string `json:"backupJob"`
+
+	// JobName is the Job extracting the archive into the standby PVC
+	JobName string `json:"jobName"`
+
+	// Phase is the state of the extraction (Running, Succeeded, Failed)
+	Phase string `json:"phase"`
+
+	// StartTime is when the extraction started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the extraction finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// StorageRoute maps source PVCs to a backup storage PVC
+type StorageRoute struct {
+	// Selector matches source PVCs by label
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// BackupStoragePVC is the PVC that stores backups of matching PVCs
+	// +kubebuilder:validation:MinLength=1
+	BackupStoragePVC string `json:"backupStoragePVC"`
 }
 
 // BackupRecord contains information about a backup
@@ -52,16 +243,121 @@ type BackupRecord struct {
 
 	// Message provides additional information
 	Message string `json:"message,omitempty"`
+
+	// SizeBytes is the size of the backup archive, or zero if unknown
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// HookStatus is the state of the post-backup hook Job (Pending, Running,
+	// Succeeded, Failed), or empty if no hook runs for this backup
+	HookStatus string `json:"hookStatus,omitempty"`
+
+	// Reason is why a failed backup failed, when it is known:
+	// InsufficientSpace when the free-space check stopped it before writing
+	Reason string `json:"reason,omitempty"`
+}
+
+// PVCBackupStatus reports the backups of one source PVC
+type PVCBackupStatus struct {
+	// PVC is the source PVC
+	PVC string `json:"pvc"`
+
+	// JobName is the PVC's most recent backup Job
+	JobName string `json:"jobName"`
+
+	// Phase is the status of that Job: Pending, Running, Succeeded or Failed
+	Phase string `json:"phase"`
+
+	// StartTime is when that Job started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when that Job succeeded
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// LastSuccessfulTime is when a backup of the PVC last succeeded
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+}
+
+// MountedPVCStatus reports how a ReadWriteOnce or ReadWriteOncePod PVC that
+// a running pod mounts is backed up
+type MountedPVCStatus struct {
+	// PVC is the source PVC
+	PVC string `json:"pvc"`
+
+	// Pod is the running pod mounting the PVC
+	Pod string `json:"pod"`
+
+	// Node is the node the PVC is attached to
+	Node string `json:"node"`
+
+	// Action is PinnedToNode when the backup Job runs on Node, where a
+	// ReadWriteOnce volume can be mounted a second time, or Skipped when the
+	// PVC is ReadWriteOncePod and no other pod may mount it
+	Action string `json:"action"`
+}
+
+// TriggerStatus reports the outcome of an on-demand backup requested through
+// the backup.example.com/trigger annotation
+type TriggerStatus struct {
+	// Token is the trigger annotation value this status belongs to
+	Token string `json:"token"`
+
+	// JobNames lists the backup jobs created for this trigger
+	JobNames []string `json:"jobNames,omitempty"`
+
+	// Phase is the overall trigger state (Pending, Running, Succeeded,
+	// Failed). Pending means the run is deferred by minInterval.
+	Phase string `json:"phase"`
+
+	// StartTime is when the triggered backup started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when every triggered job finished
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Message provides additional information
+	Message string `json:"message,omitempty"`
 }
 
 // BackupPolicyStatus defines the observed state of BackupPolicy
 type BackupPolicyStatus struct {
-	// LastScheduleTime is when the last backup was scheduled
+	// LastScheduleTime is when the last backup run started, whether
+	// scheduled or triggered
 	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
 
 	// LastSuccessfulTime is when the last backup succeeded
 	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
 
+	// LastBackupSize is the size in bytes of the most recent successful backup
+	LastBackupSize int64 `json:"lastBackupSize,omitempty"`
+
+	// TotalStorageUsed is the combined size in bytes of all retained backups
+	TotalStorageUsed int64 `json:"totalStorageUsed,omitempty"`
+
+	// MatchedPVCs lists the PVCs selected by the policy while in dry-run mode
+	MatchedPVCs []string `json:"matchedPVCs,omitempty"`
+
+	// SkippedPVCs lists selected PVCs left out because they carry the
+	// backup.example.com/skip=true annotation
+	SkippedPVCs []string `json:"skippedPVCs,omitempty"`
+
+	// DeferredPVCs lists the PVCs whose scheduled backup is waiting for the
+	// next backup window
+	DeferredPVCs []string `json:"deferredPVCs,omitempty"`
+
+	// MountedPVCs reports the selected single-node PVCs a running pod already
+	// mounts, and how each of them is backed up
+	MountedPVCs []MountedPVCStatus `json:"mountedPVCs,omitempty"`
+
+	// PVCBackups reports the latest backup of each source PVC, so a slow
+	// volume stands out
+	PVCBackups []PVCBackupStatus `json:"pvcBackups,omitempty"`
+
+	// Trigger reports the most recent on-demand backup
+	Trigger *TriggerStatus `json:"trigger,omitempty"`
+
+	// WarmStandby reports the standby copy of each backed-up PVC
+	WarmStandby []WarmStandbyStatus `json:"warmStandby,omitempty"`
+
 	// BackupHistory contains recent backup information
 	BackupHistory []BackupRecord `json:"backupHistory,omitempty"`
 
@@ -0,0 +1,94 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// SetupWebhookWithManager registers the BackupPolicy validating webhook
+func (r *BackupPolicy) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		WithValidator(&backupPolicyValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-backup-example-com-v1alpha1-backuppolicy,mutating=false,failurePolicy=fail,sideEffects=None,groups=backup.example.com,resources=backuppolicies,verbs=create;update,versions=v1alpha1,name=vbackuppolicy.kb.io,admissionReviewVersions=v1
+
+// backupPolicyValidator rejects policies the controller could never run, so
+// mistakes surface at kubectl apply instead of in the Ready condition
+type backupPolicyValidator struct{}
+
+var _ admission.CustomValidator = &backupPolicyValidator{}
+
+func (v *backupPolicyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	policy, ok := obj.(*BackupPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a BackupPolicy but got %T", obj)
+	}
+	return nil, policy.validate()
+}
+
+func (v *backupPolicyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	policy, ok := newObj.(*BackupPolicy)
+	if !ok {
+		return nil, fmt.Errorf("expected a BackupPolicy but got %T", newObj)
+	}
+	return nil, policy.validate()
+}
+
+func (v *backupPolicyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (r *BackupPolicy) validate() error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if _, err := cron.ParseStandard(r.Spec.Schedule); err != nil {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("schedule"), r.Spec.Schedule,
+			fmt.Sprintf("must be a standard cron expression such as \"0 2 * * *\": %v", err)))
+	}
+
+	if window := r.Spec.BackupWindow; window != nil {
+		windowPath := specPath.Child("backupWindow")
+		if schedule, err := cron.ParseStandard(window.Start); err != nil {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("start"), window.Start,
+				fmt.Sprintf("must be a standard cron expression such as \"0 0 * * *\": %v", err)))
+		} else if schedule.Next(time.Now()).IsZero() {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("start"), window.Start,
+				"never matches a date, so the window would never open"))
+		}
+		if window.Duration.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(windowPath.Child("duration"), window.Duration.Duration.String(),
+				"must be positive"))
+		}
+	}
+
+	if r.Spec.BackupStoragePVC == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("backupStoragePVC"),
+			"name the PVC that backup archives are written to"))
+	}
+
+	// Only tar has a backup Job behind it; the other strategies would fail on every run
+	switch r.Spec.BackupStrategy {
+	case "", "tar":
+	case "snapshot", "custom":
+		allErrs = append(allErrs, field.NotSupported(specPath.Child("backupStrategy"),
+			r.Spec.BackupStrategy, []string{"tar"}))
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "BackupPolicy"}, r.Name, allErrs)
+}
@@ -21,6 +21,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -37,7 +38,79 @@ func (in *BackupPolicy) DeepCopyInto(out *BackupPolicy) {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BackupPolicySpec) DeepCopyInto(out *BackupPolicySpec) {
 	*out = *in
+	if in.ScheduleJitter != nil {
+		in, out := &in.ScheduleJitter, &out.ScheduleJitter
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.BackupWindow != nil {
+		in, out := &in.BackupWindow, &out.BackupWindow
+		*out = new(BackupWindow)
+		**out = **in
+	}
+	if in.MinInterval != nil {
+		in, out := &in.MinInterval, &out.MinInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 	in.PVCSelector.DeepCopyInto(&out.PVCSelector)
+	if in.StatefulSetSelector != nil {
+		in, out := &in.StatefulSetSelector, &out.StatefulSetSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ExcludePaths != nil {
+		in, out := &in.ExcludePaths, &out.ExcludePaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Split != nil {
+		in, out := &in.Split, &out.Split
+		*out = new(SplitSpec)
+		**out = **in
+	}
+	if in.PodSecurityContext != nil {
+		in, out := &in.PodSecurityContext, &out.PodSecurityContext
+		*out = new(v1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StorageRoutes != nil {
+		in, out := &in.StorageRoutes, &out.StorageRoutes
+		*out = make([]StorageRoute, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostBackupHook != nil {
+		in, out := &in.PostBackupHook, &out.PostBackupHook
+		*out = new(PostBackupHook)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WarmStandby != nil {
+		in, out := &in.WarmStandby, &out.WarmStandby
+		*out = new(WarmStandbySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RetentionEnabled != nil {
+		in, out := &in.RetentionEnabled, &out.RetentionEnabled
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupPolicySpec.
@@ -61,6 +134,45 @@ func (in *BackupPolicyStatus) DeepCopyInto(out *BackupPolicyStatus) {
 		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
 		*out = (*in).DeepCopy()
 	}
+	if in.MatchedPVCs != nil {
+		in, out := &in.MatchedPVCs, &out.MatchedPVCs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SkippedPVCs != nil {
+		in, out := &in.SkippedPVCs, &out.SkippedPVCs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeferredPVCs != nil {
+		in, out := &in.DeferredPVCs, &out.DeferredPVCs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MountedPVCs != nil {
+		in, out := &in.MountedPVCs, &out.MountedPVCs
+		*out = make([]MountedPVCStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.PVCBackups != nil {
+		in, out := &in.PVCBackups, &out.PVCBackups
+		*out = make([]PVCBackupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Trigger != nil {
+		in, out := &in.Trigger, &out.Trigger
+		*out = new(TriggerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WarmStandby != nil {
+		in, out := &in.WarmStandby, &out.WarmStandby
+		*out = make([]WarmStandbyStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.BackupHistory != nil {
 		in, out := &in.BackupHistory, &out.BackupHistory
 		*out = make([]BackupRecord, len(*in))
@@ -156,3 +268,188 @@ func (in *BackupPolicyList) DeepCopyObject() runtime.Object {
 	}
 	return nil
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TriggerStatus) DeepCopyInto(out *TriggerStatus) {
+	*out = *in
+	if in.JobNames != nil {
+		in, out := &in.JobNames, &out.JobNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TriggerStatus.
+func (in *TriggerStatus) DeepCopy() *TriggerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TriggerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StorageRoute) DeepCopyInto(out *StorageRoute) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StorageRoute.
+func (in *StorageRoute) DeepCopy() *StorageRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(StorageRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplitSpec) DeepCopyInto(out *SplitSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplitSpec.
+func (in *SplitSpec) DeepCopy() *SplitSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SplitSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostBackupHook) DeepCopyInto(out *PostBackupHook) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostBackupHook.
+func (in *PostBackupHook) DeepCopy() *PostBackupHook {
+	if in == nil {
+		return nil
+	}
+	out := new(PostBackupHook)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WarmStandbySpec) DeepCopyInto(out *WarmStandbySpec) {
+	*out = *in
+	if in.MinInterval != nil {
+		in, out := &in.MinInterval, &out.MinInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WarmStandbySpec.
+func (in *WarmStandbySpec) DeepCopy() *WarmStandbySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WarmStandbySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WarmStandbyStatus) DeepCopyInto(out *WarmStandbyStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WarmStandbyStatus.
+func (in *WarmStandbyStatus) DeepCopy() *WarmStandbyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WarmStandbyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupWindow) DeepCopyInto(out *BackupWindow) {
+	*out = *in
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupWindow.
+func (in *BackupWindow) DeepCopy() *BackupWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCBackupStatus) DeepCopyInto(out *PVCBackupStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCBackupStatus.
+func (in *PVCBackupStatus) DeepCopy() *PVCBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MountedPVCStatus) DeepCopyInto(out *MountedPVCStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MountedPVCStatus.
+func (in *MountedPVCStatus) DeepCopy() *MountedPVCStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MountedPVCStatus)
+	in.DeepCopyInto(out)
+	return out
+}
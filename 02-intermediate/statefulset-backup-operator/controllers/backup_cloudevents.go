@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+)
+
+const (
+	eventBackupStarted   = "backup.started"
+	eventBackupSucceeded = "backup.succeeded"
+	eventBackupFailed    = "backup.failed"
+
+	cloudEventsTimeout = 10 * time.Second
+)
+
+// backupEventData is the payload of every backup CloudEvent
+type backupEventData struct {
+	Policy          string     `json:"policy"`
+	Namespace       string     `json:"namespace"`
+	PVC             string     `json:"pvc"`
+	JobName         string     `json:"jobName"`
+	StartTime       *time.Time `json:"startTime,omitempty"`
+	CompletionTime  *time.Time `json:"completionTime,omitempty"`
+	DurationSeconds float64    `json:"durationSeconds,omitempty"`
+	SizeBytes       int64      `json:"sizeBytes,omitempty"`
+	Message         string     `json:"message,omitempty"`
+}
+
+// publishTransitions sends a CloudEvent for each lifecycle transition the Job
+// has reached and not yet reported. Delivered types are recorded on the Job,
+// so each transition is sent once; a failed delivery is retried on the next
+// reconcile.
+func (r *BackupPolicyReconciler) publishTransitions(ctx context.Context, policy *backupv1alpha1.BackupPolicy, job *batchv1.Job, record *backupv1alpha1.BackupRecord) {
+	log := log.FromContext(ctx)
+
+	var transitions []string
+	if job.Status.StartTime != nil {
+		transitions = append(transitions, eventBackupStarted)
+	}
+	switch record.Status {
+	case "Succeeded":
+		transitions = append(transitions, eventBackupSucceeded)
+	case "Failed":
+		// Also covers backups failed by the operator rather than the Job,
+		// such as one whose post-backup hook failed
+		transitions = append(transitions, eventBackupFailed)
+	}
+
+	sent := strings.Split(job.Annotations[cloudEventsAnnotation], ",")
+	delivered := false
+	for _, eventType := range transitions {
+		if slices.Contains(sent, eventType) {
+			continue
+		}
+		if err := sendCloudEvent(ctx, policy, job, eventType, backupEvent(policy, job, record, eventType)); err != nil {
+			log.Error(err, "Failed to deliver CloudEvent", "job", job.Name, "type", eventType)
+			break
+		}
+		sent = append(sent, eventType)
+		delivered = true
+	}
+	if !delivered {
+		return
+	}
+
+	patch := client.MergeFrom(job.DeepCopy())
+	if job.Annotations == nil {
+		job.Annotations = map[string]string{}
+	}
+	job.Annotations[cloudEventsAnnotation] = strings.Trim(strings.Join(sent, ","), ",")
+	if err := r.Patch(ctx, job, patch); err != nil {
+		log.Error(err, "Failed to record delivered CloudEvents on job", "job", job.Name)
+	}
+}
+
+// backupEvent builds the event payload for a Job
+func backupEvent(policy *backupv1alpha1.BackupPolicy, job *batchv1.Job, record *backupv1alpha1.BackupRecord, eventType string) backupEventData {
+	data := backupEventData{
+		Policy:    policy.Name,
+		Namespace: policy.Namespace,
+		PVC:       job.Labels["pvc"],
+		JobName:   job.Name,
+	}
+	if job.Status.StartTime != nil {
+		data.StartTime = &job.Status.StartTime.Time
+	}
+
+	switch eventType {
+	case eventBackupSucceeded:
+		if job.Status.CompletionTime != nil {
+			data.CompletionTime = &job.Status.CompletionTime.Time
+		}
+		data.SizeBytes = record.SizeBytes
+	case eventBackupFailed:
+		for _, condition := range job.Status.Conditions {
+			if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+				data.CompletionTime = &condition.LastTransitionTime.Time
+				data.Message = condition.Message
+			}
+		}
+	}
+	if data.StartTime != nil && data.CompletionTime != nil {
+		data.DurationSeconds = data.CompletionTime.Sub(*data.StartTime).Seconds()
+	}
+	return data
+}
+
+// sendCloudEvent posts data to the policy's sink as a binary-mode CloudEvent.
+// The id is derived from the Job and type, so sinks can drop redeliveries.
+func sendCloudEvent(ctx context.Context, policy *backupv1alpha1.BackupPolicy, job *batchv1.Job, eventType string, data backupEventData) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cloudEventsTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, policy.Spec.CloudEventsSink, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("ce-specversion", "1.0")
+	req.Header.Set("ce-id", fmt.Sprintf("%s/%s/%s", job.Namespace, job.Name, eventType))
+	req.Header.Set("ce-type", eventType)
+	req.Header.Set("ce-source", fmt.Sprintf("/apis/backup.example.com/v1alpha1/namespaces/%s/backuppolicies/%s", policy.Namespace, policy.Name))
+	req.Header.Set("ce-subject", job.Name)
+	req.Header.Set("ce-time", time.Now().UTC().Format(time.RFC3339))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sink returned %s", resp.Status)
+	}
+	return nil
+}
+
+// jobFailed reports whether the Job has given up after exhausting its retries
+func jobFailed(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+)
+
+// hookPolicyLabel marks post-backup hook Jobs with the policy they belong to
+const hookPolicyLabel = "backup.example.com/hook-for-policy"
+
+// reconcilePostBackupHook starts the hook Job for a succeeded backup Job and
+// returns the hook's state. The hook Job is owned by the backup Job, so
+// retention cleanup removes both together.
+func (r *BackupPolicyReconciler) reconcilePostBackupHook(ctx context.Context, policy *backupv1alpha1.BackupPolicy, job *batchv1.Job, sizeBytes int64) string {
+	log := log.FromContext(ctx)
+
+	hookJob := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: hookJobName(job), Namespace: job.Namespace}, hookJob)
+	if errors.IsNotFound(err) {
+		hookJob = r.desiredHookJob(policy, job, sizeBytes)
+		if err := controllerutil.SetControllerReference(job, hookJob, r.Scheme); err != nil {
+			log.Error(err, "Failed to set owner on post-backup hook job", "job", job.Name)
+			return "Pending"
+		}
+		if err := r.Create(ctx, hookJob); err != nil && !errors.IsAlreadyExists(err) {
+			log.Error(err, "Failed to create post-backup hook job", "job", job.Name)
+		} else {
+			log.Info("Started post-backup hook", "job", job.Name, "hookJob", hookJob.Name)
+		}
+		return "Pending"
+	} else if err != nil {
+		log.Error(err, "Failed to get post-backup hook job", "job", job.Name)
+		return "Pending"
+	}
+
+	switch {
+	case hookJob.Status.Succeeded > 0:
+		return "Succeeded"
+	case jobFailed(hookJob):
+		return "Failed"
+	case hookJob.Status.Active > 0:
+		return "Running"
+	default:
+		return "Pending"
+	}
+}
+
+// desiredHookJob builds the hook Job for a succeeded backup Job, passing the
+// backup's metadata in BACKUP_* environment variables
+func (r *BackupPolicyReconciler) desiredHookJob(policy *backupv1alpha1.BackupPolicy, job *batchv1.Job, sizeBytes int64) *batchv1.Job {
+	hook := policy.Spec.PostBackupHook
+	pvcName := job.Labels["pvc"]
+	timestamp := job.Labels["timestamp"]
+	backupFile, _ := backupArchive(policy, job)
+
+	completionTime := ""
+	if job.Status.CompletionTime != nil {
+		completionTime = job.Status.CompletionTime.UTC().Format(time.RFC3339)
+	}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      hookJobName(job),
+			Namespace: job.Namespace,
+			Labels: map[string]string{
+				hookPolicyLabel: policy.Name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: policy.Spec.ServiceAccountName,
+					SecurityContext:    podSecurityContext(policy),
+					ImagePullSecrets:   policy.Spec.ImagePullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:            "hook",
+							Image:           hook.Image,
+							Command:         hook.Command,
+							Args:            hook.Args,
+							SecurityContext: containerSecurityContext(policy),
+							Env: []corev1.EnvVar{
+								{Name: "BACKUP_POLICY", Value: policy.Name},
+								{Name: "BACKUP_NAMESPACE", Value: policy.Namespace},
+								{Name: "BACKUP_JOB", Value: job.Name},
+								{Name: "BACKUP_PVC", Value: pvcName},
+								{Name: "BACKUP_STORAGE_PVC", Value: jobStoragePVC(job)},
+								{Name: "BACKUP_FILE", Value: backupFile},
+								{Name: "BACKUP_TIMESTAMP", Value: timestamp},
+								{Name: "BACKUP_COMPLETION_TIME", Value: completionTime},
+								{Name: "BACKUP_SIZE_BYTES", Value: strconv.FormatInt(sizeBytes, 10)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func hookJobName(job *batchv1.Job) string {
+	return job.Name + "-hook"
+}
+
+// findPolicyForHookJob maps a post-backup hook Job to its policy, so hook
+// progress updates the backup history
+func (r *BackupPolicyReconciler) findPolicyForHookJob(ctx context.Context, obj client.Object) []reconcile.Request {
+	policyName, ok := obj.GetLabels()[hookPolicyLabel]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: policyName, Namespace: obj.GetNamespace()},
+	}}
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+)
+
+// Actions taken for a PVC a running pod already mounts
+const (
+	mountedPinnedToNode = "PinnedToNode"
+	mountedSkipped      = "Skipped"
+)
+
+// reconcileMountedPVCs looks up the running pods mounting the selected
+// single-node PVCs. A ReadWriteOnce volume attaches to one node but may be
+// mounted by any number of pods on it, so the backup Job of such a PVC is
+// pinned to that node instead of failing with a multi-attach error. A
+// ReadWriteOncePod volume can't be mounted a second time at all, so the PVC
+// is left out. The decisions are recorded in status.mountedPVCs and the
+// PVCsMounted condition. It returns the PVCs to back up and the node each
+// mounted one is pinned to.
+func (r *BackupPolicyReconciler) reconcileMountedPVCs(ctx context.Context, policy *backupv1alpha1.BackupPolicy, pvcs []corev1.PersistentVolumeClaim) ([]corev1.PersistentVolumeClaim, map[string]string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(policy.Namespace)); err != nil {
+		return nil, nil, err
+	}
+
+	var statuses []backupv1alpha1.MountedPVCStatus
+	var backups []corev1.PersistentVolumeClaim
+	var skipped []string
+	nodes := map[string]string{}
+	for _, pvc := range pvcs {
+		pod := mountingPod(pods.Items, &pvc)
+		if pod == nil {
+			backups = append(backups, pvc)
+			continue
+		}
+
+		status := backupv1alpha1.MountedPVCStatus{
+			PVC:    pvc.Name,
+			Pod:    pod.Name,
+			Node:   pod.Spec.NodeName,
+			Action: mountedPinnedToNode,
+		}
+		if hasAccessMode(&pvc, corev1.ReadWriteOncePod) {
+			status.Action = mountedSkipped
+			skipped = append(skipped, pvc.Name)
+			log.FromContext(ctx).Info("Skipping backup, ReadWriteOncePod PVC is mounted by a running pod", "pvc", pvc.Name, "pod", pod.Name)
+		} else {
+			nodes[pvc.Name] = pod.Spec.NodeName
+			backups = append(backups, pvc)
+		}
+		statuses = append(statuses, status)
+	}
+
+	policy.Status.MountedPVCs = statuses
+	switch {
+	case len(statuses) == 0:
+		meta.RemoveStatusCondition(&policy.Status.Conditions, "PVCsMounted")
+	case len(skipped) > 0:
+		r.updateCondition(ctx, policy, "PVCsMounted", metav1.ConditionTrue, "ReadWriteOncePodInUse",
+			fmt.Sprintf("Skipped %d ReadWriteOncePod PVC(s) mounted by a running pod: %s", len(skipped), strings.Join(skipped, ", ")))
+	default:
+		r.updateCondition(ctx, policy, "PVCsMounted", metav1.ConditionTrue, "PinnedToNode",
+			fmt.Sprintf("Backups of %d mounted ReadWriteOnce PVC(s) run on the node they are attached to", len(statuses)))
+	}
+	return backups, nodes, nil
+}
+
+// mountingPod returns a scheduled, unfinished pod mounting a single-node
+// PVC, or nil when the PVC may be attached to several nodes or isn't in use
+func mountingPod(pods []corev1.Pod, pvc *corev1.PersistentVolumeClaim) *corev1.Pod {
+	if hasAccessMode(pvc, corev1.ReadWriteMany) || hasAccessMode(pvc, corev1.ReadOnlyMany) {
+		return nil
+	}
+	if !hasAccessMode(pvc, corev1.ReadWriteOnce) && !hasAccessMode(pvc, corev1.ReadWriteOncePod) {
+		return nil
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				return pod
+			}
+		}
+	}
+	return nil
+}
+
+// hasAccessMode reports whether the PVC was bound with mode, or requests it
+// while still unbound
+func hasAccessMode(pvc *corev1.PersistentVolumeClaim, mode corev1.PersistentVolumeAccessMode) bool {
+	modes := pvc.Status.AccessModes
+	if len(modes) == 0 {
+		modes = pvc.Spec.AccessModes
+	}
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeAffinity requires a backup Job's pod to run on node
+func nodeAffinity(node string) *corev1.Affinity {
+	if node == "" {
+		return nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{
+						MatchFields: []corev1.NodeSelectorRequirement{
+							{
+								Key:      "metadata.name",
+								Operator: corev1.NodeSelectorOpIn,
+								Values:   []string{node},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+)
+
+const (
+	// insufficientSpaceExitCode is what the free-space check exits with when
+	// the storage PVC can't hold the backup
+	insufficientSpaceExitCode = 75
+
+	// reasonInsufficientSpace marks a backup record stopped by the free-space check
+	reasonInsufficientSpace = "InsufficientSpace"
+)
+
+// spaceCheckScript stops the backup before anything is written when the
+// storage PVC has less room than the source uses. The uncompressed size
+// over-estimates the archive, so the check errs on the side of failing early.
+const spaceCheckScript = `need=$(du -sk /data | cut -f1); free=$(df -Pk /backup | awk 'NR==2 {print $4}'); ` +
+	`if [ "$free" -lt "$need" ]; then ` +
+	`echo "Insufficient space on /backup: /data uses ${need} KiB, ${free} KiB free" | tee /dev/termination-log; exit %d; fi`
+
+// spaceCheckCommand returns the free-space check to run ahead of a tar
+// backup, or "" when the policy doesn't ask for one
+func spaceCheckCommand(policy *backupv1alpha1.BackupPolicy) string {
+	if !policy.Spec.CheckFreeSpace {
+		return ""
+	}
+	return fmt.Sprintf(spaceCheckScript, insufficientSpaceExitCode) + " && "
+}
+
+// spaceCheckFailurePolicy fails the Job on the first failed free-space check.
+// Retrying would only find the same shortage again.
+func spaceCheckFailurePolicy(policy *backupv1alpha1.BackupPolicy) *batchv1.PodFailurePolicy {
+	if !policy.Spec.CheckFreeSpace {
+		return nil
+	}
+	container := "backup"
+	return &batchv1.PodFailurePolicy{
+		Rules: []batchv1.PodFailurePolicyRule{
+			{
+				Action: batchv1.PodFailurePolicyActionFailJob,
+				OnExitCodes: &batchv1.PodFailurePolicyOnExitCodesRequirement{
+					ContainerName: &container,
+					Operator:      batchv1.PodFailurePolicyOnExitCodesOpIn,
+					Values:        []int32{insufficientSpaceExitCode},
+				},
+			},
+		},
+	}
+}
+
+// insufficientSpace reports whether a failed Job was stopped by the
+// free-space check, which is its only pod failure policy rule
+func insufficientSpace(job *batchv1.Job) bool {
+	if job.Spec.PodFailurePolicy == nil {
+		return false
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue &&
+			condition.Reason == batchv1.JobReasonPodFailurePolicy {
+			return true
+		}
+	}
+	return false
+}
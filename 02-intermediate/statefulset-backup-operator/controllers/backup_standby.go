@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+)
+
+// standbyPolicyLabel marks warm-standby Jobs with the policy they belong to
+const standbyPolicyLabel = "backup.example.com/standby-for-policy"
+
+// standbyScript extracts a backup into <dir>.tmp on the standby PVC and then
+// swaps it in place of <dir>, so the previous copy stays usable until the new
+// one is complete. The archive word expands to the single archive and to any
+// split parts; whichever exist are extracted.
+const standbyScript = `(set -e
+dir=%[1]s
+rm -rf "$dir.tmp" && mkdir -p "$dir.tmp"
+n=0
+for part in %[2]s %[2]s.part-*; do
+  [ -f "$part" ] || continue
+  case "$part" in *.json) continue;; esac
+  tar xzf "$part" -C "$dir.tmp"
+  n=$((n+1))
+done
+if [ $n -eq 0 ]; then echo "No archive found at "%[2]s >&2; exit 1; fi
+rm -rf "$dir.old"
+[ ! -e "$dir" ] || mv "$dir" "$dir.old"
+mv "$dir.tmp" "$dir"
+rm -rf "$dir.old"
+echo "Standby copy refreshed: $dir")`
+
+// reconcileWarmStandby extracts the latest succeeded backup of each PVC into
+// the standby PVC and tracks the extraction Jobs in status.warmStandby. A
+// PVC's copy is refreshed at most once per MinInterval, and one extraction
+// per PVC runs at a time. It returns how long until a backup held back by
+// MinInterval can be extracted, or 0 when none is waiting.
+func (r *BackupPolicyReconciler) reconcileWarmStandby(ctx context.Context, policy *backupv1alpha1.BackupPolicy) time.Duration {
+	log := log.FromContext(ctx)
+
+	if policy.Spec.WarmStandby == nil {
+		policy.Status.WarmStandby = nil
+		return 0
+	}
+
+	jobList := &batchv1.JobList{}
+	if err := r.List(ctx, jobList, client.InNamespace(policy.Namespace),
+		client.MatchingLabels{"backup-policy": policy.Name}); err != nil {
+		log.Error(err, "Failed to list backup jobs for warm standby")
+		return 0
+	}
+
+	// Find the newest succeeded backup of each PVC
+	latest := map[string]*batchv1.Job{}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if job.Status.Succeeded == 0 || job.Status.CompletionTime == nil {
+			continue
+		}
+		pvcName := job.Labels["pvc"]
+		if current, ok := latest[pvcName]; !ok || job.Status.CompletionTime.After(current.Status.CompletionTime.Time) {
+			latest[pvcName] = job
+		}
+	}
+
+	pvcNames := make([]string, 0, len(latest))
+	for pvcName := range latest {
+		pvcNames = append(pvcNames, pvcName)
+	}
+	sort.Strings(pvcNames)
+
+	now := time.Now()
+	var statuses []backupv1alpha1.WarmStandbyStatus
+	var requeue time.Duration
+	for _, pvcName := range pvcNames {
+		backup := latest[pvcName]
+		status := standbyStatus(policy, pvcName)
+		if status != nil && status.Phase == "Running" {
+			r.refreshStandby(ctx, policy, status)
+		}
+
+		if status == nil || (status.Phase != "Running" && status.BackupJob != backup.Name) {
+			if wait := standbyWait(policy, status, now); wait > 0 {
+				requeue = earliest(requeue, wait)
+			} else if started, err := r.startStandbyJob(ctx, policy, backup, status); err != nil {
+				log.Error(err, "Failed to start warm standby job", "pvc", pvcName, "job", backup.Name)
+			} else {
+				status = started
+			}
+		}
+
+		if status != nil {
+			statuses = append(statuses, *status)
+		}
+	}
+	policy.Status.WarmStandby = statuses
+	return requeue
+}
+
+// standbyStatus returns a copy of the recorded standby status of a source PVC, if any
+func standbyStatus(policy *backupv1alpha1.BackupPolicy, pvcName string) *backupv1alpha1.WarmStandbyStatus {
+	for i := range policy.Status.WarmStandby {
+		if policy.Status.WarmStandby[i].PVC == pvcName {
+			return policy.Status.WarmStandby[i].DeepCopy()
+		}
+	}
+	return nil
+}
+
+// standbyWait returns how long until MinInterval has passed since the PVC's
+// standby copy was last refreshed, or 0 when it may be refreshed now
+func standbyWait(policy *backupv1alpha1.BackupPolicy, status *backupv1alpha1.WarmStandbyStatus, now time.Time) time.Duration {
+	minInterval := policy.Spec.WarmStandby.MinInterval
+	if minInterval == nil || status == nil || status.StartTime == nil {
+		return 0
+	}
+	return max(status.StartTime.Add(minInterval.Duration).Sub(now), 0)
+}
+
+// refreshStandby updates a running standby status from its Job, emitting a
+// warning event when the extraction fails
+func (r *BackupPolicyReconciler) refreshStandby(ctx context.Context, policy *backupv1alpha1.BackupPolicy, status *backupv1alpha1.WarmStandbyStatus) {
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Name: status.JobName, Namespace: policy.Namespace}, job)
+	if err != nil && !errors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Failed to get warm standby job", "job", status.JobName)
+		return
+	}
+
+	now := metav1.Now()
+	switch {
+	case err != nil || jobFailed(job):
+		status.Phase = "Failed"
+		status.CompletionTime = &now
+		if r.Recorder != nil {
+			r.Recorder.Event(policy, corev1.EventTypeWarning, "WarmStandbyFailed",
+				fmt.Sprintf("Extracting backup %s into standby PVC %s failed", status.BackupJob, policy.Spec.WarmStandby.PVC))
+		}
+	case job.Status.Succeeded > 0:
+		status.Phase = "Succeeded"
+		status.CompletionTime = job.Status.CompletionTime
+		if status.CompletionTime == nil {
+			status.CompletionTime = &now
+		}
+	}
+}
+
+// startStandbyJob deletes the PVC's previous standby Job and starts one
+// extracting backup, returning the new status
+func (r *BackupPolicyReconciler) startStandbyJob(ctx context.Context, policy *backupv1alpha1.BackupPolicy, backup *batchv1.Job, previous *backupv1alpha1.WarmStandbyStatus) (*backupv1alpha1.WarmStandbyStatus, error) {
+	if previous != nil && previous.JobName != "" {
+		old := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: previous.JobName, Namespace: policy.Namespace}}
+		if err := r.Delete(ctx, old, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	job, err := r.desiredStandbyJob(policy, backup)
+	if err != nil {
+		return nil, err
+	}
+	if err := controllerutil.SetControllerReference(policy, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, job); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, err
+	}
+	log.FromContext(ctx).Info("Started warm standby refresh", "pvc", backup.Labels["pvc"], "job", backup.Name, "standbyJob", job.Name)
+
+	now := metav1.Now()
+	return &backupv1alpha1.WarmStandbyStatus{
+		PVC:       backup.Labels["pvc"],
+		BackupJob: backup.Name,
+		JobName:   job.Name,
+		Phase:     "Running",
+		StartTime: &now,
+	}, nil
+}
+
+// desiredStandbyJob builds the Job extracting a succeeded backup into the
+// standby PVC, under a directory named after the source PVC
+func (r *BackupPolicyReconciler) desiredStandbyJob(policy *backupv1alpha1.BackupPolicy, backup *batchv1.Job) (*batchv1.Job, error) {
+	pvcName := backup.Labels["pvc"]
+	fileName, err := backupArchive(policy, backup)
+	if err != nil {
+		return nil, err
+	}
+
+	image := policy.Spec.BackupImage
+	if image == "" {
+		image = "busybox:latest"
+	}
+
+	command := fmt.Sprintf(standbyScript, shellQuote("/standby/"+pvcName), shellQuote("/backup/"+fileName))
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      standbyJobName(backup),
+			Namespace: policy.Namespace,
+			Labels: map[string]string{
+				standbyPolicyLabel: policy.Name,
+				"pvc":              pvcName,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: policy.Spec.ServiceAccountName,
+					SecurityContext:    podSecurityContext(policy),
+					ImagePullSecrets:   policy.Spec.ImagePullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:                     "standby",
+							Image:                    image,
+							ImagePullPolicy:          imagePullPolicy(policy, image),
+							SecurityContext:          containerSecurityContext(policy),
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+							Command:                  []string{"/bin/sh", "-c", command},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "backup",
+									MountPath: "/backup",
+									ReadOnly:  true,
+								},
+								{
+									Name:      "standby",
+									MountPath: "/standby",
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "backup",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: jobStoragePVC(backup),
+									ReadOnly:  true,
+								},
+							},
+						},
+						{
+							Name: "standby",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: policy.Spec.WarmStandby.PVC,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func standbyJobName(backup *batchv1.Job) string {
+	return backup.Name + "-standby"
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+	"github.com/robfig/cron/v3"
+)
+
+// windowEndAnnotation records the window end a backup Job's deadline was cut to
+const windowEndAnnotation = "backup.example.com/window-end"
+
+// errWindowNeverOpens is returned for a backup window whose start schedule never matches
+var errWindowNeverOpens = errors.New("backupWindow.start never matches a date")
+
+// backupWindowBounds returns when the backup window open at now closes, or,
+// while it is closed, when the next one opens. Exactly one of the two is set.
+func backupWindowBounds(window *backupv1alpha1.BackupWindow, now time.Time) (end, next time.Time, err error) {
+	schedule, err := cron.ParseStandard(window.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	// Windows opening within the last Duration are still open; with
+	// overlapping windows the latest one closes last. Next returns the zero
+	// time for a schedule that never matches, such as February 30th.
+	start := schedule.Next(now.Add(-window.Duration.Duration))
+	if start.IsZero() {
+		return time.Time{}, time.Time{}, errWindowNeverOpens
+	}
+	if start.After(now) {
+		return time.Time{}, schedule.Next(now), nil
+	}
+	for later := schedule.Next(start); !later.After(now); later = schedule.Next(later) {
+		if later.IsZero() {
+			return time.Time{}, time.Time{}, errWindowNeverOpens
+		}
+		start = later
+	}
+	return start.Add(window.Duration.Duration), time.Time{}, nil
+}
+
+// reconcileBackupWindow reports in the BackupWindow condition whether a due
+// scheduled run may start. While the window is closed the selected PVCs are
+// recorded in status.deferredPVCs and the time the next window opens is
+// returned; otherwise the result is zero.
+func (r *BackupPolicyReconciler) reconcileBackupWindow(ctx context.Context, policy *backupv1alpha1.BackupPolicy, pvcs []corev1.PersistentVolumeClaim, now time.Time) time.Time {
+	window := policy.Spec.BackupWindow
+	if window == nil {
+		policy.Status.DeferredPVCs = nil
+		meta.RemoveStatusCondition(&policy.Status.Conditions, "BackupWindow")
+		return time.Time{}
+	}
+
+	// The window was validated with the rest of the spec
+	end, next, _ := backupWindowBounds(window, now)
+	if next.IsZero() {
+		policy.Status.DeferredPVCs = nil
+		r.updateCondition(ctx, policy, "BackupWindow", metav1.ConditionTrue, "WithinWindow",
+			fmt.Sprintf("The last backup run started in the window closing at %s", end.UTC().Format(time.RFC3339)))
+		return time.Time{}
+	}
+
+	names := make([]string, 0, len(pvcs))
+	for _, pvc := range pvcs {
+		names = append(names, pvc.Name)
+	}
+
+	log.FromContext(ctx).Info("Backup window closed, deferring backups", "pvcs", names, "until", next)
+	policy.Status.DeferredPVCs = names
+	r.updateCondition(ctx, policy, "BackupWindow", metav1.ConditionFalse, "OutsideWindow",
+		fmt.Sprintf("%d backup(s) deferred to the window opening at %s: %s",
+			len(names), next.UTC().Format(time.RFC3339), strings.Join(names, ", ")))
+	return next
+}
+
+// windowJobDeadline returns the ActiveDeadlineSeconds for a backup Job started
+// at now. With cancelAtWindowEnd the deadline is cut to the end of the open
+// window, which is then returned so the Job can record it.
+func windowJobDeadline(policy *backupv1alpha1.BackupPolicy, now time.Time) (*int64, time.Time) {
+	deadline := policy.Spec.ActiveDeadlineSeconds
+	window := policy.Spec.BackupWindow
+	if window == nil || !window.CancelAtWindowEnd {
+		return deadline, time.Time{}
+	}
+
+	end, _, err := backupWindowBounds(window, now)
+	if err != nil || end.IsZero() {
+		return deadline, time.Time{}
+	}
+
+	remaining := int64(math.Ceil(end.Sub(now).Seconds()))
+	if remaining < 1 {
+		remaining = 1
+	}
+	if deadline != nil && *deadline <= remaining {
+		return deadline, time.Time{}
+	}
+	return &remaining, end
+}
@@ -3,17 +3,31 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/robfig/cron/v3"
 	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
@@ -21,12 +35,64 @@ import (
 
 const (
 	finalizerName = "backuppolicy.backup.example.com/finalizer"
+
+	// triggerAnnotation requests an immediate backup; each new value starts a new run
+	triggerAnnotation = "backup.example.com/trigger"
+
+	// triggeredLabel marks backup Jobs started by the trigger annotation
+	triggeredLabel = "backup.example.com/triggered"
+
+	// archiveAnnotation records the file name a backup Job writes its archive to
+	archiveAnnotation = "backup.example.com/archive"
+
+	// sizeAnnotation records the archive size reported by a finished backup Job
+	sizeAnnotation = "backup.example.com/size-bytes"
+
+	// sizeRetryInterval is how soon the size of a succeeded backup is read
+	// again while its pod's termination message isn't visible yet
+	sizeRetryInterval = 10 * time.Second
+
+	// storagePVCAnnotation on a source PVC overrides where its backups are stored
+	storagePVCAnnotation = "backup.example.com/storage-pvc"
+
+	// skipAnnotation set to "true" on a PVC opts it out of every policy that selects it
+	skipAnnotation = "backup.example.com/skip"
+
+	// cloudEventsAnnotation lists the CloudEvent types already delivered for a backup Job
+	cloudEventsAnnotation = "backup.example.com/cloudevents-sent"
+
+	// storageFullAnnotation records when a backup Job ran out of space on its storage PVC
+	storageFullAnnotation = "backup.example.com/storage-full-at"
+
+	// noSpaceMessage is the ENOSPC error text tar and gzip print when the storage PVC is full
+	noSpaceMessage = "No space left on device"
+
+	// storageFullBackoff is how long scheduled backups to a full storage PVC
+	// are paused before one is tried again
+	storageFullBackoff = time.Hour
+
+	// indexFile lists every archive on a backup storage PVC for restore tooling
+	indexFile = "/backup/index.json"
+
+	defaultFileNameTemplate = "{{.PVC}}-{{.Timestamp}}.tar.gz"
+
+	// backupTimestampFormat names each backup run
+	backupTimestampFormat = "20060102-150405"
+
+	// jobNameAttempts is how many random Job name suffixes are tried before
+	// giving up on creating a backup Job
+	jobNameAttempts = 3
 )
 
+// safeFileNamePattern matches a file name that cannot leave the backup
+// directory, be hidden or be mistaken for a command-line flag
+var safeFileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
 // BackupPolicyReconciler reconciles a BackupPolicy object
 type BackupPolicyReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=backup.example.com,resources=backuppolicies,verbs=get;list;watch;create;update;patch;delete
@@ -34,6 +100,9 @@ type BackupPolicyReconciler struct {
 // +kubebuilder:rbac:groups=backup.example.com,resources=backuppolicies/finalizers,verbs=update
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *BackupPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -57,17 +126,58 @@ func (r *BackupPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		}
 	}
 
+	// Validate spec
+	if err := validateSpec(policy); err != nil {
+		log.Error(err, "Invalid BackupPolicy spec")
+		r.updateCondition(ctx, policy, "Ready", metav1.ConditionFalse, "InvalidSpec", err.Error())
+		if err := r.Status().Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// In dry-run mode only report which PVCs would be backed up
+	if policy.Spec.DryRun {
+		return r.reconcileDryRun(ctx, policy)
+	}
+	meta.RemoveStatusCondition(&policy.Status.Conditions, "DryRun")
+	policy.Status.MatchedPVCs = nil
+
+	// Handle on-demand backups, which run even while scheduling is suspended
+	triggerRequeue, err := r.reconcileTrigger(ctx, policy)
+	if err != nil {
+		log.Error(err, "Failed to reconcile backup trigger")
+		return ctrl.Result{}, err
+	}
+
 	// Check if suspended
 	if policy.Spec.Suspend {
 		log.Info("Backup policy is suspended")
 		r.updateCondition(ctx, policy, "Suspended", metav1.ConditionTrue, "PolicySuspended", "Backup policy is suspended")
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: triggerRequeue}, nil
 	}
 
 	// Update backup history from existing jobs
-	if err := r.updateBackupHistory(ctx, policy); err != nil {
+	fullStorage, sizePending, err := r.updateBackupHistory(ctx, policy)
+	if err != nil {
 		log.Error(err, "Failed to update backup history")
 	}
+	if sizePending {
+		// Every path below requeues after triggerRequeue at the latest
+		triggerRequeue = earliest(triggerRequeue, sizeRetryInterval)
+	}
+	r.updateStorageFullCondition(ctx, policy, fullStorage)
+
+	// Keep the standby copies in step with the latest backups, coming back
+	// when minInterval lets a held-back backup be extracted
+	triggerRequeue = earliest(triggerRequeue, r.reconcileWarmStandby(ctx, policy))
+
+	// Report whether retention cleanup is frozen
+	if retentionEnabled(policy) {
+		r.updateCondition(ctx, policy, "RetentionFrozen", metav1.ConditionFalse, "RetentionEnabled", "Old backups are cleaned up according to retentionCount")
+	} else {
+		r.updateCondition(ctx, policy, "RetentionFrozen", metav1.ConditionTrue, "RetentionDisabled", "Automatic cleanup is disabled, no backups will be deleted")
+	}
 
 	// Check if it's time for a backup
 	nextSchedule, err := r.getNextScheduleTime(policy)
@@ -79,12 +189,25 @@ func (r *BackupPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request
 
 	now := time.Now()
 	if now.Before(nextSchedule) {
-		// Not time yet, requeue at next schedule time
-		requeueAfter := nextSchedule.Sub(now)
+		// Not time yet, persist refreshed history and requeue at next schedule time
+		if err := r.Status().Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+		requeueAfter := earliest(nextSchedule.Sub(now), triggerRequeue)
 		log.Info("Next backup scheduled", "after", requeueAfter)
 		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
+	// Hold the run back until minInterval has passed since the previous one
+	if wait := minIntervalRemaining(policy, now); wait > 0 {
+		log.Info("Deferring scheduled backup for minInterval", "after", wait)
+		r.updateMinIntervalCondition(ctx, policy, "Scheduled", wait)
+		if err := r.Status().Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
 	// Time to create a backup
 	log.Info("Creating backup jobs")
 
@@ -101,39 +224,127 @@ func (r *BackupPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request
 		r.updateCondition(ctx, policy, "Ready", metav1.ConditionTrue, "NoPVCs", "No PVCs found matching selector")
 		// Still requeue for next schedule
 		nextSchedule, _ = r.getNextScheduleTime(policy)
-		return ctrl.Result{RequeueAfter: time.Until(nextSchedule)}, nil
+		return ctrl.Result{RequeueAfter: earliest(time.Until(nextSchedule), triggerRequeue)}, nil
 	}
 
-	// Create backup jobs
+	// Leave the run for the next backup window once the current one has closed
+	if nextWindow := r.reconcileBackupWindow(ctx, policy, pvcs, now); !nextWindow.IsZero() {
+		if err := r.Status().Update(ctx, policy); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: earliest(time.Until(nextWindow), triggerRequeue)}, nil
+	}
+
+	// Pin backups of mounted ReadWriteOnce PVCs to their node
+	pvcs, nodes, err := r.reconcileMountedPVCs(ctx, policy, pvcs)
+	if err != nil {
+		log.Error(err, "Failed to look up pods mounting the PVCs")
+		return ctrl.Result{}, err
+	}
+
+	// Create backup jobs, holding back PVCs whose previous backup is still in
+	// progress and those whose storage PVC recently ran out of space
+	scheduled := 0
+	var inProgress []string
 	for _, pvc := range pvcs {
-		if err := r.createBackupJob(ctx, policy, &pvc); err != nil {
+		if backup := pvcBackupStatus(policy, pvc.Name); backup != nil && (backup.Phase == "Pending" || backup.Phase == "Running") {
+			log.Info("Skipping backup, the previous backup of this PVC is still in progress", "pvc", pvc.Name, "job", backup.JobName)
+			inProgress = append(inProgress, pvc.Name)
+			continue
+		}
+		if storagePVC, err := backupStoragePVC(policy, &pvc); err == nil {
+			if fullAt, ok := fullStorage[storagePVC]; ok && now.Before(fullAt.Add(storageFullBackoff)) {
+				log.Info("Skipping backup, storage PVC is full", "pvc", pvc.Name, "storagePVC", storagePVC,
+					"retryAfter", fullAt.Add(storageFullBackoff))
+				continue
+			}
+		}
+		if _, err := r.createBackupJob(ctx, policy, &pvc, nodes[pvc.Name], false); err != nil {
 			log.Error(err, "Failed to create backup job", "pvc", pvc.Name)
 			r.updateCondition(ctx, policy, "Ready", metav1.ConditionFalse, "JobCreationFailed", fmt.Sprintf("Failed to create backup job: %v", err))
 			return ctrl.Result{}, err
 		}
+		scheduled++
 	}
 
 	// Clean up old backups
-	if err := r.cleanupOldBackups(ctx, policy); err != nil {
-		log.Error(err, "Failed to cleanup old backups")
+	if retentionEnabled(policy) {
+		if err := r.cleanupOldBackups(ctx, policy); err != nil {
+			log.Error(err, "Failed to cleanup old backups")
+		}
+	} else {
+		log.Info("Retention is disabled, skipping cleanup of old backups")
 	}
 
 	// Update status
 	now = time.Now()
 	policy.Status.LastScheduleTime = &metav1.Time{Time: now}
-	r.updateCondition(ctx, policy, "Ready", metav1.ConditionTrue, "BackupScheduled", fmt.Sprintf("Scheduled %d backup job(s)", len(pvcs)))
+	r.clearMinIntervalCondition(ctx, policy)
+	message := fmt.Sprintf("Scheduled %d backup job(s)", scheduled)
+	if len(inProgress) > 0 {
+		message += fmt.Sprintf(", skipped %d PVC(s) still being backed up: %s", len(inProgress), strings.Join(inProgress, ", "))
+	}
+	r.updateCondition(ctx, policy, "Ready", metav1.ConditionTrue, "BackupScheduled", message)
 	if err := r.Status().Update(ctx, policy); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// Requeue for next schedule
 	nextSchedule, _ = r.getNextScheduleTime(policy)
-	requeueAfter := time.Until(nextSchedule)
+	requeueAfter := earliest(time.Until(nextSchedule), triggerRequeue)
 	log.Info("Backup jobs created, next backup scheduled", "after", requeueAfter)
 
 	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
+// reconcileDryRun records the PVCs the policy selects without creating any backup jobs
+func (r *BackupPolicyReconciler) reconcileDryRun(ctx context.Context, policy *backupv1alpha1.BackupPolicy) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	pvcs, err := r.findPVCsToBackup(ctx, policy)
+	if err != nil {
+		log.Error(err, "Failed to find PVCs")
+		r.updateCondition(ctx, policy, "Ready", metav1.ConditionFalse, "PVCLookupFailed", fmt.Sprintf("Failed to find PVCs: %v", err))
+		return ctrl.Result{}, err
+	}
+
+	names := make([]string, 0, len(pvcs))
+	for _, pvc := range pvcs {
+		names = append(names, pvc.Name)
+	}
+	sort.Strings(names)
+
+	log.Info("Dry run, skipping backup jobs", "pvcs", names)
+	policy.Status.MatchedPVCs = names
+	r.updateCondition(ctx, policy, "DryRun", metav1.ConditionTrue, "DryRunEnabled",
+		fmt.Sprintf("Dry run: %d PVC(s) would be backed up: %s", len(names), strings.Join(names, ", ")))
+	if err := r.Status().Update(ctx, policy); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// findDryRunPoliciesForPVC maps a PVC change to the dry-run policies in its
+// namespace, so their matchedPVCs follow PVCs being created, relabelled or
+// deleted. Other policies only read PVCs when a backup is due.
+func (r *BackupPolicyReconciler) findDryRunPoliciesForPVC(ctx context.Context, obj client.Object) []reconcile.Request {
+	policies := &backupv1alpha1.BackupPolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		if policy.Spec.DryRun {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: policy.Name, Namespace: policy.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
 func (r *BackupPolicyReconciler) handleDeletion(ctx context.Context, policy *backupv1alpha1.BackupPolicy) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
@@ -143,6 +354,18 @@ func (r *BackupPolicyReconciler) handleDeletion(ctx context.Context, policy *bac
 		if err := r.List(ctx, jobList, client.InNamespace(policy.Namespace),
 			client.MatchingLabels{"backup-policy": policy.Name}); err != nil {
 			log.Error(err, "Failed to list jobs for cleanup")
+		} else if !retentionEnabled(policy) {
+			// Orphan jobs so garbage collection doesn't delete them with the policy
+			for i := range jobList.Items {
+				job := &jobList.Items[i]
+				if err := controllerutil.RemoveControllerReference(policy, job, r.Scheme); err != nil {
+					continue
+				}
+				if err := r.Update(ctx, job); err != nil {
+					log.Error(err, "Failed to orphan job", "job", job.Name)
+					return ctrl.Result{}, err
+				}
+			}
 		} else {
 			for _, job := range jobList.Items {
 				if err := r.Delete(ctx, &job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
@@ -174,55 +397,209 @@ func (r *BackupPolicyReconciler) getNextScheduleTime(policy *backupv1alpha1.Back
 		lastSchedule = policy.CreationTimestamp.Time
 	}
 
-	return schedule.Next(lastSchedule), nil
+	next := schedule.Next(lastSchedule)
+	return next.Add(scheduleOffset(policy, next)), nil
+}
+
+// scheduleOffset spreads policies across the jitter window. Hashing the
+// policy's identity with the scheduled time gives each policy a different
+// offset per run that stays the same however often it is recomputed.
+func scheduleOffset(policy *backupv1alpha1.BackupPolicy, scheduled time.Time) time.Duration {
+	if policy.Spec.ScheduleJitter == nil || policy.Spec.ScheduleJitter.Duration <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s@%d", policy.Namespace, policy.Name, scheduled.Unix())
+	return time.Duration(h.Sum64() % uint64(policy.Spec.ScheduleJitter.Duration))
+}
+
+// minIntervalRemaining returns how long a backup run must still wait for
+// minInterval to pass since the last run, or zero if it may start at now
+func minIntervalRemaining(policy *backupv1alpha1.BackupPolicy, now time.Time) time.Duration {
+	if policy.Spec.MinInterval == nil || policy.Status.LastScheduleTime == nil {
+		return 0
+	}
+	if wait := policy.Status.LastScheduleTime.Add(policy.Spec.MinInterval.Duration).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// updateMinIntervalCondition reports a scheduled or triggered run deferred by minInterval
+func (r *BackupPolicyReconciler) updateMinIntervalCondition(ctx context.Context, policy *backupv1alpha1.BackupPolicy, run string, wait time.Duration) {
+	r.updateCondition(ctx, policy, "MinIntervalDeferred", metav1.ConditionTrue, "MinIntervalNotElapsed",
+		fmt.Sprintf("%s backup deferred until %s, minInterval %s after the last run",
+			run, time.Now().Add(wait).UTC().Format(time.RFC3339), policy.Spec.MinInterval.Duration))
+}
+
+// clearMinIntervalCondition reports that a backup run has started
+func (r *BackupPolicyReconciler) clearMinIntervalCondition(ctx context.Context, policy *backupv1alpha1.BackupPolicy) {
+	if policy.Spec.MinInterval == nil {
+		meta.RemoveStatusCondition(&policy.Status.Conditions, "MinIntervalDeferred")
+		return
+	}
+	r.updateCondition(ctx, policy, "MinIntervalDeferred", metav1.ConditionFalse, "BackupStarted", "The last backup run started without being deferred")
+}
+
+// earliest returns the shorter of two requeue delays, ignoring zero
+func earliest(a, b time.Duration) time.Duration {
+	if b > 0 && (a <= 0 || b < a) {
+		return b
+	}
+	return a
 }
 
+// findPVCsToBackup returns the PVCs selected by the policy, leaving out those
+// annotated to skip backups, which are recorded in status.skippedPVCs
 func (r *BackupPolicyReconciler) findPVCsToBackup(ctx context.Context, policy *backupv1alpha1.BackupPolicy) ([]corev1.PersistentVolumeClaim, error) {
-	selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PVCSelector)
+	pvcList := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, pvcList, client.InNamespace(policy.Namespace)); err != nil {
+		return nil, err
+	}
+
+	// An empty PVC selector matches everything, so only use it on its own
+	// or when it has been narrowed down alongside a StatefulSet selector
+	var pvcSelector labels.Selector
+	if policy.Spec.StatefulSetSelector == nil || !isEmptySelector(&policy.Spec.PVCSelector) {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PVCSelector)
+		if err != nil {
+			return nil, err
+		}
+		pvcSelector = selector
+	}
+
+	var prefixes []string
+	if policy.Spec.StatefulSetSelector != nil {
+		var err error
+		prefixes, err = r.statefulSetPVCPrefixes(ctx, policy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var pvcs []corev1.PersistentVolumeClaim
+	var skipped []string
+	for _, pvc := range pvcList.Items {
+		selected := pvcSelector != nil && pvcSelector.Matches(labels.Set(pvc.Labels))
+		for _, prefix := range prefixes {
+			if selected {
+				break
+			}
+			selected = strings.HasPrefix(pvc.Name, prefix) && isOrdinalSuffix(strings.TrimPrefix(pvc.Name, prefix))
+		}
+		if !selected {
+			continue
+		}
+
+		if pvc.Annotations[skipAnnotation] == "true" {
+			skipped = append(skipped, pvc.Name)
+			continue
+		}
+		pvcs = append(pvcs, pvc)
+	}
+
+	policy.Status.SkippedPVCs = skipped
+	return pvcs, nil
+}
+
+// statefulSetPVCPrefixes returns "<template>-<statefulset>-" for every
+// volumeClaimTemplate of the StatefulSets matched by the policy. The
+// StatefulSet controller names its PVCs with that prefix plus the pod ordinal.
+func (r *BackupPolicyReconciler) statefulSetPVCPrefixes(ctx context.Context, policy *backupv1alpha1.BackupPolicy) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(policy.Spec.StatefulSetSelector)
 	if err != nil {
 		return nil, err
 	}
 
-	pvcList := &corev1.PersistentVolumeClaimList{}
-	if err := r.List(ctx, pvcList, client.InNamespace(policy.Namespace),
+	stsList := &appsv1.StatefulSetList{}
+	if err := r.List(ctx, stsList, client.InNamespace(policy.Namespace),
 		client.MatchingLabelsSelector{Selector: selector}); err != nil {
 		return nil, err
 	}
 
-	return pvcList.Items, nil
+	var prefixes []string
+	for _, sts := range stsList.Items {
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			prefixes = append(prefixes, fmt.Sprintf("%s-%s-", template.Name, sts.Name))
+		}
+	}
+	return prefixes, nil
+}
+
+func isEmptySelector(selector *metav1.LabelSelector) bool {
+	return len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0
 }
 
-func (r *BackupPolicyReconciler) createBackupJob(ctx context.Context, policy *backupv1alpha1.BackupPolicy, pvc *corev1.PersistentVolumeClaim) error {
-	timestamp := time.Now().Format("20060102-150405")
-	jobName := fmt.Sprintf("backup-%s-%s", pvc.Name, timestamp)
+// isOrdinalSuffix reports whether s is a non-empty string of digits
+func isOrdinalSuffix(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// createBackupJob starts a backup Job for the PVC. A non-empty node pins the
+// Job to the node the PVC is attached to. Triggered Jobs are named and
+// labelled apart from scheduled ones.
+func (r *BackupPolicyReconciler) createBackupJob(ctx context.Context, policy *backupv1alpha1.BackupPolicy, pvc *corev1.PersistentVolumeClaim, node string, triggered bool) (string, error) {
+	timestamp := time.Now().Format(backupTimestampFormat)
 
 	backupImage := policy.Spec.BackupImage
 	if backupImage == "" {
 		backupImage = "busybox:latest"
 	}
 
+	storagePVC, err := backupStoragePVC(policy, pvc)
+	if err != nil {
+		return "", err
+	}
+
+	fileName, err := backupFileName(policy, pvc.Name, timestamp)
+	if err != nil {
+		return "", err
+	}
+	command := r.getBackupCommand(policy, pvc, fileName)
+
+	deadline, windowEnd := windowJobDeadline(policy, time.Now())
+
 	job := &batchv1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      jobName,
 			Namespace: policy.Namespace,
 			Labels: map[string]string{
 				"backup-policy": policy.Name,
 				"pvc":           pvc.Name,
 				"timestamp":     timestamp,
 			},
+			Annotations: map[string]string{archiveAnnotation: fileName},
 		},
 		Spec: batchv1.JobSpec{
+			ActiveDeadlineSeconds: deadline,
+			PodFailurePolicy:      spaceCheckFailurePolicy(policy),
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
-					RestartPolicy: corev1.RestartPolicyNever,
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: policy.Spec.ServiceAccountName,
+					SecurityContext:    podSecurityContext(policy),
+					ImagePullSecrets:   policy.Spec.ImagePullSecrets,
+					Affinity:           nodeAffinity(node),
 					Containers: []corev1.Container{
 						{
-							Name:  "backup",
-							Image: backupImage,
+							Name:            "backup",
+							Image:           backupImage,
+							ImagePullPolicy: imagePullPolicy(policy, backupImage),
+							SecurityContext: containerSecurityContext(policy),
+							// Surface the error output of a failed backup, such as ENOSPC
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
 							Command: []string{
 								"/bin/sh",
 								"-c",
-								r.getBackupCommand(policy, pvc, timestamp),
+								command,
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -251,7 +628,7 @@ func (r *BackupPolicyReconciler) createBackupJob(ctx context.Context, policy *ba
 							Name: "backup",
 							VolumeSource: corev1.VolumeSource{
 								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: policy.Spec.BackupStoragePVC,
+									ClaimName: storagePVC,
 								},
 							},
 						},
@@ -261,42 +638,536 @@ func (r *BackupPolicyReconciler) createBackupJob(ctx context.Context, policy *ba
 		},
 	}
 
+	if !windowEnd.IsZero() {
+		job.Annotations[windowEndAnnotation] = windowEnd.UTC().Format(time.RFC3339)
+	}
+
+	prefix := "backup"
+	if triggered {
+		prefix = "backup-triggered"
+		job.Labels[triggeredLabel] = "true"
+	}
+
 	// Set owner reference
 	if err := controllerutil.SetControllerReference(policy, job, r.Scheme); err != nil {
-		return err
+		return "", err
 	}
 
-	return r.Create(ctx, job)
+	// Two runs for the same PVC within one second share a timestamp, so a
+	// random suffix keeps their names apart; on a collision try a new one
+	for attempt := 0; attempt < jobNameAttempts; attempt++ {
+		job.Name = fmt.Sprintf("%s-%s-%s-%s", prefix, pvc.Name, timestamp, utilrand.String(5))
+		if err = r.Create(ctx, job); !errors.IsAlreadyExists(err) {
+			break
+		}
+		log.FromContext(ctx).Info("Backup job name already taken, retrying with a new name", "job", job.Name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return job.Name, nil
 }
 
-func (r *BackupPolicyReconciler) getBackupCommand(policy *backupv1alpha1.BackupPolicy, pvc *corev1.PersistentVolumeClaim, timestamp string) string {
-	backupFile := fmt.Sprintf("/backup/%s-%s.tar.gz", pvc.Name, timestamp)
+// podSecurityContext returns the policy's pod security context, or a
+// restricted non-root default that satisfies Pod Security Standards
+func podSecurityContext(policy *backupv1alpha1.BackupPolicy) *corev1.PodSecurityContext {
+	if policy.Spec.PodSecurityContext != nil {
+		return policy.Spec.PodSecurityContext.DeepCopy()
+	}
+
+	// fsGroup matches runAsGroup so the backup user can write to the
+	// storage PVC; ownership is only changed when the volume root differs
+	runAsNonRoot := true
+	nobody := int64(65534)
+	onRootMismatch := corev1.FSGroupChangeOnRootMismatch
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot:        &runAsNonRoot,
+		RunAsUser:           &nobody,
+		RunAsGroup:          &nobody,
+		FSGroup:             &nobody,
+		FSGroupChangePolicy: &onRootMismatch,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// containerSecurityContext returns the policy's container security context,
+// or a default that drops all capabilities and blocks privilege escalation
+func containerSecurityContext(policy *backupv1alpha1.BackupPolicy) *corev1.SecurityContext {
+	if policy.Spec.SecurityContext != nil {
+		return policy.Spec.SecurityContext.DeepCopy()
+	}
+
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// imagePullPolicy returns the policy's pull policy for image or, like
+// Kubernetes, Always for the latest tag or no tag and IfNotPresent otherwise
+func imagePullPolicy(policy *backupv1alpha1.BackupPolicy, image string) corev1.PullPolicy {
+	if policy.Spec.ImagePullPolicy != "" {
+		return policy.Spec.ImagePullPolicy
+	}
+	if strings.Contains(image, "@") {
+		return corev1.PullIfNotPresent
+	}
+	// A colon after the last slash separates the tag, not a registry port
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i < 0 || name[i+1:] == "latest" {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
+
+// backupStoragePVC picks the storage PVC for a source PVC: its annotation,
+// then the first matching storage route, then the policy default
+func backupStoragePVC(policy *backupv1alpha1.BackupPolicy, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	if name := pvc.Annotations[storagePVCAnnotation]; name != "" {
+		return name, nil
+	}
+
+	for i := range policy.Spec.StorageRoutes {
+		route := &policy.Spec.StorageRoutes[i]
+		selector, err := metav1.LabelSelectorAsSelector(&route.Selector)
+		if err != nil {
+			return "", fmt.Errorf("invalid selector in storage route %d: %w", i, err)
+		}
+		if selector.Matches(labels.Set(pvc.Labels)) {
+			return route.BackupStoragePVC, nil
+		}
+	}
+
+	return policy.Spec.BackupStoragePVC, nil
+}
+
+// reconcileTrigger starts a backup of every selected PVC when the trigger
+// annotation carries a new token, then tracks the resulting jobs in
+// status.trigger so callers can poll for completion. It returns how long
+// until a trigger deferred by minInterval may start.
+func (r *BackupPolicyReconciler) reconcileTrigger(ctx context.Context, policy *backupv1alpha1.BackupPolicy) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	token := policy.Annotations[triggerAnnotation]
+	if token == "" {
+		return 0, nil
+	}
+
+	trigger := policy.Status.Trigger
+	if trigger != nil && trigger.Token == token && trigger.Phase != "Pending" {
+		if trigger.Phase != "Running" {
+			return 0, nil
+		}
+		if !r.refreshTrigger(ctx, policy, trigger) {
+			return 0, nil
+		}
+		return 0, r.Status().Update(ctx, policy)
+	}
+
+	// Hold the run back until minInterval has passed since the previous one
+	if wait := minIntervalRemaining(policy, time.Now()); wait > 0 {
+		if trigger != nil && trigger.Token == token {
+			return wait, nil
+		}
+		log.Info("Deferring triggered backup for minInterval", "token", token, "after", wait)
+		policy.Status.Trigger = &backupv1alpha1.TriggerStatus{
+			Token:   token,
+			Phase:   "Pending",
+			Message: fmt.Sprintf("Deferred by minInterval until %s", time.Now().Add(wait).UTC().Format(time.RFC3339)),
+		}
+		r.updateMinIntervalCondition(ctx, policy, "Triggered", wait)
+		return wait, r.Status().Update(ctx, policy)
+	}
+
+	// New or deferred token, start the backup
+	log.Info("Starting triggered backup", "token", token)
+	now := metav1.Now()
+	trigger = &backupv1alpha1.TriggerStatus{
+		Token:     token,
+		Phase:     "Running",
+		StartTime: &now,
+	}
+	policy.Status.Trigger = trigger
+	policy.Status.LastScheduleTime = &now
+	r.clearMinIntervalCondition(ctx, policy)
+
+	pvcs, err := r.findPVCsToBackup(ctx, policy)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(pvcs) == 0 {
+		trigger.Phase = "Failed"
+		trigger.CompletionTime = &now
+		trigger.Message = "No PVCs found matching selector"
+		return 0, r.Status().Update(ctx, policy)
+	}
+
+	pvcs, nodes, err := r.reconcileMountedPVCs(ctx, policy, pvcs)
+	if err != nil {
+		return 0, err
+	}
+	if len(pvcs) == 0 {
+		trigger.Phase = "Failed"
+		trigger.CompletionTime = &now
+		trigger.Message = "Every selected PVC is a ReadWriteOncePod PVC mounted by a running pod"
+		return 0, r.Status().Update(ctx, policy)
+	}
+
+	for _, pvc := range pvcs {
+		jobName, err := r.createBackupJob(ctx, policy, &pvc, nodes[pvc.Name], true)
+		if err != nil {
+			trigger.Phase = "Failed"
+			trigger.CompletionTime = &now
+			trigger.Message = fmt.Sprintf("Failed to create backup job for PVC %s: %v", pvc.Name, err)
+			return 0, r.Status().Update(ctx, policy)
+		}
+		trigger.JobNames = append(trigger.JobNames, jobName)
+	}
+
+	trigger.Message = fmt.Sprintf("Started %d backup job(s)", len(trigger.JobNames))
+	if skipped := len(policy.Status.MountedPVCs) - len(nodes); skipped > 0 {
+		trigger.Message += fmt.Sprintf(", skipped %d mounted ReadWriteOncePod PVC(s)", skipped)
+	}
+	return 0, r.Status().Update(ctx, policy)
+}
+
+// refreshTrigger updates a running trigger from its jobs, reporting whether anything changed
+func (r *BackupPolicyReconciler) refreshTrigger(ctx context.Context, policy *backupv1alpha1.BackupPolicy, trigger *backupv1alpha1.TriggerStatus) bool {
+	succeeded := 0
+	var completionTime *metav1.Time
+	for _, jobName := range trigger.JobNames {
+		job := &batchv1.Job{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: policy.Namespace, Name: jobName}, job); err != nil {
+			if errors.IsNotFound(err) {
+				trigger.Phase = "Failed"
+				trigger.Message = fmt.Sprintf("Backup job %s no longer exists", jobName)
+				now := metav1.Now()
+				trigger.CompletionTime = &now
+				return true
+			}
+			return false
+		}
+
+		// A failed pod is retried until backoffLimit, so only a failed Job counts
+		if jobFailed(job) {
+			trigger.Phase = "Failed"
+			trigger.Message = fmt.Sprintf("Backup job %s failed", jobName)
+			trigger.CompletionTime = job.Status.CompletionTime
+			if trigger.CompletionTime == nil {
+				now := metav1.Now()
+				trigger.CompletionTime = &now
+			}
+			return true
+		}
+
+		if job.Status.Succeeded > 0 {
+			succeeded++
+			if completionTime == nil || (job.Status.CompletionTime != nil && job.Status.CompletionTime.After(completionTime.Time)) {
+				completionTime = job.Status.CompletionTime
+			}
+		}
+	}
+
+	if succeeded < len(trigger.JobNames) {
+		return false
+	}
+
+	trigger.Phase = "Succeeded"
+	trigger.Message = fmt.Sprintf("All %d backup job(s) succeeded", succeeded)
+	trigger.CompletionTime = completionTime
+	return true
+}
+
+func (r *BackupPolicyReconciler) getBackupCommand(policy *backupv1alpha1.BackupPolicy, pvc *corev1.PersistentVolumeClaim, fileName string) string {
+	backupFile := "/backup/" + fileName
 
 	switch policy.Spec.BackupStrategy {
 	case "tar":
-		return fmt.Sprintf("tar czf %s -C /data . && echo 'Backup completed: %s'", backupFile, backupFile)
+		return tarBackupCommand(policy, pvc.Name, backupFile)
 	case "snapshot":
 		return "echo 'Snapshot strategy not implemented' && exit 1"
 	case "custom":
 		return "echo 'Custom backup strategy not implemented' && exit 1"
 	default:
-		return fmt.Sprintf("tar czf %s -C /data . && echo 'Backup completed: %s'", backupFile, backupFile)
+		return tarBackupCommand(policy, pvc.Name, backupFile)
 	}
 }
 
-func (r *BackupPolicyReconciler) updateBackupHistory(ctx context.Context, policy *backupv1alpha1.BackupPolicy) error {
-	// List jobs for this policy
+// backupFileData is the data FileNameTemplate is rendered with
+type backupFileData struct {
+	PVC        string
+	Timestamp  string
+	Namespace  string
+	PolicyName string
+}
+
+// backupFileName renders the policy's file name template for one backup and
+// rejects results that are not a plain file name on the storage volume
+func backupFileName(policy *backupv1alpha1.BackupPolicy, pvcName, timestamp string) (string, error) {
+	text := policy.Spec.FileNameTemplate
+	if text == "" {
+		text = defaultFileNameTemplate
+	}
+
+	tmpl, err := template.New("fileName").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid fileNameTemplate: %w", err)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, backupFileData{
+		PVC:        pvcName,
+		Timestamp:  timestamp,
+		Namespace:  policy.Namespace,
+		PolicyName: policy.Name,
+	}); err != nil {
+		return "", fmt.Errorf("invalid fileNameTemplate: %w", err)
+	}
+
+	name := b.String()
+	if len(name) > 255 || !safeFileNamePattern.MatchString(name) {
+		return "", fmt.Errorf("fileNameTemplate rendered %q, which is not a plain file name of letters, digits, '.', '_' and '-'", name)
+	}
+	// Entry files and the index itself live next to the archives
+	if strings.HasSuffix(name, ".json") {
+		return "", fmt.Errorf("fileNameTemplate rendered %q; names ending in .json are reserved for the backup index", name)
+	}
+	return name, nil
+}
+
+// backupArchive returns the file name of a backup Job's archive. Jobs
+// created before the name was recorded have it rendered again from the
+// policy's current fileNameTemplate.
+func backupArchive(policy *backupv1alpha1.BackupPolicy, job *batchv1.Job) (string, error) {
+	if fileName := job.Annotations[archiveAnnotation]; fileName != "" {
+		return fileName, nil
+	}
+	return backupFileName(policy, job.Labels["pvc"], job.Labels["timestamp"])
+}
+
+// indexScript records an archive in the storage index. Each archive gets its
+// own entry file next to it, and index.json is rebuilt from the entries whose
+// archive still exists. Both are written to a temporary file and renamed into
+// place, so readers never see a partial index. A mkdir lock keeps concurrent
+// jobs on the same storage from racing. A job that can't take the lock within
+// a minute fails instead of writing anyway, and only the job that took the
+// lock removes it.
+const indexScript = `(set -e
+file=%[2]s
+sum=$(sha256sum "$file" | cut -d' ' -f1)
+size=$(wc -c < "$file")
+printf '{"pvc":"%%s","file":"%%s","timestamp":"%%s","sizeBytes":%%s,"sha256":"%%s"}\n' \
+  %[1]s "${file##*/}" "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)" "$size" "$sum" > "$file.json.tmp"
+mv "$file.json.tmp" "$file.json"
+i=0
+until mkdir /backup/.index.lock 2>/dev/null; do
+  i=$((i+1))
+  if [ $i -ge 60 ]; then echo "Timed out waiting for /backup/.index.lock" >&2; exit 1; fi
+  sleep 1
+done
+trap 'rmdir /backup/.index.lock 2>/dev/null' EXIT
+{
+  echo '['; sep=''
+  for entry in /backup/*.json; do
+    [ "$entry" != %[3]s ] && [ -f "${entry%%.json}" ] || continue
+    printf '%%s' "$sep"; cat "$entry"; sep=','
+  done
+  echo ']'
+} > /backup/.index.json.$$
+mv /backup/.index.json.$$ %[3]s)`
+
+// getIndexCommand adds backupFile to the storage index with its size and checksum
+func getIndexCommand(pvcName, backupFile string) string {
+	return indexCommand(pvcName, shellQuote(backupFile))
+}
+
+// indexCommand renders indexScript for fileWord, a shell word that expands
+// to the archive path, such as a quoted path or a loop variable
+func indexCommand(pvcName, fileWord string) string {
+	return fmt.Sprintf(indexScript, shellQuote(pvcName), fileWord, indexFile)
+}
+
+// tarBackupCommand archives the PVC into backupFile, or into split parts
+// next to it, and records the result in the storage index
+func tarBackupCommand(policy *backupv1alpha1.BackupPolicy, pvcName, backupFile string) string {
+	if policy.Spec.Split == nil {
+		return spaceCheckCommand(policy) + getTarCommand(policy, backupFile) + " && " + getIndexCommand(pvcName, backupFile)
+	}
+	return spaceCheckCommand(policy) + getSplitTarCommand(policy, backupFile) + " && " +
+		fmt.Sprintf(`for part in %s.part-*; do case "$part" in *.json) continue;; esac; %s; done`,
+			shellQuote(backupFile), indexCommand(pvcName, `"$part"`))
+}
+
+// getTarCommand archives /data into backupFile, skipping excluded paths. The
+// archive size is written to the termination log so the controller can record it.
+func getTarCommand(policy *backupv1alpha1.BackupPolicy, backupFile string) string {
+	return fmt.Sprintf("tar czf %s%s -C /data . && wc -c < %s > /dev/termination-log && echo 'Backup completed: %s'",
+		backupFile, tarExcludes(policy), backupFile, backupFile)
+}
+
+// tarExcludes returns the tar --exclude options for the policy's ExcludePaths
+func tarExcludes(policy *backupv1alpha1.BackupPolicy) string {
+	var excludes strings.Builder
+	for _, path := range policy.Spec.ExcludePaths {
+		path = "./" + strings.TrimPrefix(strings.TrimPrefix(path, "/"), "./")
+		excludes.WriteString(" --exclude=" + shellQuote(path))
+	}
+	return excludes.String()
+}
+
+// splitTarScript writes one archive per top-level directory of /data, named
+// <archive>.part-NNN, plus one more for the files at the top level. Up to
+// the given number of tar processes run at once. <archive>.parts maps each
+// part to the directory it holds, so a single directory can be restored. The
+// combined size of the parts goes to the termination log.
+const splitTarScript = `(set -e
+file=%[1]s
+cd /data
+n=0; running=0; pids=''
+: > "$file.parts.tmp"
+set --
+for entry in ./* ./.[!.]* ./..?*; do
+  [ -e "$entry" ] || [ -L "$entry" ] || continue
+  if [ -d "$entry" ] && [ ! -L "$entry" ]; then
+    part=$(printf '%%s.part-%%03d' "$file" $n); n=$((n+1))
+    printf '%%s	%%s
+' "${part##*/}" "${entry#./}" >> "$file.parts.tmp"
+    tar czf "$part"%[2]s "$entry" &
+    pids="$pids $!"; running=$((running+1))
+    if [ $running -ge %[3]d ]; then
+      for pid in $pids; do wait $pid; done
+      pids=''; running=0
+    fi
+  else
+    set -- "$@" "$entry"
+  fi
+done
+[ $n -gt 0 ] || [ $# -gt 0 ] || set -- .
+if [ $# -gt 0 ]; then
+  part=$(printf '%%s.part-%%03d' "$file" $n)
+  printf '%%s	.
+' "${part##*/}" >> "$file.parts.tmp"
+  tar czf "$part"%[2]s "$@" &
+  pids="$pids $!"
+fi
+for pid in $pids; do wait $pid; done
+mv "$file.parts.tmp" "$file.parts"
+cat "$file".part-* | wc -c > /dev/termination-log
+echo "Backup completed: $file in $(wc -l < "$file.parts") parts")`
+
+// getSplitTarCommand archives /data into parallel split parts of backupFile
+func getSplitTarCommand(policy *backupv1alpha1.BackupPolicy, backupFile string) string {
+	parallelism := policy.Spec.Split.Parallelism
+	if parallelism < 1 {
+		parallelism = 4
+	}
+	return fmt.Sprintf(splitTarScript, shellQuote(backupFile), tarExcludes(policy), parallelism)
+}
+
+// shellQuote wraps value in single quotes for safe use in a shell command
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// validateSpec rejects field values the CRD schema cannot express
+func validateSpec(policy *backupv1alpha1.BackupPolicy) error {
+	if policy.Spec.ScheduleJitter != nil && policy.Spec.ScheduleJitter.Duration < 0 {
+		return fmt.Errorf("scheduleJitter must not be negative")
+	}
+	if policy.Spec.MinInterval != nil && policy.Spec.MinInterval.Duration < 0 {
+		return fmt.Errorf("minInterval must not be negative")
+	}
+	if window := policy.Spec.BackupWindow; window != nil {
+		schedule, err := cron.ParseStandard(window.Start)
+		if err != nil {
+			return fmt.Errorf("invalid backupWindow.start: %w", err)
+		}
+		if schedule.Next(time.Now()).IsZero() {
+			return errWindowNeverOpens
+		}
+		if window.Duration.Duration <= 0 {
+			return fmt.Errorf("backupWindow.duration must be positive")
+		}
+	}
+	if standby := policy.Spec.WarmStandby; standby != nil && standby.MinInterval != nil && standby.MinInterval.Duration < 0 {
+		return fmt.Errorf("warmStandby.minInterval must not be negative")
+	}
+
+	for _, path := range policy.Spec.ExcludePaths {
+		if strings.TrimSpace(path) == "" {
+			return fmt.Errorf("excludePaths must not contain empty paths")
+		}
+		if strings.HasPrefix(path, "/backup") {
+			return fmt.Errorf("exclude path %q must be relative to /data, not the backup volume", path)
+		}
+		for _, segment := range strings.Split(path, "/") {
+			if segment == ".." {
+				return fmt.Errorf("exclude path %q must not leave /data", path)
+			}
+		}
+	}
+
+	// Render with two timestamps so a template that would overwrite the previous backup is caught early
+	first, err := backupFileName(policy, "data-0", "20060102-150405")
+	if err != nil {
+		return err
+	}
+	second, err := backupFileName(policy, "data-0", "20060102-150406")
+	if err != nil {
+		return err
+	}
+	if first == second {
+		return fmt.Errorf("fileNameTemplate must include {{.Timestamp}} so backups don't overwrite each other")
+	}
+
+	for i := range policy.Spec.StorageRoutes {
+		if _, err := metav1.LabelSelectorAsSelector(&policy.Spec.StorageRoutes[i].Selector); err != nil {
+			return fmt.Errorf("invalid selector in storage route %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// updateBackupHistory refreshes the policy's backup history from its Jobs. It
+// returns the storage PVCs whose most recent finished backup ran out of space,
+// with the time the failure happened.
+func (r *BackupPolicyReconciler) updateBackupHistory(ctx context.Context, policy *backupv1alpha1.BackupPolicy) (map[string]time.Time, bool, error) {
+	// List jobs for this policy. Only backup Jobs carry the backup-policy
+	// label; hook and standby Jobs have their own, so they never count as backups.
 	jobList := &batchv1.JobList{}
 	if err := r.List(ctx, jobList, client.InNamespace(policy.Namespace),
 		client.MatchingLabels{"backup-policy": policy.Name}); err != nil {
-		return err
+		return nil, false, err
+	}
+
+	type finishedBackup struct {
+		startTime time.Time
+		fullAt    *time.Time
+	}
+	latestFinished := map[string]finishedBackup{}
+
+	type pvcBackup struct {
+		created time.Time
+		status  backupv1alpha1.PVCBackupStatus
 	}
+	pvcBackups := map[string]*pvcBackup{}
 
 	var history []backupv1alpha1.BackupRecord
-	for _, job := range jobList.Items {
+	var totalSize int64
+	sizePending := false
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
 		record := backupv1alpha1.BackupRecord{
 			JobName: job.Name,
 		}
+		var fullAt *time.Time
 
 		if job.Status.StartTime != nil {
 			record.StartTime = *job.Status.StartTime
@@ -305,23 +1176,88 @@ func (r *BackupPolicyReconciler) updateBackupHistory(ctx context.Context, policy
 		if job.Status.Succeeded > 0 {
 			record.Status = "Succeeded"
 			record.CompletionTime = job.Status.CompletionTime
-			// Update last successful time
-			if policy.Status.LastSuccessfulTime == nil ||
-				(job.Status.CompletionTime != nil && job.Status.CompletionTime.After(policy.Status.LastSuccessfulTime.Time)) {
+			var pending bool
+			record.SizeBytes, pending = r.getBackupSize(ctx, job)
+			sizePending = sizePending || pending
+			totalSize += record.SizeBytes
+			if hook := policy.Spec.PostBackupHook; hook != nil {
+				record.HookStatus = r.reconcilePostBackupHook(ctx, policy, job, record.SizeBytes)
+				if hook.BlockCompletion && record.HookStatus == "Failed" {
+					record.Status = "Failed"
+					record.Message = "Post-backup hook failed"
+				} else if hook.BlockCompletion && record.HookStatus != "Succeeded" {
+					record.Status = "Running"
+					record.CompletionTime = nil
+					record.Message = "Waiting for the post-backup hook"
+				}
+			}
+			// Update last successful time once the backup, and any blocking hook, succeeded
+			if record.Status == "Succeeded" && (policy.Status.LastSuccessfulTime == nil ||
+				(job.Status.CompletionTime != nil && job.Status.CompletionTime.After(policy.Status.LastSuccessfulTime.Time))) {
 				policy.Status.LastSuccessfulTime = job.Status.CompletionTime
+				policy.Status.LastBackupSize = record.SizeBytes
+			} else if record.Status == "Succeeded" && policy.Status.LastBackupSize == 0 &&
+				job.Status.CompletionTime != nil && job.Status.CompletionTime.Equal(policy.Status.LastSuccessfulTime) {
+				// The size of the latest backup was read after its success was recorded
+				policy.Status.LastBackupSize = record.SizeBytes
 			}
-		} else if job.Status.Failed > 0 {
+		} else if job.Status.Failed > 0 || jobFailed(job) {
 			record.Status = "Failed"
-			record.Message = "Backup job failed"
+			record.Message = jobFailureMessage(job)
+			if insufficientSpace(job) {
+				record.Reason = reasonInsufficientSpace
+				record.Message = fmt.Sprintf("Backup storage PVC %s has less free space than the source uses", jobStoragePVC(job))
+			} else if fullAt = r.storageFullTime(ctx, job); fullAt != nil {
+				record.Message = fmt.Sprintf("Backup storage PVC %s is full", jobStoragePVC(job))
+			}
 		} else if job.Status.Active > 0 {
 			record.Status = "Running"
 		} else {
 			record.Status = "Pending"
 		}
 
+		if policy.Spec.CloudEventsSink != "" {
+			r.publishTransitions(ctx, policy, job, &record)
+		}
+
+		if record.Status == "Succeeded" || record.Status == "Failed" {
+			storagePVC := jobStoragePVC(job)
+			if latest, ok := latestFinished[storagePVC]; !ok || record.StartTime.After(latest.startTime) {
+				latestFinished[storagePVC] = finishedBackup{startTime: record.StartTime.Time, fullAt: fullAt}
+			}
+		}
+
+		// Track each source PVC's backups on their own
+		pvcName := job.Labels["pvc"]
+		backup, ok := pvcBackups[pvcName]
+		if !ok {
+			backup = &pvcBackup{status: backupv1alpha1.PVCBackupStatus{PVC: pvcName}}
+			pvcBackups[pvcName] = backup
+		}
+		if backup.status.JobName == "" || job.CreationTimestamp.After(backup.created) {
+			backup.created = job.CreationTimestamp.Time
+			backup.status.JobName = job.Name
+			backup.status.Phase = record.Status
+			backup.status.StartTime = job.Status.StartTime
+			backup.status.CompletionTime = record.CompletionTime
+		}
+		if record.Status == "Succeeded" && record.CompletionTime != nil && (backup.status.LastSuccessfulTime == nil ||
+			record.CompletionTime.After(backup.status.LastSuccessfulTime.Time)) {
+			backup.status.LastSuccessfulTime = record.CompletionTime
+		}
+
 		history = append(history, record)
 	}
 
+	var pvcStatuses []backupv1alpha1.PVCBackupStatus
+	for _, backup := range pvcBackups {
+		pvcStatuses = append(pvcStatuses, backup.status)
+	}
+	sort.Slice(pvcStatuses, func(i, j int) bool {
+		return pvcStatuses[i].PVC < pvcStatuses[j].PVC
+	})
+	policy.Status.PVCBackups = pvcStatuses
+
 	// Sort by start time, most recent first
 	sort.Slice(history, func(i, j int) bool {
 		return history[i].StartTime.After(history[j].StartTime.Time)
@@ -333,9 +1269,176 @@ func (r *BackupPolicyReconciler) updateBackupHistory(ctx context.Context, policy
 	}
 
 	policy.Status.BackupHistory = history
+	policy.Status.TotalStorageUsed = totalSize
+
+	fullStorage := map[string]time.Time{}
+	for storagePVC, latest := range latestFinished {
+		if latest.fullAt != nil {
+			fullStorage[storagePVC] = *latest.fullAt
+		}
+	}
+	return fullStorage, sizePending, nil
+}
+
+// storageFullTime returns when a failed backup Job ran out of space on its
+// storage PVC, or nil if it failed for another reason. The backup container's
+// termination message falls back to its log output, where tar and gzip report
+// ENOSPC. The result is cached on the Job so it survives pod cleanup.
+func (r *BackupPolicyReconciler) storageFullTime(ctx context.Context, job *batchv1.Job) *time.Time {
+	log := log.FromContext(ctx)
+
+	if value, ok := job.Annotations[storageFullAnnotation]; ok {
+		if fullAt, err := time.Parse(time.RFC3339, value); err == nil {
+			return &fullAt
+		}
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name}); err != nil {
+		log.Error(err, "Failed to list pods for backup job", "job", job.Name)
+		return nil
+	}
+
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if status.Name != "backup" || terminated == nil || terminated.ExitCode == 0 ||
+				!strings.Contains(terminated.Message, noSpaceMessage) {
+				continue
+			}
+
+			fullAt := terminated.FinishedAt.Time
+			patch := client.MergeFrom(job.DeepCopy())
+			if job.Annotations == nil {
+				job.Annotations = map[string]string{}
+			}
+			job.Annotations[storageFullAnnotation] = fullAt.UTC().Format(time.RFC3339)
+			if err := r.Patch(ctx, job, patch); err != nil {
+				log.Error(err, "Failed to record full storage on job", "job", job.Name)
+			}
+			return &fullAt
+		}
+	}
+
+	return nil
+}
+
+// pvcBackupStatus returns the recorded backup status of a source PVC, if any
+func pvcBackupStatus(policy *backupv1alpha1.BackupPolicy, pvcName string) *backupv1alpha1.PVCBackupStatus {
+	for i := range policy.Status.PVCBackups {
+		if policy.Status.PVCBackups[i].PVC == pvcName {
+			return &policy.Status.PVCBackups[i]
+		}
+	}
 	return nil
 }
 
+// jobStoragePVC returns the storage PVC a backup Job writes to
+func jobStoragePVC(job *batchv1.Job) string {
+	for _, volume := range job.Spec.Template.Spec.Volumes {
+		if volume.Name == "backup" && volume.PersistentVolumeClaim != nil {
+			return volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	return ""
+}
+
+// updateStorageFullCondition reports storage PVCs that ran out of space and
+// emits a warning event when one newly fills up. The condition clears once a
+// backup to each of them succeeds again.
+func (r *BackupPolicyReconciler) updateStorageFullCondition(ctx context.Context, policy *backupv1alpha1.BackupPolicy, fullStorage map[string]time.Time) {
+	if len(fullStorage) == 0 {
+		r.updateCondition(ctx, policy, "StorageFull", metav1.ConditionFalse, "StorageAvailable", "Backup storage has space for new backups")
+		return
+	}
+
+	names := make([]string, 0, len(fullStorage))
+	for storagePVC := range fullStorage {
+		names = append(names, storagePVC)
+	}
+	sort.Strings(names)
+
+	message := fmt.Sprintf("Backup storage PVC(s) %s ran out of space; scheduled backups to them are retried every %s until one succeeds",
+		strings.Join(names, ", "), storageFullBackoff)
+	previous := meta.FindStatusCondition(policy.Status.Conditions, "StorageFull")
+	if r.Recorder != nil && (previous == nil || previous.Status != metav1.ConditionTrue || previous.Message != message) {
+		r.Recorder.Event(policy, corev1.EventTypeWarning, "StorageFull", message)
+	}
+	r.updateCondition(ctx, policy, "StorageFull", metav1.ConditionTrue, "NoSpaceLeft", message)
+}
+
+// jobFailureMessage explains why a backup Job failed, calling out a Job
+// terminated by ActiveDeadlineSeconds or at the end of its backup window so
+// hung backups are easy to tell apart
+func jobFailureMessage(job *batchv1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue &&
+			condition.Reason == batchv1.JobReasonDeadlineExceeded && job.Spec.ActiveDeadlineSeconds != nil {
+			if windowEnd := job.Annotations[windowEndAnnotation]; windowEnd != "" {
+				return fmt.Sprintf("Backup job was cancelled when the backup window closed at %s", windowEnd)
+			}
+			return fmt.Sprintf("Backup job exceeded its deadline of %ds and was terminated", *job.Spec.ActiveDeadlineSeconds)
+		}
+	}
+	return "Backup job failed"
+}
+
+// getBackupSize returns the archive size of a succeeded backup Job. The size is
+// read from the backup container's termination message and cached on the Job
+// as an annotation so it survives pod cleanup. Zero means it couldn't be
+// determined. pending reports that the Job's pod is known but its backup
+// container isn't seen as terminated yet, so the size may still show up.
+func (r *BackupPolicyReconciler) getBackupSize(ctx context.Context, job *batchv1.Job) (size int64, pending bool) {
+	log := log.FromContext(ctx)
+
+	if value, ok := job.Annotations[sizeAnnotation]; ok {
+		size, _ := strconv.ParseInt(value, 10, 64)
+		return size, false
+	}
+
+	podList := &corev1.PodList{}
+	if err := r.List(ctx, podList, client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name}); err != nil {
+		log.Error(err, "Failed to list pods for backup job", "job", job.Name)
+		return 0, true
+	}
+
+	for _, pod := range podList.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != "backup" {
+				continue
+			}
+			terminated := status.State.Terminated
+			if terminated == nil {
+				// The pod cache lags behind the Job's status
+				pending = true
+				continue
+			}
+			if terminated.ExitCode != 0 {
+				continue
+			}
+
+			size, err := strconv.ParseInt(strings.TrimSpace(terminated.Message), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+
+			patch := client.MergeFrom(job.DeepCopy())
+			if job.Annotations == nil {
+				job.Annotations = map[string]string{}
+			}
+			job.Annotations[sizeAnnotation] = strconv.FormatInt(size, 10)
+			if err := r.Patch(ctx, job, patch); err != nil {
+				log.Error(err, "Failed to record backup size on job", "job", job.Name)
+			}
+			return size, false
+		}
+	}
+
+	return 0, pending
+}
+
 func (r *BackupPolicyReconciler) cleanupOldBackups(ctx context.Context, policy *backupv1alpha1.BackupPolicy) error {
 	jobList := &batchv1.JobList{}
 	if err := r.List(ctx, jobList, client.InNamespace(policy.Namespace),
@@ -364,6 +1467,11 @@ func (r *BackupPolicyReconciler) cleanupOldBackups(ctx context.Context, policy *
 	return nil
 }
 
+// retentionEnabled reports whether old backups may be cleaned up, defaulting to true
+func retentionEnabled(policy *backupv1alpha1.BackupPolicy) bool {
+	return policy.Spec.RetentionEnabled == nil || *policy.Spec.RetentionEnabled
+}
+
 func (r *BackupPolicyReconciler) updateCondition(ctx context.Context, policy *backupv1alpha1.BackupPolicy, conditionType string, status metav1.ConditionStatus, reason, message string) {
 	condition := metav1.Condition{
 		Type:               conditionType,
@@ -377,6 +1485,10 @@ func (r *BackupPolicyReconciler) updateCondition(ctx context.Context, policy *ba
 	found := false
 	for i, c := range policy.Status.Conditions {
 		if c.Type == conditionType {
+			// Keep the transition time unless the status actually changed
+			if c.Status == status {
+				condition.LastTransitionTime = c.LastTransitionTime
+			}
 			policy.Status.Conditions[i] = condition
 			found = true
 			break
@@ -392,5 +1504,8 @@ func (r *BackupPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&backupv1alpha1.BackupPolicy{}).
 		Owns(&batchv1.Job{}).
+		// Hook Jobs are owned by their backup Job, so map them back by label
+		Watches(&batchv1.Job{}, handler.EnqueueRequestsFromMapFunc(r.findPolicyForHookJob)).
+		Watches(&corev1.PersistentVolumeClaim{}, handler.EnqueueRequestsFromMapFunc(r.findDryRunPoliciesForPVC)).
 		Complete(r)
 }
@@ -0,0 +1,528 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	backupv1alpha1 "github.com/nutcas3/statefulset-backup-operator/api/v1alpha1"
+)
+
+// newTestReconciler returns a BackupPolicyReconciler backed by a fake client
+// holding objs, whose calls go through funcs
+func newTestReconciler(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) *BackupPolicyReconciler {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := backupv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &BackupPolicyReconciler{
+		Client: fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&backupv1alpha1.BackupPolicy{}).
+			WithInterceptorFuncs(funcs).
+			Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}
+}
+
+func backupPolicy(name string) *backupv1alpha1.BackupPolicy {
+	return &backupv1alpha1.BackupPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: "policy-uid"},
+		Spec: backupv1alpha1.BackupPolicySpec{
+			Schedule:         "0 2 * * *",
+			BackupStoragePVC: "backups",
+		},
+	}
+}
+
+func pvc(name string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+}
+
+func TestCreateBackupJobTwiceInOneSecond(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t, interceptor.Funcs{})
+	policy := backupPolicy("nightly")
+
+	first, err := r.createBackupJob(ctx, policy, pvc("data-db-0"), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := r.createBackupJob(ctx, policy, pvc("data-db-0"), "", false)
+	if err != nil {
+		t.Fatalf("second backup failed: %v", err)
+	}
+	if first == second {
+		t.Errorf("both backups are named %s", first)
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := r.List(ctx, jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.Items) != 2 {
+		t.Errorf("%d jobs created, want 2", len(jobs.Items))
+	}
+}
+
+func TestCreateBackupJobRetriesNameCollisions(t *testing.T) {
+	tests := []struct {
+		name       string
+		collisions int
+		wantErr    bool
+	}{
+		{name: "no collision"},
+		{name: "one collision", collisions: 1},
+		{name: "collisions up to the last attempt", collisions: jobNameAttempts - 1},
+		{name: "every attempt collides", collisions: jobNameAttempts, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			var tried []string
+			r := newTestReconciler(t, interceptor.Funcs{
+				Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+					tried = append(tried, obj.GetName())
+					if len(tried) <= tt.collisions {
+						return apierrors.NewAlreadyExists(batchv1.Resource("jobs"), obj.GetName())
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			})
+
+			name, err := r.createBackupJob(ctx, backupPolicy("nightly"), pvc("data-db-0"), "", false)
+			if tt.wantErr {
+				if !apierrors.IsAlreadyExists(err) {
+					t.Errorf("createBackupJob() error = %v, want AlreadyExists", err)
+				}
+				if len(tried) != jobNameAttempts {
+					t.Errorf("tried %d names, want %d", len(tried), jobNameAttempts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createBackupJob() error = %v", err)
+			}
+			if len(tried) != tt.collisions+1 || name != tried[len(tried)-1] {
+				t.Errorf("created %s after trying %v", name, tried)
+			}
+			for _, taken := range tried[:tt.collisions] {
+				if taken == name {
+					t.Errorf("reused the colliding name %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestTarBackupCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		split      *backupv1alpha1.SplitSpec
+		excludes   []string
+		checkSpace bool
+		pvcName    string
+		want       []string
+		notWant    []string
+	}{
+		{
+			name:    "single archive",
+			pvcName: "data-db-0",
+			want:    []string{"tar czf /backup/db.tar.gz -C /data .", "file='/backup/db.tar.gz'", "printf '{\"pvc\""},
+			notWant: []string{".part-", "df -Pk"},
+		},
+		{
+			name:     "excludes",
+			excludes: []string{"/cache", "./tmp", "logs/old"},
+			pvcName:  "data-db-0",
+			want:     []string{" --exclude='./cache' --exclude='./tmp' --exclude='./logs/old' -C /data ."},
+		},
+		{
+			name:     "split",
+			split:    &backupv1alpha1.SplitSpec{},
+			excludes: []string{"cache"},
+			pvcName:  "data-db-0",
+			want: []string{
+				"file='/backup/db.tar.gz'",
+				`tar czf "$part" --exclude='./cache' "$entry" &`,
+				"if [ $running -ge 4 ]; then",
+				`for part in '/backup/db.tar.gz'.part-*; do`,
+			},
+			notWant: []string{"tar czf /backup/db.tar.gz"},
+		},
+		{
+			name:    "split parallelism",
+			split:   &backupv1alpha1.SplitSpec{Parallelism: 2},
+			pvcName: "data-db-0",
+			want:    []string{"if [ $running -ge 2 ]; then"},
+		},
+		{
+			name:       "space check",
+			checkSpace: true,
+			pvcName:    "data-db-0",
+			want:       []string{"need=$(du -sk /data | cut -f1);", "exit 75; fi && tar czf"},
+		},
+		{
+			name:       "space check before split",
+			split:      &backupv1alpha1.SplitSpec{},
+			checkSpace: true,
+			pvcName:    "data-db-0",
+			want:       []string{"exit 75; fi && (set -e\nfile='/backup/db.tar.gz'"},
+		},
+		{
+			name:    "odd PVC name",
+			pvcName: `it's "$(data)"`,
+			want:    []string{`'it'\''s "$(data)"'`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := backupPolicy("nightly")
+			policy.Spec.Split = tt.split
+			policy.Spec.ExcludePaths = tt.excludes
+			policy.Spec.CheckFreeSpace = tt.checkSpace
+
+			command := tarBackupCommand(policy, tt.pvcName, "/backup/db.tar.gz")
+			if tt.checkSpace != strings.HasPrefix(command, "need=") {
+				t.Errorf("command starts with the space check = %v, want %v:\n%s", !tt.checkSpace, tt.checkSpace, command)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(command, want) {
+					t.Errorf("command is missing %q:\n%s", want, command)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(command, notWant) {
+					t.Errorf("command contains %q:\n%s", notWant, command)
+				}
+			}
+		})
+	}
+}
+
+// scriptPaths matches the volume mount paths at the start of a shell word
+var scriptPaths = regexp.MustCompile(`(^|[\s'"])/(data|backup|standby)\b`)
+
+// runScript runs a Job command under sh with /data, /backup, /standby and the
+// termination log moved under root, and with the programs in bin ahead of
+// the PATH. It returns the combined output and the exit code.
+func runScript(t *testing.T, root, bin, command string) (string, int) {
+	t.Helper()
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar is not installed")
+	}
+	command = scriptPaths.ReplaceAllString(command, "${1}"+root+"/$2")
+	command = strings.ReplaceAll(command, "/dev/termination-log", root+"/termination-log")
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+// writeFiles creates each file under root with its name as its content
+func writeFiles(t *testing.T, root string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// archiveMembers lists the regular files in the archives matching pattern
+func archiveMembers(t *testing.T, pattern string) []string {
+	t.Helper()
+	archives, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []string
+	for _, archive := range archives {
+		if strings.HasSuffix(archive, ".json") || strings.HasSuffix(archive, ".parts") {
+			continue
+		}
+		out, err := exec.Command("tar", "tzf", archive).Output()
+		if err != nil {
+			t.Fatalf("listing %s: %v", archive, err)
+		}
+		for _, member := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			if member != "" && !strings.HasSuffix(member, "/") {
+				members = append(members, strings.TrimPrefix(member, "./"))
+			}
+		}
+	}
+	slices.Sort(members)
+	return members
+}
+
+func TestTarBackupCommandRuns(t *testing.T) {
+	tests := []struct {
+		name  string
+		split *backupv1alpha1.SplitSpec
+		parts int
+	}{
+		{name: "single archive"},
+		{name: "split", split: &backupv1alpha1.SplitSpec{Parallelism: 1}, parts: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, filepath.Join(root, "data"), "top.txt", ".hidden", "orders/1.json", "users/a b.txt", "cache/blob")
+			if err := os.MkdirAll(filepath.Join(root, "backup"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			policy := backupPolicy("nightly")
+			policy.Spec.Split = tt.split
+			policy.Spec.ExcludePaths = []string{"/cache"}
+
+			out, code := runScript(t, root, t.TempDir(), tarBackupCommand(policy, `it's-0`, "/backup/db.tar.gz"))
+			if code != 0 {
+				t.Fatalf("exit code %d:\n%s", code, out)
+			}
+
+			want := []string{".hidden", "orders/1.json", "top.txt", "users/a b.txt"}
+			if got := archiveMembers(t, filepath.Join(root, "backup", "db.tar.gz*")); !slices.Equal(got, want) {
+				t.Errorf("archived %q, want %q", got, want)
+			}
+
+			index, err := os.ReadFile(filepath.Join(root, "backup", "index.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var entries []struct {
+				PVC       string `json:"pvc"`
+				File      string `json:"file"`
+				SizeBytes int64  `json:"sizeBytes"`
+			}
+			if err := json.Unmarshal(index, &entries); err != nil {
+				t.Fatalf("index.json is not valid JSON: %v\n%s", err, index)
+			}
+			wantEntries := max(tt.parts, 1)
+			if len(entries) != wantEntries {
+				t.Fatalf("index has %d entries, want %d:\n%s", len(entries), wantEntries, index)
+			}
+			for _, entry := range entries {
+				if entry.PVC != `it's-0` || entry.SizeBytes == 0 || !strings.HasPrefix(entry.File, "db.tar.gz") {
+					t.Errorf("unexpected index entry %+v", entry)
+				}
+			}
+
+			if tt.split != nil {
+				parts, err := os.ReadFile(filepath.Join(root, "backup", "db.tar.gz.parts"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if lines := strings.Count(string(parts), "\n"); lines != tt.parts {
+					t.Errorf("parts file lists %d parts, want %d:\n%s", lines, tt.parts, parts)
+				}
+			}
+			if size, err := os.ReadFile(filepath.Join(root, "termination-log")); err != nil || strings.TrimSpace(string(size)) == "0" {
+				t.Errorf("termination log = %q, %v, want the archive size", size, err)
+			}
+		})
+	}
+}
+
+func TestSpaceCheckStopsBackup(t *testing.T) {
+	tests := []struct {
+		name     string
+		freeKiB  int
+		wantCode int
+	}{
+		{name: "enough space", freeKiB: 1 << 20},
+		{name: "insufficient space", freeKiB: 0, wantCode: insufficientSpaceExitCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, filepath.Join(root, "data"), "top.txt")
+			if err := os.MkdirAll(filepath.Join(root, "backup"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			// Report the free space the case calls for
+			bin := t.TempDir()
+			df := fmt.Sprintf("#!/bin/sh\nprintf 'Filesystem 1024-blocks Used Available Capacity Mounted\\nfake 0 0 %d 0%% /backup\\n'\n", tt.freeKiB)
+			if err := os.WriteFile(filepath.Join(bin, "df"), []byte(df), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			policy := backupPolicy("nightly")
+			policy.Spec.CheckFreeSpace = true
+			out, code := runScript(t, root, bin, tarBackupCommand(policy, "data-db-0", "/backup/db.tar.gz"))
+			if code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", code, tt.wantCode, out)
+			}
+			_, err := os.Stat(filepath.Join(root, "backup", "db.tar.gz"))
+			if written := err == nil; written != (tt.wantCode == 0) {
+				t.Errorf("archive written = %v, want %v", written, tt.wantCode == 0)
+			}
+			if tt.wantCode != 0 {
+				message, _ := os.ReadFile(filepath.Join(root, "termination-log"))
+				if !strings.Contains(string(message), "Insufficient space on") {
+					t.Errorf("termination log = %q, want the shortage explained", message)
+				}
+			}
+		})
+	}
+}
+
+func TestStandbyScriptRuns(t *testing.T) {
+	tests := []struct {
+		name  string
+		split *backupv1alpha1.SplitSpec
+	}{
+		{name: "single archive"},
+		{name: "split", split: &backupv1alpha1.SplitSpec{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, dir := range []string{"backup", "standby"} {
+				if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			policy := backupPolicy("nightly")
+			policy.Spec.Split = tt.split
+			policy.Spec.WarmStandby = &backupv1alpha1.WarmStandbySpec{PVC: "standby"}
+			r := newTestReconciler(t, interceptor.Funcs{})
+
+			// backup archives data as it is now, and returns the Job that made it
+			backup := func(timestamp string, files ...string) *batchv1.Job {
+				t.Helper()
+				data := filepath.Join(root, "data")
+				if err := os.RemoveAll(data); err != nil {
+					t.Fatal(err)
+				}
+				writeFiles(t, data, files...)
+				fileName, err := backupFileName(policy, "data-db-0", timestamp)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if out, code := runScript(t, root, t.TempDir(), tarBackupCommand(policy, "data-db-0", "/backup/"+fileName)); code != 0 {
+					t.Fatalf("backup exit code %d:\n%s", code, out)
+				}
+				return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+					Name:   "nightly-data-db-0-" + timestamp,
+					Labels: map[string]string{"pvc": "data-db-0", "timestamp": timestamp},
+				}}
+			}
+			// extract runs the standby Job for backup
+			extract := func(backup *batchv1.Job) (string, int) {
+				t.Helper()
+				job, err := r.desiredStandbyJob(policy, backup)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return runScript(t, root, t.TempDir(), job.Spec.Template.Spec.Containers[0].Command[2])
+			}
+			standby := filepath.Join(root, "standby", "data-db-0")
+
+			if out, code := extract(backup("20260101-020000", "orders/1.json", "top.txt")); code != 0 {
+				t.Fatalf("exit code %d:\n%s", code, out)
+			}
+			if _, err := os.Stat(filepath.Join(standby, "orders", "1.json")); err != nil {
+				t.Errorf("first backup was not extracted: %v", err)
+			}
+
+			// A newer backup replaces the copy instead of adding to it
+			if out, code := extract(backup("20260102-020000", "users/a.txt")); code != 0 {
+				t.Fatalf("exit code %d:\n%s", code, out)
+			}
+			if _, err := os.Stat(filepath.Join(standby, "users", "a.txt")); err != nil {
+				t.Errorf("second backup was not extracted: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(standby, "orders")); !os.IsNotExist(err) {
+				t.Errorf("files of the first backup remain: %v", err)
+			}
+			for _, leftover := range []string{"data-db-0.tmp", "data-db-0.old"} {
+				if _, err := os.Stat(filepath.Join(root, "standby", leftover)); !os.IsNotExist(err) {
+					t.Errorf("%s was left behind", leftover)
+				}
+			}
+
+			// A missing archive fails and keeps the current copy
+			missing := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "gone",
+				Labels: map[string]string{"pvc": "data-db-0", "timestamp": "20260103-020000"}}}
+			if _, code := extract(missing); code == 0 {
+				t.Error("extracting a missing archive succeeded")
+			}
+			if _, err := os.Stat(filepath.Join(standby, "users", "a.txt")); err != nil {
+				t.Errorf("failed extraction lost the current copy: %v", err)
+			}
+		})
+	}
+}
+
+func TestStandbyUsesRecordedArchive(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(t, interceptor.Funcs{})
+	policy := backupPolicy("nightly")
+	policy.Spec.WarmStandby = &backupv1alpha1.WarmStandbySpec{PVC: "standby"}
+	policy.Spec.PostBackupHook = &backupv1alpha1.PostBackupHook{Image: "catalog:latest"}
+
+	name, err := r.createBackupJob(ctx, policy, pvc("data-db-0"), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup := &batchv1.Job{}
+	if err := r.Get(ctx, client.ObjectKey{Name: name, Namespace: "default"}, backup); err != nil {
+		t.Fatal(err)
+	}
+	archive := backup.Annotations[archiveAnnotation]
+	if want := "data-db-0-" + backup.Labels["timestamp"] + ".tar.gz"; archive != want {
+		t.Fatalf("recorded archive %q, want %q", archive, want)
+	}
+
+	// Changing the template afterwards doesn't change which archive is extracted
+	policy.Spec.FileNameTemplate = "{{.PolicyName}}-{{.PVC}}-{{.Timestamp}}.tgz"
+	job, err := r.desiredStandbyJob(policy, backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if command := job.Spec.Template.Spec.Containers[0].Command[2]; !strings.Contains(command, shellQuote("/backup/"+archive)) {
+		t.Errorf("standby command doesn't extract %s:\n%s", archive, command)
+	}
+	hook := r.desiredHookJob(policy, backup, 0)
+	if env := hook.Spec.Template.Spec.Containers[0].Env; !slices.Contains(env, corev1.EnvVar{Name: "BACKUP_FILE", Value: archive}) {
+		t.Errorf("hook env %v doesn't pass BACKUP_FILE=%s", env, archive)
+	}
+}